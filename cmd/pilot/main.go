@@ -0,0 +1,112 @@
+// Command pilot is the toolkit of pilot applications.
+//
+//	pilot keygen [-id=k1]
+//	pilot encrypt [-key-file=keys] <value>
+//	pilot decrypt [-key-file=keys] <enc:v1:...>
+//
+// Keys are read from -key-file or environment PILOT_CONFIG_KEYS, one id:base64key per line,
+// the first one is used to encrypt, all of them could be used to decrypt.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/conf/secret"
+)
+
+const usage = `Usage: pilot <command> [flags] [value]
+
+Commands:
+  keygen    generate a key to encrypt config values
+  encrypt   encrypt value, read from stdin if value not provided
+  decrypt   decrypt value, read from stdin if value not provided
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "keygen":
+		err = keygen(args)
+	case "encrypt", "decrypt":
+		err = crypt(cmd, args)
+	case "-h", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pilot: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func keygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	id := fs.String("id", "k1", "id of the key, new id is required when rotating keys")
+	_ = fs.Parse(args)
+
+	key, err := secret.GenerateKey()
+	if err != nil {
+		return err
+	}
+	fmt.Println(secret.FormatKey(*id, key))
+	return nil
+}
+
+func crypt(cmd string, args []string) error {
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	keyFile := fs.String("key-file", os.Getenv("PILOT_CONFIG_KEY_FILE"), "file of keys, one id:base64key per line")
+	_ = fs.Parse(args)
+
+	var (
+		provider secret.KeyProvider
+		err      error
+	)
+	if *keyFile != "" {
+		provider, err = secret.NewFileKeyProvider(*keyFile)
+	} else {
+		provider, err = secret.NewEnvKeyProvider(conf.EnvConfigKeys)
+	}
+	if err != nil {
+		return err
+	}
+
+	value := strings.Join(fs.Args(), " ")
+	if value == "" {
+		if value, err = readValue(os.Stdin); err != nil {
+			return err
+		}
+	}
+
+	cipher := secret.NewCipher(provider)
+	if cmd == "encrypt" {
+		value, err = cipher.Encrypt(value)
+	} else {
+		value, err = cipher.Decrypt(value)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+	return nil
+}
+
+func readValue(r io.Reader) (string, error) {
+	content, err := io.ReadAll(bufio.NewReader(r))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
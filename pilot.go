@@ -0,0 +1,400 @@
+package pilot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/constant"
+	"github.com/5idu/pilot/pkg/flag"
+	"github.com/5idu/pilot/pkg/hooks"
+	"github.com/5idu/pilot/pkg/registry"
+	"github.com/5idu/pilot/pkg/server"
+	"github.com/5idu/pilot/pkg/server/governor"
+	"github.com/5idu/pilot/pkg/signals"
+	"github.com/5idu/pilot/pkg/util/xgo"
+	"github.com/5idu/pilot/pkg/xlog"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	// defaultStopTimeout max duration to wait for servers graceful stop
+	defaultStopTimeout = 30 * time.Second
+)
+
+// Application is the framework's instance, it governs the lifecycle of servers:
+// startup, serve, register into registry and shutdown.
+type Application struct {
+	smu         *sync.RWMutex
+	startupOnce sync.Once
+	signalOnce  sync.Once
+	stopOnce    sync.Once
+	servers     []server.Server
+	registered  []*server.ServiceInfo
+	governor    *governor.Server
+	stopTimeout time.Duration
+	stopped     chan struct{}
+	stopErr     error
+
+	disableGovernor bool
+	// unregistered servers are not registered any more once stopping
+	unregistered bool
+
+	// flagset parsed by Startup with args, the global flagset with os.Args if nil
+	flagset *flag.FlagSet
+	args    []string
+	// exit exits after the command selected by arguments ran, os.Exit by default
+	exit func(code int)
+}
+
+// Option overrides the default settings of Application.
+type Option func(app *Application)
+
+// WithStopTimeout sets the max duration to wait for servers graceful stop,
+// servers will be stopped immediately once timeout.
+// It takes precedence over the config key `pilot.application.stopTimeout`.
+func WithStopTimeout(timeout time.Duration) Option {
+	return func(app *Application) {
+		app.stopTimeout = timeout
+	}
+}
+
+// DisableGovernor disables the governor server which served by default.
+func DisableGovernor() Option {
+	return func(app *Application) {
+		app.disableGovernor = true
+	}
+}
+
+// New constructs an Application with options.
+func New(opts ...Option) *Application {
+	app := &Application{
+		smu:        &sync.RWMutex{},
+		servers:    make([]server.Server, 0),
+		registered: make([]*server.ServiceInfo, 0),
+		stopped:    make(chan struct{}),
+		exit:       os.Exit,
+	}
+	for _, opt := range opts {
+		opt(app)
+	}
+	return app
+}
+
+// Startup parses flags, which loads the configuration, then runs fns serially.
+// It returns the first error met.
+// If a command is selected by arguments, such as `app config check`, the command runs instead of fns,
+// and the process exits with its result.
+func (app *Application) Startup(fns ...func() error) (err error) {
+	app.startupOnce.Do(func() {
+		if err = app.parseFlags(); err != nil {
+			return
+		}
+		if ran, cmdErr := app.runCommand(); ran {
+			code := 0
+			if cmdErr != nil {
+				fmt.Fprintln(os.Stderr, cmdErr)
+				code = 1
+			}
+			app.exit(code)
+			err = cmdErr
+			return
+		}
+		err = xgo.SerialUntilError(fns...)()
+	})
+	return
+}
+
+func (app *Application) parseFlags() error {
+	if app.flagset == nil {
+		return flag.Parse()
+	}
+	return app.flagset.ParseArgs(app.args)
+}
+
+func (app *Application) runCommand() (bool, error) {
+	if app.flagset == nil {
+		return flag.RunCommand()
+	}
+	return app.flagset.RunCommand()
+}
+
+// Serve starts all servers concurrently with the governor server,
+// and blocks until the application stopped.
+// Each server will be registered into registry.DefaultRegisterer once it is serving, see server.Readier.
+func (app *Application) Serve(servers ...server.Server) error {
+	app.smu.Lock()
+	app.servers = append(app.servers, servers...)
+	app.smu.Unlock()
+
+	if err := app.initGovernor(); err != nil {
+		return err
+	}
+
+	// servers are not started if any hook of BeforeRun failed
+	if err := hooks.DoE(context.Background(), hooks.Stage_BeforeRun); err != nil {
+		return err
+	}
+	app.waitSignals()
+
+	app.smu.RLock()
+	fns := make([]func() error, 0, len(app.servers))
+	for _, s := range app.servers {
+		s := s
+		fns = append(fns, func() error {
+			err := s.Serve()
+			if err != nil {
+				xlog.Error("server serve failed", xlog.String("mod", "app"), xlog.Any("info", s.Info()), xlog.FieldErr(err))
+				// one server failed, shutdown the whole application
+				xgo.Go(app.shutdown)
+			}
+			return err
+		})
+	}
+	app.smu.RUnlock()
+
+	errs := xgo.ParallelWithErrorChan(fns...)
+	_ = hooks.DoE(context.Background(), hooks.Stage_AfterRun)
+	app.registerServers()
+
+	var err error
+	for e := range errs {
+		err = multierr.Append(err, e)
+	}
+	// all servers returned, the application is stopped even if none failed
+	app.shutdown()
+	<-app.stopped
+
+	return multierr.Append(err, app.stopErr)
+}
+
+// Stop stops the application immediately after necessary cleanup.
+func (app *Application) Stop() (err error) {
+	app.stopOnce.Do(func() {
+		// hooks are abandoned after stop timeout, they never block stopping
+		ctx, cancel := context.WithTimeout(context.Background(), app.getStopTimeout())
+		defer cancel()
+
+		err = hooks.DoE(ctx, hooks.Stage_BeforeStop)
+		app.unregisterServers(ctx)
+		err = multierr.Append(err, app.stopServers())
+		err = multierr.Append(err, app.afterStop())
+
+		app.stopErr = err
+		close(app.stopped)
+	})
+	return
+}
+
+// GracefulStop stops the application gracefully after necessary cleanup,
+// servers will be stopped immediately once ctx done.
+func (app *Application) GracefulStop(ctx context.Context) (err error) {
+	app.stopOnce.Do(func() {
+		// hooks are abandoned once ctx done, servers still get stopped
+		hookErr := hooks.DoE(ctx, hooks.Stage_BeforeStop)
+		app.unregisterServers(ctx)
+
+		app.smu.RLock()
+		fns := make([]func() error, 0, len(app.servers))
+		for _, s := range app.servers {
+			s := s
+			fns = append(fns, func() error {
+				return s.GracefulStop(ctx)
+			})
+		}
+		app.smu.RUnlock()
+
+		done := make(chan error, 1)
+		go func() {
+			var errs error
+			for e := range xgo.ParallelWithErrorChan(fns...) {
+				errs = multierr.Append(errs, e)
+			}
+			done <- errs
+		}()
+
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			xlog.Warn("graceful stop timeout, stop servers immediately", xlog.String("mod", "app"))
+			err = multierr.Append(ctx.Err(), app.stopServers())
+		}
+		err = multierr.Combine(hookErr, err, app.afterStop())
+
+		app.stopErr = err
+		close(app.stopped)
+	})
+	return
+}
+
+// shutdown stops the application gracefully within stop timeout.
+func (app *Application) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), app.getStopTimeout())
+	defer cancel()
+	if err := app.GracefulStop(ctx); err != nil {
+		xlog.Error("graceful stop failed", xlog.String("mod", "app"), xlog.FieldErr(err))
+	}
+}
+
+// waitSignals registers the shutdown signals once, a second Serve doesn't add another handler
+func (app *Application) waitSignals() {
+	app.signalOnce.Do(func() {
+		signals.Shutdown(func(grace bool) {
+			xlog.Info("receive shutdown signal", xlog.String("mod", "app"), xlog.Bool("grace", grace))
+			if grace {
+				app.shutdown()
+				return
+			}
+			if err := app.Stop(); err != nil {
+				xlog.Error("stop failed", xlog.String("mod", "app"), xlog.FieldErr(err))
+			}
+		})
+	})
+}
+
+// afterStop runs hooks of AfterStop within stop timeout,
+// they still run even if the context of stopping is done.
+func (app *Application) afterStop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), app.getStopTimeout())
+	defer cancel()
+	return hooks.DoE(ctx, hooks.Stage_AfterStop)
+}
+
+func (app *Application) stopServers() (err error) {
+	app.smu.RLock()
+	defer app.smu.RUnlock()
+	for _, s := range app.servers {
+		err = multierr.Append(err, s.Stop())
+	}
+	return
+}
+
+func (app *Application) registerServers() {
+	// servers are not registered if any hook of BeforeRegister failed, such as warming up
+	if err := hooks.DoE(context.Background(), hooks.Stage_BeforeRegister); err != nil {
+		xlog.Error("skip registering services", xlog.String("mod", "app"), xlog.FieldErr(err))
+		return
+	}
+
+	app.smu.RLock()
+	servers := append([]server.Server(nil), app.servers...)
+	app.smu.RUnlock()
+	for _, s := range servers {
+		// governor server only serves the current process
+		if _, ok := s.(*governor.Server); ok {
+			continue
+		}
+		readier, ok := s.(server.Readier)
+		if !ok {
+			app.registerServer(s)
+			continue
+		}
+		s := s
+		xgo.Go(func() {
+			select {
+			case <-readier.Ready():
+				app.registerServer(s)
+			case <-app.stopped:
+			}
+		})
+	}
+}
+
+// registerServer registers services of s, unless the application is stopping
+func (app *Application) registerServer(s server.Server) {
+	app.smu.Lock()
+	defer app.smu.Unlock()
+	if app.unregistered {
+		return
+	}
+	for _, info := range server.ServiceInfos(s) {
+		if err := registry.DefaultRegisterer.RegisterService(context.Background(), info); err != nil {
+			xlog.Error("register service failed", xlog.String("mod", "app"), xlog.Any("info", info), xlog.FieldErr(err))
+			continue
+		}
+		app.registered = append(app.registered, info)
+	}
+}
+
+func (app *Application) unregisterServers(ctx context.Context) {
+	app.smu.Lock()
+	app.unregistered = true
+	for _, info := range app.registered {
+		if err := registry.DefaultRegisterer.UnregisterService(ctx, info); err != nil {
+			xlog.Error("unregister service failed", xlog.String("mod", "app"), xlog.Any("info", info), xlog.FieldErr(err))
+		}
+	}
+	app.registered = app.registered[:0]
+	app.smu.Unlock()
+
+	_ = hooks.DoE(ctx, hooks.Stage_AfterUnregister)
+}
+
+// initGovernor builds the governor server with config `pilot.governor`,
+// which exposes the registry info and routes of current application.
+func (app *Application) initGovernor() error {
+	if app.disableGovernor || app.governor != nil {
+		return nil
+	}
+	config := governor.StdConfig("governor")
+	if !config.Enable {
+		return nil
+	}
+	s, err := config.Build()
+	if err != nil {
+		return err
+	}
+	s.HandleFunc("/debug/registry", func(w http.ResponseWriter, r *http.Request) {
+		governor.WriteJSON(w, app.registeredServices())
+	})
+	s.HandleFunc("/debug/routes", func(w http.ResponseWriter, r *http.Request) {
+		governor.WriteJSON(w, app.serverRoutes())
+	})
+
+	app.smu.Lock()
+	app.governor = s
+	app.servers = append(app.servers, s)
+	app.smu.Unlock()
+	return nil
+}
+
+func (app *Application) registeredServices() map[string]interface{} {
+	app.smu.RLock()
+	defer app.smu.RUnlock()
+	return map[string]interface{}{
+		"kind":     registry.DefaultRegisterer.Kind(),
+		"services": app.registered,
+	}
+}
+
+type serverRoutes struct {
+	Info   *server.ServiceInfo `json:"info"`
+	Routes []server.Route      `json:"routes"`
+}
+
+func (app *Application) serverRoutes() []serverRoutes {
+	app.smu.RLock()
+	defer app.smu.RUnlock()
+	out := make([]serverRoutes, 0, len(app.servers))
+	for _, s := range app.servers {
+		if lister, ok := s.(server.RouteLister); ok {
+			out = append(out, serverRoutes{Info: s.Info(), Routes: lister.ListRoutes()})
+		}
+	}
+	return out
+}
+
+func (app *Application) getStopTimeout() time.Duration {
+	if app.stopTimeout > 0 {
+		return app.stopTimeout
+	}
+	if timeout := conf.GetDuration(constant.ConfigKey("application.stopTimeout")); timeout > 0 {
+		return timeout
+	}
+	return defaultStopTimeout
+}
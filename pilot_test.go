@@ -0,0 +1,255 @@
+package pilot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/constant"
+	"github.com/5idu/pilot/pkg/flag"
+	"github.com/5idu/pilot/pkg/hooks"
+	"github.com/5idu/pilot/pkg/server"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testServer struct {
+	stop     chan struct{}
+	serveErr error
+	blocking bool
+	stopped  int32
+}
+
+func newTestServer() *testServer {
+	return &testServer{stop: make(chan struct{})}
+}
+
+func (s *testServer) Serve() error {
+	if s.serveErr != nil {
+		return s.serveErr
+	}
+	<-s.stop
+	return nil
+}
+
+func (s *testServer) Stop() error {
+	if atomic.CompareAndSwapInt32(&s.stopped, 0, 1) {
+		close(s.stop)
+	}
+	return nil
+}
+
+func (s *testServer) GracefulStop(ctx context.Context) error {
+	if s.blocking {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return s.Stop()
+}
+
+func (s *testServer) Info() *server.ServiceInfo {
+	info := server.ApplyOptions(server.WithScheme("test"), server.WithAddress("127.0.0.1:0"))
+	return &info
+}
+
+func (s *testServer) Healthz() bool { return true }
+
+func TestApplicationGracefulStop(t *testing.T) {
+	var stages int32
+	hooks.Register(hooks.Stage_BeforeStop, func() { atomic.AddInt32(&stages, 1) })
+	hooks.Register(hooks.Stage_AfterStop, func() { atomic.AddInt32(&stages, 1) })
+
+	app := New(DisableGovernor())
+	s1, s2 := newTestServer(), newTestServer()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		assert.Nil(t, app.GracefulStop(context.Background()))
+	}()
+
+	assert.Nil(t, app.Serve(s1, s2))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&s1.stopped))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&s2.stopped))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&stages))
+}
+
+func TestApplicationStopTimeout(t *testing.T) {
+	app := New(DisableGovernor(), WithStopTimeout(100*time.Millisecond))
+	s := newTestServer()
+	s.blocking = true
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		app.shutdown()
+	}()
+
+	err := app.Serve(s)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&s.stopped))
+}
+
+func TestApplicationServeFailed(t *testing.T) {
+	app := New(DisableGovernor())
+	s1, s2 := newTestServer(), newTestServer()
+	s1.serveErr = errors.New("listen failed")
+
+	err := app.Serve(s1, s2)
+	assert.ErrorIs(t, err, s1.serveErr)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&s2.stopped))
+}
+
+func TestApplicationServeReturned(t *testing.T) {
+	app := New(DisableGovernor())
+	s := newTestServer()
+	// Serve returns nil immediately
+	_ = s.Stop()
+
+	done := make(chan error, 1)
+	go func() { done <- app.Serve(s) }()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Serve blocked after all servers returned")
+	}
+}
+
+// readyServer binds its listener in Serve
+type readyServer struct {
+	*testServer
+	ready chan struct{}
+	bind  chan struct{}
+}
+
+func (s *readyServer) Serve() error {
+	<-s.bind
+	close(s.ready)
+	return s.testServer.Serve()
+}
+
+func (s *readyServer) Ready() <-chan struct{} { return s.ready }
+
+func TestApplicationRegisterReady(t *testing.T) {
+	app := New(DisableGovernor())
+	s := &readyServer{testServer: newTestServer(), ready: make(chan struct{}), bind: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() { done <- app.Serve(s) }()
+
+	registered := func() int {
+		app.smu.RLock()
+		defer app.smu.RUnlock()
+		return len(app.registered)
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, registered())
+
+	close(s.bind)
+	assert.Eventually(t, func() bool { return registered() == 1 }, time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, app.GracefulStop(context.Background()))
+	assert.Nil(t, <-done)
+	assert.Equal(t, 0, registered())
+}
+
+func TestApplicationGovernor(t *testing.T) {
+	conf.Set(constant.ConfigKey("governor.host"), "127.0.0.1")
+	conf.Set(constant.ConfigKey("governor.port"), 0)
+
+	app := New()
+	assert.Nil(t, app.initGovernor())
+	assert.NotNil(t, app.governor)
+	defer app.governor.Stop()
+
+	s := newTestServer()
+	app.servers = append(app.servers, s)
+	app.registerServers()
+	services := app.registeredServices()
+	assert.Equal(t, "local", services["kind"])
+	assert.Len(t, services["services"], 1)
+}
+
+func TestApplicationHooks(t *testing.T) {
+	// hooks are global, they only take effect within this test
+	var enabled int32 = 1
+	defer atomic.StoreInt32(&enabled, 0)
+
+	beforeRunErr := errors.New("not ready")
+	var failed int32 = 1
+	hooks.RegisterHook(hooks.Stage_BeforeRun, "test-before-run", func(ctx context.Context) error {
+		if atomic.LoadInt32(&enabled) == 1 && atomic.LoadInt32(&failed) == 1 {
+			return beforeRunErr
+		}
+		return nil
+	})
+	hooks.RegisterHook(hooks.Stage_BeforeStop, "test-hang", func(ctx context.Context) error {
+		if atomic.LoadInt32(&enabled) == 1 {
+			<-ctx.Done()
+		}
+		return nil
+	})
+
+	// failed BeforeRun aborts startup
+	s := newTestServer()
+	err := New(DisableGovernor()).Serve(s)
+	assert.ErrorIs(t, err, beforeRunErr)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&s.stopped))
+
+	// hanging BeforeStop doesn't block shutdown
+	atomic.StoreInt32(&failed, 0)
+	app := New(DisableGovernor(), WithStopTimeout(100*time.Millisecond))
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		app.shutdown()
+	}()
+	start := time.Now()
+	err = app.Serve(s)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&s.stopped))
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestApplicationStartupCommand(t *testing.T) {
+	newApp := func(args ...string) (*Application, *[]int) {
+		var codes []int
+		app := New()
+		app.flagset = flag.NewFlagSet("app")
+		app.flagset.SetOutput(io.Discard)
+		app.flagset.RegisterCommand(&flag.Command{
+			Name: "migrate",
+			Action: func(fs *flag.FlagSet) error {
+				if fs.Arg(0) == "fail" {
+					return errors.New("migrate failed")
+				}
+				return nil
+			},
+		})
+		app.args = args
+		app.exit = func(code int) { codes = append(codes, code) }
+		return app, &codes
+	}
+
+	// command runs and exits instead of starting application
+	var started int32
+	startup := func() error {
+		atomic.AddInt32(&started, 1)
+		return nil
+	}
+	app, codes := newApp("migrate")
+	assert.Nil(t, app.Startup(startup))
+	assert.Equal(t, []int{0}, *codes)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&started))
+
+	app, codes = newApp("migrate", "fail")
+	assert.EqualError(t, app.Startup(startup), "migrate failed")
+	assert.Equal(t, []int{1}, *codes)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&started))
+
+	// positional arguments matching no command are kept
+	app, codes = newApp("foo")
+	assert.Nil(t, app.Startup(startup))
+	assert.Empty(t, *codes)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&started))
+	assert.Equal(t, []string{"foo"}, app.flagset.Args())
+}
@@ -0,0 +1,32 @@
+package consul
+
+import (
+	"github.com/hashicorp/consul/api"
+	"github.com/pkg/errors"
+)
+
+// Client ...
+type Client struct {
+	*api.Client
+	config *Config
+}
+
+func newClient(config *Config) (*Client, error) {
+	apiConfig := api.DefaultNonPooledConfig()
+	apiConfig.Address = config.Address
+	apiConfig.Scheme = config.Scheme
+	apiConfig.Datacenter = config.Datacenter
+	apiConfig.Token = config.Token
+	apiConfig.WaitTime = config.WaitTime
+	if config.UserName != "" {
+		apiConfig.HttpAuth = &api.HttpBasicAuth{Username: config.UserName, Password: config.Password}
+	}
+	apiConfig.TLSConfig.CAFile = config.CaFile
+	apiConfig.TLSConfig.InsecureSkipVerify = config.Insecure
+
+	client, err := api.NewClient(apiConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "create consul client")
+	}
+	return &Client{Client: client, config: config}, nil
+}
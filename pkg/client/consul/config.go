@@ -0,0 +1,99 @@
+package consul
+
+import (
+	"time"
+
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/constant"
+	"github.com/5idu/pilot/pkg/singleton"
+	"github.com/5idu/pilot/pkg/xlog"
+
+	"github.com/pkg/errors"
+)
+
+// Config ...
+type Config struct {
+	Name string `json:"name"`
+	// Address of consul agent, host:port
+	Address    string `json:"address"`
+	Scheme     string `json:"scheme"`
+	Datacenter string `json:"datacenter"`
+	Token      string `json:"-"`
+	UserName   string `json:"userName"`
+	Password   string `json:"-"`
+	CaFile     string `json:"caFile"`
+	Insecure   bool   `json:"insecure"`
+	// WaitTime max duration of blocking queries
+	WaitTime time.Duration `json:"waitTime"`
+	logger   *xlog.Logger
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		Address:  "127.0.0.1:8500",
+		Scheme:   "http",
+		WaitTime: 5 * time.Minute,
+		logger:   xlog.With(xlog.String("mod", "client.consul")),
+	}
+}
+
+// StdConfig ...
+func StdConfig(name string) *Config {
+	return RawConfig(constant.ConfigKey("consul." + name))
+}
+
+// RawConfig ...
+func RawConfig(key string) *Config {
+	var config = DefaultConfig()
+	config.Name = key
+
+	if err := conf.UnmarshalKey(key, config); err != nil {
+		panic(errors.WithMessage(err, "client consul parse config failed"))
+	}
+
+	return config
+}
+
+// WithLogger ...
+func (config *Config) WithLogger(logger *xlog.Logger) *Config {
+	config.logger = logger
+	return config
+}
+
+// Build ...
+func (config *Config) Build() (*Client, error) {
+	return newClient(config)
+}
+
+func (config *Config) Singleton() (*Client, error) {
+	if client, ok := singleton.Load(constant.ModuleClientConsul, config.Name); ok && client != nil {
+		return client.(*Client), nil
+	}
+
+	client, err := config.Build()
+	if err != nil {
+		xlog.Error("build consul client failed", xlog.Any("error", err))
+		return nil, err
+	}
+
+	singleton.Store(constant.ModuleClientConsul, config.Name, client)
+
+	return client, nil
+}
+
+func (config *Config) MustBuild() *Client {
+	client, err := config.Build()
+	if err != nil {
+		xlog.Panic("build consul client failed", xlog.Any("error", err))
+	}
+	return client
+}
+
+func (config *Config) MustSingleton() *Client {
+	client, err := config.Singleton()
+	if err != nil {
+		xlog.Panic("build consul client failed", xlog.Any("error", err))
+	}
+	return client
+}
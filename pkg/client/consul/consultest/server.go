@@ -0,0 +1,289 @@
+// Package consultest provides a fake consul agent for tests, which serves kv, agent services and health apis in memory.
+package consultest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/5idu/pilot/pkg/client/consul"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// maxWait max duration of blocking queries
+const maxWait = time.Second
+
+// Server is a fake consul agent
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	index    uint64
+	updated  chan struct{}
+	kv       map[string]*api.KVPair
+	services map[string]*api.AgentServiceRegistration
+	// checks status of checks by check id
+	checks map[string]string
+}
+
+// NewServer starts a fake consul agent
+func NewServer() *Server {
+	s := &Server{
+		index:    1,
+		updated:  make(chan struct{}),
+		kv:       make(map[string]*api.KVPair),
+		services: make(map[string]*api.AgentServiceRegistration),
+		checks:   make(map[string]string),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/kv/", s.handleKV)
+	mux.HandleFunc("/v1/agent/service/register", s.handleRegister)
+	mux.HandleFunc("/v1/agent/service/deregister/", s.handleDeregister)
+	mux.HandleFunc("/v1/agent/check/update/", s.handleUpdateTTL)
+	mux.HandleFunc("/v1/health/service/", s.handleHealth)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Config returns config of consul client connecting to the server
+func (s *Server) Config() *consul.Config {
+	config := consul.DefaultConfig()
+	config.Address = strings.TrimPrefix(s.URL, "http://")
+	config.WaitTime = maxWait
+	return config
+}
+
+// Put sets value of the key
+func (s *Server) Put(key string, value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(key, value)
+}
+
+// CheckStatus returns status of the check, empty if not exists
+func (s *Server) CheckStatus(checkID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checks[checkID]
+}
+
+// Services returns registered services by id
+func (s *Server) Services() map[string]*api.AgentServiceRegistration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	services := make(map[string]*api.AgentServiceRegistration, len(s.services))
+	for id, service := range s.services {
+		services[id] = service
+	}
+	return services
+}
+
+// put must be called with s.mu held
+func (s *Server) put(key string, value []byte) {
+	s.index++
+	pair, ok := s.kv[key]
+	if !ok {
+		pair = &api.KVPair{Key: key, CreateIndex: s.index}
+		s.kv[key] = pair
+	}
+	pair.Value = value
+	pair.ModifyIndex = s.index
+	s.notify()
+}
+
+// notify wakes up blocking queries, must be called with s.mu held
+func (s *Server) notify() {
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+// wait blocks until index changed or wait elapsed, if index is requested
+func (s *Server) wait(r *http.Request) {
+	index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	wait, err := time.ParseDuration(r.URL.Query().Get("wait"))
+	if err != nil || wait > maxWait {
+		wait = maxWait
+	}
+	s.mu.Lock()
+	current, updated := s.index, s.updated
+	s.mu.Unlock()
+	if index == 0 || index != current {
+		return
+	}
+	select {
+	case <-updated:
+	case <-time.After(wait):
+	case <-r.Context().Done():
+	}
+}
+
+// writeJSON must be called with s.mu held
+func (s *Server) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(s.index, 10))
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) handleKV(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	switch r.Method {
+	case http.MethodGet:
+		s.wait(r)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		pair, ok := s.kv[key]
+		if !ok {
+			w.Header().Set("X-Consul-Index", strconv.FormatUint(s.index, 10))
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.writeJSON(w, []*api.KVPair{pair})
+	case http.MethodPut:
+		value, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if cas := r.URL.Query().Get("cas"); cas != "" {
+			index, _ := strconv.ParseUint(cas, 10, 64)
+			pair, ok := s.kv[key]
+			if (index == 0 && ok) || (index != 0 && (!ok || pair.ModifyIndex != index)) {
+				s.writeJSON(w, false)
+				return
+			}
+		}
+		s.put(key, value)
+		s.writeJSON(w, true)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var service api.AgentServiceRegistration
+	if err := json.NewDecoder(r.Body).Decode(&service); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index++
+	s.services[service.ID] = &service
+	if check := service.Check; check != nil {
+		checkID := check.CheckID
+		if checkID == "" {
+			checkID = "service:" + service.ID
+		}
+		status := check.Status
+		// ttl checks are critical until updated, other checks are not probed and treated as passing
+		if status == "" && check.TTL == "" {
+			status = api.HealthPassing
+		} else if status == "" {
+			status = api.HealthCritical
+		}
+		s.checks[checkID] = status
+	}
+	s.notify()
+}
+
+func (s *Server) handleDeregister(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.services[id]; !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.index++
+	delete(s.services, id)
+	for checkID := range s.checks {
+		if checkID == "service:"+id {
+			delete(s.checks, checkID)
+		}
+	}
+	s.notify()
+}
+
+func (s *Server) handleUpdateTTL(w http.ResponseWriter, r *http.Request) {
+	checkID := strings.TrimPrefix(r.URL.Path, "/v1/agent/check/update/")
+	var update struct {
+		Status string
+		Output string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.checks[checkID]; !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if s.checks[checkID] != update.Status {
+		s.index++
+		s.checks[checkID] = update.Status
+		s.notify()
+	}
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/v1/health/service/")
+	s.wait(r)
+
+	query := r.URL.Query()
+	_, passingOnly := query["passing"]
+	tags := query["tag"]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]*api.ServiceEntry, 0)
+	for id, service := range s.services {
+		if service.Name != name || !hasTags(service.Tags, tags) {
+			continue
+		}
+		status := s.checks["service:"+id]
+		if status == "" {
+			status = api.HealthPassing
+		}
+		if passingOnly && status != api.HealthPassing {
+			continue
+		}
+		entries = append(entries, &api.ServiceEntry{
+			Node: &api.Node{Node: "fake", Address: "127.0.0.1"},
+			Service: &api.AgentService{
+				ID:      service.ID,
+				Service: service.Name,
+				Tags:    service.Tags,
+				Address: service.Address,
+				Port:    service.Port,
+				Meta:    service.Meta,
+			},
+			Checks: api.HealthChecks{{CheckID: "service:" + id, ServiceID: id, Status: status}},
+		})
+	}
+	s.writeJSON(w, entries)
+}
+
+func hasTags(tags, expects []string) bool {
+	for _, expect := range expects {
+		found := false
+		for _, tag := range tags {
+			if tag == expect {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
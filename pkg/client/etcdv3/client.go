@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/5idu/pilot/pkg/health"
 	"github.com/5idu/pilot/pkg/xlog"
 
 	grpcprom "github.com/grpc-ecosystem/go-grpc-prometheus"
@@ -108,10 +109,24 @@ func newClient(config *Config) (*Client, error) {
 		Client: client,
 		config: config,
 	}
+	health.RegisterReadiness(cc.healthName(), health.CheckerFunc(func(ctx context.Context) error {
+		_, err := client.Get(ctx, "health")
+		return err
+	}))
 
 	return cc, nil
 }
 
+// Close unregisters the health checker and closes the client
+func (client *Client) Close() error {
+	health.Unregister(client.healthName())
+	return client.Client.Close()
+}
+
+func (client *Client) healthName() string {
+	return "etcdv3:" + client.config.Name
+}
+
 // GetKeyValue queries etcd key, returns mvccpb.KeyValue
 func (client *Client) GetKeyValue(ctx context.Context, key string) (kv *mvccpb.KeyValue, err error) {
 	rp, err := client.Client.Get(ctx, key)
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/5idu/pilot/pkg/constant"
+	"github.com/5idu/pilot/pkg/health"
 	"github.com/5idu/pilot/pkg/singleton"
 
 	"github.com/pkg/errors"
@@ -31,6 +32,7 @@ func (ins *Client) CmdOnMaster() *redis.Client {
 }
 
 func (ins *Client) Close() {
+	health.Unregister(ins.healthName())
 	if ins.master != nil {
 		ins.master.Close()
 	}
@@ -76,7 +78,7 @@ func (config *Config) MustBuild() *Client {
 
 // Build ..
 func (config *Config) Build() (*Client, error) {
-	ins := new(Client)
+	ins := &Client{config: config}
 	var err error
 	ins.master, err = config.build(config.Addr, config.Username, config.Password)
 	if err != nil {
@@ -85,9 +87,16 @@ func (config *Config) Build() (*Client, error) {
 	if ins.master == nil {
 		return ins, errors.New("no master for " + config.name)
 	}
+	health.RegisterReadiness(ins.healthName(), health.CheckerFunc(func(ctx context.Context) error {
+		return ins.master.Ping(ctx).Err()
+	}))
 	return ins, nil
 }
 
+func (ins *Client) healthName() string {
+	return "redis:" + ins.config.name
+}
+
 func (config *Config) build(addr, user, pass string) (*redis.Client, error) {
 
 	client := redis.NewClient(&redis.Options{
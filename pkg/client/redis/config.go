@@ -11,22 +11,26 @@ import (
 	"github.com/spf13/cast"
 )
 
+func init() {
+	conf.RegisterSchemaFunc(func() string { return constant.ConfigKey("redis.*") }, DefaultConfig())
+}
+
 // Config ...
 type Config struct {
-	Addr     string `json:"addr"`
+	Addr     string `json:"addr" validate:"required"`
 	Username string `json:"username"`
 	Password string `json:"password"`
 	/****** for github.com/redis/go-redis/v9 ******/
 	// DB default 0,not recommend
 	DB int `json:"db"`
 	// PoolSize applies per Stub node and not for the whole Stub.
-	PoolSize int `json:"poolSize"`
+	PoolSize int `json:"poolSize" validate:"gte=0"`
 	// Maximum number of retries before giving up.
 	// Default is 3 retries; -1 (not 0) disables retries.
 	MaxRetries int `json:"maxRetries"`
 	// Minimum number of idle connections which is useful when establishing
 	// new connection is slow.
-	MinIdleConns int `json:"minIdleConns"`
+	MinIdleConns int `json:"minIdleConns" validate:"gte=0"`
 	// Dial timeout for establishing new connections.
 	// Default is 5 seconds.
 	DialTimeout time.Duration `json:"dialTimeout"`
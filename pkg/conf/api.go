@@ -9,6 +9,9 @@ import (
 // Unmarshaller ...
 type Unmarshaller = func([]byte, interface{}) error
 
+// Marshaller ...
+type Marshaller = func(interface{}) ([]byte, error)
+
 var defaultConfiguration = New()
 
 // OnChange 注册change回调函数
@@ -27,6 +30,11 @@ func LoadFromDataSource(ds DataSource, unmarshaller Unmarshaller) error {
 	return defaultConfiguration.LoadFromDataSource(ds, unmarshaller)
 }
 
+// LoadLayerFromDataSource load configuration from data source into the layer with default defaultConfiguration
+func LoadLayerFromDataSource(layer Layer, name string, ds DataSource, unmarshaller Unmarshaller) error {
+	return defaultConfiguration.LoadLayerFromDataSource(layer, name, ds, unmarshaller)
+}
+
 // Load loads configuration from provided provider with default defaultConfiguration.
 func LoadFromReader(r io.Reader, unmarshaller Unmarshaller) error {
 	return defaultConfiguration.LoadFromReader(r, unmarshaller)
@@ -42,9 +50,19 @@ func Reset() {
 	defaultConfiguration = New()
 }
 
+// Default returns the default Configuration, which is used by functions of the package
+func Default() *Configuration {
+	return defaultConfiguration
+}
+
+// SetDefaultConfiguration replaces the default Configuration, such as by a new one in tests
+func SetDefaultConfiguration(c *Configuration) {
+	defaultConfiguration = c
+}
+
 // Traverse ...
 func Traverse(sep string) map[string]interface{} {
-	return defaultConfiguration.traverse(sep)
+	return defaultConfiguration.Traverse(sep)
 }
 
 // Debug ...
@@ -66,3 +84,68 @@ func Exists(key string) bool {
 func Set(key string, val interface{}) {
 	_ = defaultConfiguration.Set(key, val)
 }
+
+// SetDecrypter sets the Decrypter of encrypted values with default defaultConfiguration
+func SetDecrypter(decrypter Decrypter) {
+	defaultConfiguration.SetDecrypter(decrypter)
+}
+
+// WriteConfig persists values set by Set into the writable data source with default defaultConfiguration
+func WriteConfig() error {
+	return defaultConfiguration.WriteConfig()
+}
+
+// SetDefault set default value for key
+func SetDefault(key string, val interface{}) {
+	defaultConfiguration.SetDefault(key, val)
+}
+
+// SetLayer set config value for key in the layer
+func SetLayer(layer Layer, key string, val interface{}) {
+	_ = defaultConfiguration.SetLayer(layer, key, val)
+}
+
+// OriginOf returns the source which supplies the value of key
+func OriginOf(key string) (Origin, bool) {
+	return defaultConfiguration.OriginOf(key)
+}
+
+// Watch calls fn on changes of key and keys in its subtree with default defaultConfiguration
+func Watch(key string, fn func(ev ChangeEvent)) (cancel func()) {
+	return defaultConfiguration.Watch(key, fn)
+}
+
+// Bind decodes key into ptr and keeps it updated with default defaultConfiguration
+func Bind(key string, ptr interface{}, opts ...BindOption) (*Binding, error) {
+	return defaultConfiguration.Bind(key, ptr, opts...)
+}
+
+// OnRejected calls fn when changes of key rejected with default defaultConfiguration
+func OnRejected(fn func(key string, err error)) {
+	defaultConfiguration.OnRejected(fn)
+}
+
+// History returns versions in history with default defaultConfiguration
+func History() []Version {
+	return defaultConfiguration.History()
+}
+
+// Rollback restores values of all sources to the version with default defaultConfiguration
+func Rollback(id int) error {
+	return defaultConfiguration.Rollback(id)
+}
+
+// SetAutoReject rejects invalid snapshots of data sources with default defaultConfiguration
+func SetAutoReject(reject bool) {
+	defaultConfiguration.SetAutoReject(reject)
+}
+
+// OnVerify registers fn to verify a new snapshot with default defaultConfiguration
+func OnVerify(fn func(*Configuration) error) {
+	defaultConfiguration.OnVerify(fn)
+}
+
+// OnChangeE registers change handler who reports error with default defaultConfiguration
+func OnChangeE(fn func(*Configuration) error) {
+	defaultConfiguration.OnChangeE(fn)
+}
@@ -0,0 +1,230 @@
+package conf
+
+import (
+	"log"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidBinding defines an error that the value to bind is not a non-nil pointer
+var ErrInvalidBinding = errors.New("bind value must be a non-nil pointer")
+
+// Validator is implemented by config structs who validate themselves after decoded
+type Validator interface {
+	Validate() error
+}
+
+type bindOptions struct {
+	getOptions []GetOption
+	validates  []func(interface{}) error
+	onChanges  []func(old, new interface{})
+}
+
+// BindOption ...
+type BindOption func(*bindOptions)
+
+// WithGetOptions decodes with the GetOption, such as TagName
+func WithGetOptions(opts ...GetOption) BindOption {
+	return func(o *bindOptions) {
+		o.getOptions = append(o.getOptions, opts...)
+	}
+}
+
+// WithValidate validates the decoded value, the value is rejected if fn returns error
+func WithValidate(fn func(v interface{}) error) BindOption {
+	return func(o *bindOptions) {
+		o.validates = append(o.validates, fn)
+	}
+}
+
+// WithOnChange calls fn when the decoded value changed
+func WithOnChange(fn func(old, new interface{})) BindOption {
+	return func(o *bindOptions) {
+		o.onChanges = append(o.onChanges, fn)
+	}
+}
+
+// Binding keeps the latest good value of a config key decoded into a struct
+type Binding struct {
+	c    *Configuration
+	key  string
+	opts bindOptions
+	// defaults copy of the value passed to Bind, every decoding starts from it
+	defaults reflect.Value
+	value    atomic.Value
+
+	mu     sync.Mutex
+	cancel func()
+	// rejected raw value last rejected, the same value is rejected only once
+	rejected interface{}
+}
+
+// Bind decodes key into ptr, and keeps decoding on changes of key in background.
+// Values of ptr are used as defaults of every decoding, the decoded value is validated
+// by Validator and WithValidate. A value failed to decode or validate is rejected,
+// the previous one is kept and handlers registered by OnRejected are called.
+func (c *Configuration) Bind(key string, ptr interface{}, opts ...BindOption) (*Binding, error) {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, ErrInvalidBinding
+	}
+
+	b := &Binding{c: c, key: key, defaults: deepCopy(rv.Elem())}
+	for _, opt := range opts {
+		opt(&b.opts)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cancel = c.Watch(key, func(ChangeEvent) { b.reload() })
+	val, err := b.decode()
+	if err != nil {
+		b.cancel()
+		return nil, err
+	}
+	// ptr doesn't share maps or slices with the snapshot
+	rv.Elem().Set(deepCopy(reflect.ValueOf(val).Elem()))
+	b.value.Store(val)
+	return b, nil
+}
+
+// Load returns the latest good value, a pointer of the type passed to Bind.
+// The value is shared, should not be modified.
+func (b *Binding) Load() interface{} {
+	return b.value.Load()
+}
+
+// OnChange calls fn when the decoded value changed
+func (b *Binding) OnChange(fn func(old, new interface{})) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.opts.onChanges = append(b.opts.onChanges, fn)
+}
+
+// Close stops watching changes
+func (b *Binding) Close() {
+	b.cancel()
+}
+
+func (b *Binding) decode() (interface{}, error) {
+	// maps and slices are decoded in place, every decoding starts from a deep copy,
+	// so that the defaults and the snapshot are not modified by rejected values.
+	ptr := reflect.New(b.defaults.Type())
+	ptr.Elem().Set(deepCopy(b.defaults))
+	val := ptr.Interface()
+
+	// missing key keeps the defaults
+	if err := b.c.UnmarshalKey(b.key, val, b.opts.getOptions...); err != nil && !errors.Is(err, ErrInvalidKey) {
+		return nil, err
+	}
+	if v, ok := val.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, validate := range b.opts.validates {
+		if err := validate(val); err != nil {
+			return nil, err
+		}
+	}
+	return val, nil
+}
+
+func (b *Binding) reload() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	raw := b.c.Get(b.key)
+	val, err := b.decode()
+	if err != nil {
+		if b.rejected == nil || !reflect.DeepEqual(b.rejected, raw) {
+			b.rejected = raw
+			log.Printf("reject config %s: %v", b.key, err)
+			b.c.reject(b.key, err)
+		}
+		return
+	}
+	b.rejected = nil
+	old := b.value.Load()
+	if reflect.DeepEqual(old, val) {
+		return
+	}
+	b.value.Store(val)
+	for _, fn := range b.opts.onChanges {
+		fn(old, val)
+	}
+}
+
+// deepCopy returns a copy of v, which shares no maps, slices or pointers with v.
+// Unexported fields of structs are copied shallowly.
+func deepCopy(v reflect.Value) reflect.Value {
+	dst := reflect.New(v.Type()).Elem()
+	copyValue(dst, v)
+	return dst
+}
+
+func copyValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.New(src.Type().Elem()))
+		copyValue(dst.Elem(), src.Elem())
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(deepCopy(src.Elem()))
+	case reflect.Struct:
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				copyValue(dst.Field(i), src.Field(i))
+			}
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		iter := src.MapRange()
+		for iter.Next() {
+			dst.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			copyValue(dst.Index(i), src.Index(i))
+		}
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			copyValue(dst.Index(i), src.Index(i))
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+// OnRejected calls fn when changes of key rejected, such as failed to decode or validate.
+// key is the source name if a snapshot of data source is rejected, see SetAutoReject.
+func (c *Configuration) OnRejected(fn func(key string, err error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onRejecteds = append(c.onRejecteds, fn)
+}
+
+func (c *Configuration) reject(key string, err error) {
+	c.mu.RLock()
+	fns := c.onRejecteds
+	c.mu.RUnlock()
+	for _, fn := range fns {
+		fn(key, err)
+	}
+}
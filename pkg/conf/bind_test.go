@@ -0,0 +1,106 @@
+package conf_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/5idu/pilot/pkg/conf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type limitConfig struct {
+	Rate    int
+	Burst   int
+	Timeout time.Duration
+}
+
+func (c *limitConfig) Validate() error {
+	if c.Rate < 0 {
+		return errors.New("rate should not be negative")
+	}
+	return nil
+}
+
+func TestBind(t *testing.T) {
+	cfg := conf.New()
+	assert.Nil(t, cfg.Set("limit.rate", 10))
+
+	var (
+		changes  int32
+		rejected int32
+	)
+	cfg.OnRejected(func(key string, err error) {
+		assert.Equal(t, "limit", key)
+		atomic.AddInt32(&rejected, 1)
+	})
+	b, err := cfg.Bind("limit", &limitConfig{Burst: 5}, conf.WithOnChange(func(old, new interface{}) {
+		assert.Equal(t, 10, old.(*limitConfig).Rate)
+		assert.Equal(t, 20, new.(*limitConfig).Rate)
+		atomic.AddInt32(&changes, 1)
+	}))
+	assert.Nil(t, err)
+	defer b.Close()
+	assert.Equal(t, &limitConfig{Rate: 10, Burst: 5}, b.Load())
+
+	// value not changed after decoded
+	assert.Nil(t, cfg.Set("limit.unknown", 1))
+	// rejected by Validate
+	assert.Nil(t, cfg.Set("limit.rate", -1))
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&rejected) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 10, b.Load().(*limitConfig).Rate)
+
+	assert.Nil(t, cfg.Set("limit.rate", 20))
+	assert.Nil(t, cfg.Set("limit.timeout", "1s"))
+	assert.Eventually(t, func() bool { return b.Load().(*limitConfig).Timeout == time.Second }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, &limitConfig{Rate: 20, Burst: 5, Timeout: time.Second}, b.Load())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&changes))
+
+	_, err = cfg.Bind("limit", limitConfig{})
+	assert.True(t, errors.Is(err, conf.ErrInvalidBinding))
+}
+
+type labelConfig struct {
+	Rate   int
+	Labels map[string]string
+	Hosts  []string
+}
+
+func (c *labelConfig) Validate() error {
+	if c.Rate < 0 {
+		return errors.New("rate should not be negative")
+	}
+	return nil
+}
+
+func TestBindDeepCopy(t *testing.T) {
+	cfg := conf.New()
+	assert.Nil(t, cfg.Set("label.rate", 1))
+
+	defaults := &labelConfig{Labels: map[string]string{"zone": "a"}, Hosts: []string{"h1"}}
+	b, err := cfg.Bind("label", defaults)
+	assert.Nil(t, err)
+	defer b.Close()
+	snapshot := b.Load().(*labelConfig)
+	assert.Equal(t, &labelConfig{Rate: 1, Labels: map[string]string{"zone": "a"}, Hosts: []string{"h1"}}, snapshot)
+
+	// modifying the value passed to Bind doesn't change the snapshot
+	defaults.Labels["zone"] = "x"
+	assert.Equal(t, "a", snapshot.Labels["zone"])
+
+	// rejected value doesn't change the snapshot
+	var rejected int32
+	cfg.OnRejected(func(string, error) { atomic.AddInt32(&rejected, 1) })
+	assert.Nil(t, cfg.Set("label", map[string]interface{}{"rate": -1, "labels": map[string]interface{}{"env": "prod"}}))
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&rejected) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Same(t, snapshot, b.Load())
+	assert.Equal(t, map[string]string{"zone": "a"}, snapshot.Labels)
+
+	// the change of map is detected
+	assert.Nil(t, cfg.Set("label", map[string]interface{}{"rate": 1, "labels": map[string]interface{}{"env": "test"}}))
+	assert.Eventually(t, func() bool { return b.Load().(*labelConfig).Labels["env"] == "test" }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string]string{"zone": "a", "env": "test"}, b.Load().(*labelConfig).Labels)
+	assert.Equal(t, map[string]string{"zone": "a"}, snapshot.Labels)
+}
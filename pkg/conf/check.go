@@ -0,0 +1,265 @@
+package conf
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+var (
+	// ErrUnknownKey defines an error that the key is not decoded into any field
+	ErrUnknownKey = errors.New("unknown key")
+	// ErrInvalidValue defines an error that the value failed to validate
+	ErrInvalidValue = errors.New("invalid value")
+)
+
+// FieldError describes an invalid config key
+type FieldError struct {
+	Key    string
+	Reason string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Key, e.Reason)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// CheckError collects all errors found by Check
+type CheckError struct {
+	Errors []error
+}
+
+func (e *CheckError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d error(s) in config:", len(e.Errors))
+	for _, err := range e.Errors {
+		sb.WriteString("\n\t- ")
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+type configSchema struct {
+	key  func() string
+	typ  reflect.Type
+	val  reflect.Value
+	opts []GetOption
+}
+
+var (
+	schemaMu sync.RWMutex
+	schemas  []configSchema
+)
+
+// RegisterSchema registers the struct of config key to be checked by Check.
+// A segment of key could be `*` to match any name, such as `pilot.redis.*`.
+// Values of schema are used as defaults of decoding, a key registered again replaces the former.
+func RegisterSchema(key string, schema interface{}, opts ...GetOption) {
+	RegisterSchemaFunc(func() string { return key }, schema, opts...)
+}
+
+// RegisterSchemaFunc is the same as RegisterSchema, but key is resolved when Check runs,
+// so that keys depending on flags or environments, such as constant.ConfigKey, are up to date.
+func RegisterSchemaFunc(key func() string, schema interface{}, opts ...GetOption) {
+	rv := reflect.Indirect(reflect.ValueOf(schema))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("config schema of %s must be a struct, got %T", key(), schema))
+	}
+	schemaMu.Lock()
+	defer schemaMu.Unlock()
+	schemas = append(schemas, configSchema{key: key, typ: rv.Type(), val: rv, opts: opts})
+}
+
+// resolveSchemas returns schemas by key resolved, the last registered of a key wins
+func resolveSchemas() ([]string, map[string]configSchema) {
+	schemaMu.RLock()
+	defer schemaMu.RUnlock()
+
+	var keys []string
+	items := make(map[string]configSchema, len(schemas))
+	for _, item := range schemas {
+		key := item.key()
+		if _, ok := items[key]; !ok {
+			keys = append(keys, key)
+		}
+		items[key] = item
+	}
+	return keys, items
+}
+
+// Check decodes all keys matched by registered schemas with default defaultConfiguration, see Configuration.Check.
+func Check() error {
+	return defaultConfiguration.Check()
+}
+
+// Check decodes all keys matched by registered schemas, validates them with `validate` tags
+// and Validator, and reports unknown keys. All errors are collected into a CheckError.
+func (c *Configuration) Check() error {
+	patterns, items := resolveSchemas()
+
+	var errs []error
+	for _, pattern := range patterns {
+		for _, key := range c.expandKey(pattern) {
+			errs = append(errs, multierr.Errors(c.checkKey(key, items[pattern]))...)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &CheckError{Errors: errs}
+}
+
+func (c *Configuration) checkKey(key string, item configSchema) error {
+	ptr := reflect.New(item.typ)
+	ptr.Elem().Set(item.val)
+	val := ptr.Interface()
+
+	opts := append([]GetOption{ValidateStruct(), ErrorUnknownKeys()}, item.opts...)
+	if err := c.UnmarshalKey(key, val, opts...); err != nil {
+		// failed to decode
+		if !isFieldError(err) {
+			return errors.Wrap(err, key)
+		}
+		return err
+	}
+	if v, ok := val.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return &FieldError{Key: key, Reason: err.Error(), Err: ErrInvalidValue}
+		}
+	}
+	return nil
+}
+
+func isFieldError(err error) bool {
+	var fe *FieldError
+	return errors.As(err, &fe)
+}
+
+// expandKey returns existing keys matched by pattern, `*` matches any segment
+func (c *Configuration) expandKey(pattern string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var keys []string
+	var walk func(prefix []string, paths []string, val interface{})
+	walk = func(prefix []string, paths []string, val interface{}) {
+		if len(paths) == 0 {
+			keys = append(keys, strings.Join(prefix, c.keyDelim))
+			return
+		}
+		m, ok := toStringMap(val)
+		if !ok {
+			return
+		}
+		if paths[0] != "*" {
+			if next, ok := m[paths[0]]; ok {
+				walk(append(prefix, paths[0]), paths[1:], next)
+			}
+			return
+		}
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			walk(append(prefix[:len(prefix):len(prefix)], name), paths[1:], m[name])
+		}
+	}
+	walk(nil, strings.Split(pattern, c.keyDelim), c.override)
+	return keys
+}
+
+// unknownKeys reports keys not decoded into any field
+func unknownKeys(key, delim string, unused []string) error {
+	sort.Strings(unused)
+	var errs error
+	for _, name := range unused {
+		errs = multierr.Append(errs, &FieldError{Key: joinKey(key, delim, name), Reason: "unknown key", Err: ErrUnknownKey})
+	}
+	return errs
+}
+
+// validators validators by tag name, field names in errors are the same as decoding
+var validators sync.Map
+
+// validateStruct validates val with `validate` tags, field names are reported in config keys
+func validateStruct(key, delim, tagName string, val interface{}) error {
+	v, ok := validators.Load(tagName)
+	if !ok {
+		v, _ = validators.LoadOrStore(tagName, newValidator(tagName))
+	}
+	err := v.(*validator.Validate).Struct(val)
+	if err == nil {
+		return nil
+	}
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		// not a struct, nothing to validate
+		return nil
+	}
+	var errs error
+	for _, fe := range fieldErrors {
+		// namespace starts with the struct name
+		name := fe.Namespace()
+		if i := strings.Index(name, "."); i >= 0 {
+			name = name[i+1:]
+		}
+		reason := fmt.Sprintf("failed on '%s' tag", fe.Tag())
+		if fe.Param() != "" {
+			reason = fmt.Sprintf("failed on '%s=%s' tag", fe.Tag(), fe.Param())
+		}
+		reason += fmt.Sprintf(", got %v", fe.Value())
+		errs = multierr.Append(errs, &FieldError{Key: joinKey(key, delim, strings.ReplaceAll(name, ".", delim)), Reason: reason, Err: ErrInvalidValue})
+	}
+	return errs
+}
+
+func newValidator(tagName string) *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{tagName, "json"} {
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if name == "-" {
+				return "-"
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+	_ = v.RegisterValidation("oneofci", oneOfFold)
+	return v
+}
+
+// oneOfFold is `oneof` ignoring case of strings, such as `validate:"oneofci=json text"` accepts JSON
+func oneOfFold(fl validator.FieldLevel) bool {
+	field := fl.Field()
+	if field.Kind() != reflect.String {
+		return false
+	}
+	for _, option := range strings.Fields(fl.Param()) {
+		if strings.EqualFold(field.String(), option) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinKey(key, delim, name string) string {
+	if key == "" {
+		return name
+	}
+	return key + delim + name
+}
@@ -0,0 +1,96 @@
+package conf_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/5idu/pilot/pkg/conf"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+)
+
+type poolConfig struct {
+	Addr     string `json:"addr" validate:"required"`
+	PoolSize int    `json:"poolSize" validate:"gte=1"`
+	Nested   struct {
+		Timeout int `json:"timeout"`
+	} `json:"nested"`
+}
+
+func TestUnmarshalKeyCheck(t *testing.T) {
+	cfg := conf.New()
+	assert.Nil(t, cfg.LoadFromReader(bytes.NewBufferString(`
+[pool]
+addr = "127.0.0.1"
+pool_size = 0
+[pool.nested]
+timeout = 1
+tmieout = 2
+`), toml.Unmarshal))
+
+	var config poolConfig
+	// unknown keys and tags are ignored by default
+	assert.Nil(t, cfg.UnmarshalKey("pool", &config, conf.TagNameJSON()))
+
+	err := cfg.UnmarshalKey("pool", &config, conf.TagNameJSON(), conf.ValidateStruct(), conf.ErrorUnknownKeys())
+	assert.True(t, errors.Is(err, conf.ErrUnknownKey))
+	assert.True(t, errors.Is(err, conf.ErrInvalidValue))
+	assert.Contains(t, err.Error(), "pool.nested.tmieout: unknown key")
+	assert.Contains(t, err.Error(), "pool.pool_size: unknown key")
+	assert.Contains(t, err.Error(), "pool.poolSize: failed on 'gte=1' tag, got 0")
+}
+
+func TestCheck(t *testing.T) {
+	conf.RestoreSchemas(t)
+	conf.RegisterSchema("check.pool.*", poolConfig{PoolSize: 10}, conf.TagNameJSON())
+
+	cfg := conf.New()
+	assert.Nil(t, cfg.LoadFromReader(bytes.NewBufferString(`
+[check.pool.a]
+addr = "127.0.0.1"
+[check.pool.b]
+poolSize = "many"
+[check.pool.c]
+poolSize = -1
+unknown = 1
+`), toml.Unmarshal))
+
+	err := cfg.Check()
+	var report *conf.CheckError
+	assert.True(t, errors.As(err, &report))
+	assert.Len(t, report.Errors, 4)
+	assert.Contains(t, report.Errors[0].Error(), "check.pool.b")
+	assert.Equal(t, "check.pool.c.unknown: unknown key", report.Errors[1].Error())
+	assert.Equal(t, "check.pool.c.addr: failed on 'required' tag, got ", report.Errors[2].Error())
+	assert.Equal(t, "check.pool.c.poolSize: failed on 'gte=1' tag, got -1", report.Errors[3].Error())
+	assert.Contains(t, err.Error(), "4 error(s) in config:")
+}
+
+type formatConfig struct {
+	Format string `json:"format" validate:"oneofci=json text"`
+}
+
+func TestCheckSchemaFunc(t *testing.T) {
+	conf.RestoreSchemas(t)
+	prefix := "before"
+	conf.RegisterSchemaFunc(func() string { return prefix + ".format.*" }, formatConfig{}, conf.TagNameJSON())
+
+	cfg := conf.New()
+	assert.Nil(t, cfg.LoadFromReader(bytes.NewBufferString(`
+[after.format.a]
+format = "JSON"
+[after.format.b]
+format = "xml"
+`), toml.Unmarshal))
+	assert.Nil(t, cfg.Check())
+
+	// key is resolved when Check runs
+	prefix = "after"
+	err := cfg.Check()
+	var report *conf.CheckError
+	assert.True(t, errors.As(err, &report))
+	assert.Len(t, report.Errors, 1)
+	assert.Equal(t, "after.format.b.format: failed on 'oneofci=json text' tag, got xml", report.Errors[0].Error())
+}
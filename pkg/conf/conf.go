@@ -5,30 +5,49 @@ import (
 	"io"
 	"log"
 	"os"
-	"reflect"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/5idu/pilot/pkg/util/xcast"
+	"github.com/5idu/pilot/pkg/util/xcrypto"
 	"github.com/5idu/pilot/pkg/util/xmap"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	"github.com/spf13/cast"
+	"go.uber.org/multierr"
 )
 
 // Configuration provides configuration for application.
+// Values are merged from layered sources, see Layer.
 type Configuration struct {
-	mu       sync.RWMutex
+	mu sync.RWMutex
+	// override merged values of all sources
 	override map[string]interface{}
 	keyDelim string
+	sources  []*source
+	// flat flattened override after interpolated and decrypted, used to detect changes
+	flat map[string]interface{}
+	// rawFlat flattened override, changes of raw values are kept in history, which never contain decrypted secrets
+	rawFlat map[string]interface{}
+	// readers count of sources loaded by Load
+	readers   int
+	decrypter Decrypter
 
 	keyMap    *sync.Map
 	onChanges []func(*Configuration)
 	onLoadeds []func(*Configuration)
 
-	watchers map[string][]func(*Configuration)
+	watchers    []*watcher
+	onRejecteds []func(key string, err error)
+
+	history     []*Version
+	historySize int
+	versionID   int
+	autoReject  bool
+	verifiers   []func(*Configuration) error
+	onChangeEs  []func(*Configuration) error
 	// TODO: concurrency protect
 	loaded bool
 }
@@ -40,13 +59,15 @@ const (
 // New constructs a new Configuration with provider.
 func New() *Configuration {
 	return &Configuration{
-		override:  make(map[string]interface{}),
-		keyDelim:  defaultKeyDelim,
-		keyMap:    &sync.Map{},
-		onChanges: make([]func(*Configuration), 0),
-		onLoadeds: make([]func(*Configuration), 0),
-		watchers:  make(map[string][]func(*Configuration)),
-		loaded:    false,
+		override:    make(map[string]interface{}),
+		flat:        make(map[string]interface{}),
+		rawFlat:     make(map[string]interface{}),
+		keyDelim:    defaultKeyDelim,
+		keyMap:      &sync.Map{},
+		onChanges:   make([]func(*Configuration), 0),
+		onLoadeds:   make([]func(*Configuration), 0),
+		loaded:      false,
+		historySize: defaultHistorySize,
 	}
 }
 
@@ -57,20 +78,86 @@ func (c *Configuration) SetKeyDelim(delim string) {
 
 // Sub returns new Configuration instance representing a sub tree of this instance.
 func (c *Configuration) Sub(key string) *Configuration {
-	return &Configuration{
-		keyDelim: c.keyDelim,
-		override: c.GetStringMap(key),
-	}
+	sub := New()
+	sub.keyDelim = c.keyDelim
+	_ = sub.apply(c.GetStringMap(key))
+	return sub
 }
 
-// WriteConfig ...
+// WriteConfig persists values set by Set into the writable data source with highest precedence,
+// see WriteConfigTo.
 func (c *Configuration) WriteConfig() error {
-	// return c.provider.Write(c.override)
+	c.mu.RLock()
+	var name string
+	for _, s := range c.sources {
+		if _, ok := s.ds.(WritableDataSource); ok {
+			name = s.name
+		}
+	}
+	c.mu.RUnlock()
+	if name == "" {
+		return ErrNotWritable
+	}
+	return c.WriteConfigTo(name)
+}
+
+// WriteConfigTo persists values set by Set into the writable data source named name,
+// the values are moved from LayerOverride into the data source once written.
+// Returns ErrConfigConflict if the data source has been changed by others since last read,
+// in which case Set values are kept and could be written again after reloaded.
+func (c *Configuration) WriteConfigTo(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var target *source
+	for _, s := range c.sources {
+		if s.name == name {
+			target = s
+		}
+	}
+	if target == nil {
+		return errors.Wrap(ErrNotWritable, name)
+	}
+	ds, ok := target.ds.(WritableDataSource)
+	if !ok {
+		return errors.Wrap(ErrNotWritable, name)
+	}
+	marshal, err := GetEncoder(ds.Format())
+	if err != nil {
+		return err
+	}
+
+	// raw values are written, placeholders and encrypted values are kept as they are
+	data := make(map[string]interface{})
+	MergeMap(data, target.data)
+	override := c.getSource(LayerOverride, LayerOverride.String())
+	MergeMap(data, override.data)
+	content, err := marshal(data)
+	if err != nil {
+		return err
+	}
+	if err := ds.WriteConfig(content); err != nil {
+		return err
+	}
+
+	target.data = data
+	override.data = make(map[string]interface{})
+	c.rebuild()
 	return nil
 }
 
+// SetDecrypter sets the Decrypter of encrypted values, such as enc:v1:<base64>
+func (c *Configuration) SetDecrypter(decrypter Decrypter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.decrypter = decrypter
+	c.rebuild()
+}
+
 // OnChange 注册change回调函数
 func (c *Configuration) OnChange(fn func(*Configuration)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.onChanges = append(c.onChanges, fn)
 }
 
@@ -83,36 +170,62 @@ func (c *Configuration) OnLoaded(fn func(*Configuration)) {
 	c.onLoadeds = append(c.onLoadeds, fn)
 }
 
-// LoadEnvironments reads os environments with prefix such as APP_
+// LoadEnvironments reads os environments with prefix such as APP_ into LayerEnv
 // PREFIX_FIELD1_FIELD2 will be translated into prefix.field1.field2
 func (c *Configuration) LoadEnvironments(prefix string) {
+	data := make(map[string]interface{})
 	for _, env := range os.Environ() {
 		if !strings.HasPrefix(env, prefix) {
 			continue
 		}
-		key := strings.ToLower(strings.ReplaceAll(env, "_", "."))
-		val := os.Getenv(env)
-		_ = c.Set(key, val)
+		name, val, _ := strings.Cut(env, "=")
+		// keys to decrypt config should never be exposed as config
+		if name == EnvConfigKeys {
+			continue
+		}
+		key := strings.ToLower(strings.ReplaceAll(name, "_", c.keyDelim))
+		setNested(data, strings.Split(key, c.keyDelim), val)
 	}
+	c.setSource(LayerEnv, "env:"+prefix, data)
 }
 
-// LoadFromDataSource ...
+// LoadFromDataSource loads config into the layer declared by LayeredDataSource, LayerRemote by default.
+// if unmarshaller is nil, the decoder of format declared by FormatDataSource will be used.
 func (c *Configuration) LoadFromDataSource(ds DataSource, unmarshaller Unmarshaller) error {
+	return c.LoadLayerFromDataSource(layerOf(ds), sourceName(ds), ds, unmarshaller)
+}
+
+// LoadLayerFromDataSource loads config into the layer as source named name,
+// the source will be replaced as a whole when data source changed.
+func (c *Configuration) LoadLayerFromDataSource(layer Layer, name string, ds DataSource, unmarshaller Unmarshaller) error {
 	content, err := ds.ReadConfig()
 	if err != nil {
 		return err
 	}
 
-	if err := c.Load(content, unmarshaller); err != nil {
+	unmarshal, err := resolveDecoder(ds, unmarshaller)
+	if err != nil {
 		return err
 	}
+	if err := c.load(layer, name, revisionOf(ds, content), content, unmarshal); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.getSource(layer, name).ds = ds
+	c.mu.Unlock()
+
 	if ds.IsConfigChanged() != nil {
 		go func() {
 			for range ds.IsConfigChanged() {
 				if content, err := ds.ReadConfig(); err == nil {
-					_ = c.reflush(content, unmarshaller)
-					for _, change := range c.onChanges {
-						change(c)
+					// format may be changed with content, such as etcd metadata.format
+					unmarshal, err := resolveDecoder(ds, unmarshaller)
+					if err != nil {
+						log.Printf("resolve config decoder failed: %v", err)
+						continue
+					}
+					if err := c.reflush(layer, name, revisionOf(ds, content), content, unmarshal, true); err != nil {
+						log.Printf("reload config from %s failed: %v", name, err)
 					}
 				}
 			}
@@ -122,21 +235,25 @@ func (c *Configuration) LoadFromDataSource(ds DataSource, unmarshaller Unmarshal
 	return nil
 }
 
-func (c *Configuration) reflush(content []byte, unmarshal Unmarshaller) error {
+func (c *Configuration) reflush(layer Layer, name, revision string, content []byte, unmarshal Unmarshaller, reload bool) error {
 	configuration := make(map[string]interface{})
 	if err := unmarshal(content, &configuration); err != nil {
 		return err
 	}
-	if err := c.apply(configuration); err != nil {
-		return err
-	}
-
-	return nil
+	return c.applySource(layer, name, revision, configuration, reload)
 }
 
-// Load ...
+// Load loads content into LayerFile as a new source
 func (c *Configuration) Load(content []byte, unmarshal Unmarshaller) error {
-	if err := c.reflush(content, unmarshal); err != nil {
+	c.mu.Lock()
+	c.readers++
+	name := fmt.Sprintf("reader#%d", c.readers)
+	c.mu.Unlock()
+	return c.load(LayerFile, name, xcrypto.Md5(string(content)), content, unmarshal)
+}
+
+func (c *Configuration) load(layer Layer, name, revision string, content []byte, unmarshal Unmarshaller) error {
+	if err := c.reflush(layer, name, revision, content, unmarshal, false); err != nil {
 		return err
 	}
 
@@ -157,55 +274,18 @@ func (c *Configuration) LoadFromReader(reader io.Reader, unmarshaller Unmarshall
 	return c.Load(content, unmarshaller)
 }
 
+// apply merges conf into LayerOverride
 func (c *Configuration) apply(conf map[string]interface{}) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	var changes = make(map[string]interface{})
-
-	xmap.MergeStringMap(c.override, conf)
-	for k, v := range c.traverse(c.keyDelim) {
-		orig, ok := c.keyMap.Load(k)
-		if ok && !reflect.DeepEqual(orig, v) {
-			changes[k] = v
-		}
-		c.keyMap.Store(k, v)
-	}
-
-	if len(changes) > 0 {
-		c.notifyChanges(changes)
-	}
-
+	MergeMap(c.getSource(LayerOverride, LayerOverride.String()).data, conf)
+	c.rebuild()
 	return nil
 }
 
-func (c *Configuration) notifyChanges(changes map[string]interface{}) {
-	var changedWatchPrefixMap = map[string]struct{}{}
-
-	for watchPrefix := range c.watchers {
-		for key := range changes {
-			// 前缀匹配即可
-			// todo 可能产生错误匹配
-			if strings.HasPrefix(key, watchPrefix) {
-				changedWatchPrefixMap[watchPrefix] = struct{}{}
-			}
-		}
-	}
-
-	for changedWatchPrefix := range changedWatchPrefixMap {
-		for _, handle := range c.watchers[changedWatchPrefix] {
-			go handle(c)
-		}
-	}
-}
-
-// Set ...
+// Set sets config value for key in LayerOverride
 func (c *Configuration) Set(key string, val interface{}) error {
-	paths := strings.Split(key, c.keyDelim)
-	lastKey := paths[len(paths)-1]
-	m := deepSearch(c.override, paths[:len(paths)-1])
-	m[lastKey] = val
-	return c.apply(m)
-	// c.keyMap.Store(key, val)
+	return c.SetLayer(LayerOverride, key, val)
 }
 
 func deepSearch(m map[string]interface{}, path []string) map[string]interface{} {
@@ -391,28 +471,48 @@ func (c *Configuration) UnmarshalKey(key string, rawVal interface{}, opts ...Get
 		opt(&options)
 	}
 
+	var metadata mapstructure.Metadata
 	config := mapstructure.DecoderConfig{
 		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
 		Result:     rawVal,
 		TagName:    options.TagName,
 		Squash:     true,
+		Metadata:   &metadata,
 	}
 	decoder, err := mapstructure.NewDecoder(&config)
 	if err != nil {
 		return err
 	}
-	if key == "" {
-		c.mu.RLock()
-		defer c.mu.RUnlock()
-		return decoder.Decode(c.override)
+	value, err := c.resolve(key)
+	if err != nil {
+		return err
 	}
-
-	value := c.Get(key)
 	if value == nil {
 		return errors.Wrap(ErrInvalidKey, key)
 	}
 
-	return decoder.Decode(value)
+	if err := decoder.Decode(value); err != nil {
+		return err
+	}
+	var errs error
+	if options.ErrorUnknownKeys {
+		errs = unknownKeys(key, c.keyDelim, metadata.Unused)
+	}
+	if options.Validate {
+		errs = multierr.Append(errs, validateStruct(key, c.keyDelim, options.TagName, rawVal))
+	}
+	return errs
+}
+
+// resolve returns the interpolated value of key, the whole config if key is empty
+func (c *Configuration) resolve(key string) (interface{}, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var value interface{} = c.override
+	if key != "" {
+		value, _ = searchPath(c.override, strings.Split(key, c.keyDelim))
+	}
+	return newInterpolator(c.override, c.keyDelim, c.decrypter).resolveKey(key, value)
 }
 
 func (c *Configuration) find(key string) interface{} {
@@ -426,6 +526,11 @@ func (c *Configuration) find(key string) interface{} {
 	defer c.mu.RUnlock()
 	m := xmap.DeepSearchInMap(c.override, paths[:len(paths)-1]...)
 	dd = m[paths[len(paths)-1]]
+	if resolved, err := newInterpolator(c.override, c.keyDelim, c.decrypter).resolveKey(key, dd); err != nil {
+		log.Printf("interpolate config failed: %v", err)
+	} else {
+		dd = resolved
+	}
 	c.keyMap.Store(key, dd)
 	return dd
 }
@@ -444,6 +549,14 @@ func lookup(prefix string, target map[string]interface{}, data map[string]interf
 	}
 }
 
+// Traverse returns flattened config joined by sep, values are not interpolated or decrypted
+func (c *Configuration) Traverse(sep string) map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.traverse(sep)
+}
+
+// traverse must be called with c.mu held
 func (c *Configuration) traverse(sep string) map[string]interface{} {
 	data := make(map[string]interface{})
 	lookup("", c.override, data, sep)
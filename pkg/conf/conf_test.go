@@ -7,6 +7,7 @@ import (
 
 	"github.com/5idu/pilot/pkg/conf"
 
+	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v3"
 )
 
@@ -35,3 +36,45 @@ func TestConf(t *testing.T) {
 	processErr(t, err)
 	fmt.Println(conf.Get("etcd.endpoints"))
 }
+
+func TestTraverseConcurrently(t *testing.T) {
+	cfg := conf.New()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = cfg.Set("app.rate", i)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_ = cfg.Traverse(".")
+	}
+	<-done
+	assert.Equal(t, 99, cfg.Traverse(".")["app.rate"])
+}
+
+// addrDataSource remembers the address it was created with
+type addrDataSource struct {
+	addr string
+}
+
+func (ds *addrDataSource) ReadConfig() ([]byte, error)      { return nil, nil }
+func (ds *addrDataSource) IsConfigChanged() <-chan struct{} { return nil }
+func (ds *addrDataSource) Close() error                     { return nil }
+
+func TestRegisterDataSource(t *testing.T) {
+	// creators registered without address still work
+	conf.Register("test-legacy", func() conf.DataSource { return &addrDataSource{} })
+	conf.RegisterDataSource("test-addr", func(configAddr string) conf.DataSource { return &addrDataSource{addr: configAddr} })
+
+	ds, err := conf.NewDataSource("test-legacy://127.0.0.1")
+	assert.Nil(t, err)
+	assert.Equal(t, "", ds.(*addrDataSource).addr)
+
+	ds, err = conf.NewDataSource("test-addr://127.0.0.1/config?keys=a,b")
+	assert.Nil(t, err)
+	assert.Equal(t, "test-addr://127.0.0.1/config?keys=a,b", ds.(*addrDataSource).addr)
+
+	_, err = conf.NewDataSource("unknown://127.0.0.1")
+	assert.ErrorIs(t, err, conf.ErrInvalidDataSource)
+}
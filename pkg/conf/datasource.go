@@ -11,13 +11,24 @@ var (
 	ErrConfigAddr = errors.New("no config... ")
 	// ErrInvalidDataSource defines an error that the scheme has been registered
 	ErrInvalidDataSource = errors.New("invalid data source, please make sure the scheme has been registered")
-	datasourceBuilders   = make(map[string]DataSourceCreatorFunc)
-	// configDecoder        = make(map[string]Unmarshaller)
+	// ErrUnsupportedFormat defines an error that no decoder registered for the format
+	ErrUnsupportedFormat = errors.New("unsupported config format")
+	// ErrUnknownFormat defines an error that neither unmarshaller nor format provided
+	ErrUnknownFormat = errors.New("unknown config format, please provide an unmarshaller")
+	// ErrConfigConflict defines an error that config has been changed by others since last read
+	ErrConfigConflict = errors.New("config has been changed since last read")
+	// ErrNotWritable defines an error that no writable data source loaded
+	ErrNotWritable     = errors.New("no writable data source")
+	datasourceBuilders = make(map[string]DataSourceFactory)
 )
 
-// DataSourceCreatorFunc represents a dataSource creator function
+// DataSourceCreatorFunc represents a dataSource creator function,
+// which reads the address from flag config, see Register.
 type DataSourceCreatorFunc func() DataSource
 
+// DataSourceFactory represents a dataSource creator function with config address
+type DataSourceFactory func(configAddr string) DataSource
+
 // DataSource ...
 type DataSource interface {
 	ReadConfig() ([]byte, error)
@@ -25,9 +36,38 @@ type DataSource interface {
 	io.Closer
 }
 
-// Register registers a dataSource creator function to the registry
+// FormatDataSource is implemented by data sources who know the format of their content,
+// such as file extension, etcd metadata.format or nacos dataId.
+type FormatDataSource interface {
+	DataSource
+	// Format returns format name, file extension or MIME type, empty if unknown
+	Format() string
+}
+
+// WritableDataSource is implemented by data sources who could persist config
+type WritableDataSource interface {
+	FormatDataSource
+	// WriteConfig writes content only if config not changed since last read,
+	// returns ErrConfigConflict otherwise.
+	WriteConfig(content []byte) error
+}
+
+// RevisionDataSource is implemented by data sources who know the revision of their content,
+// such as etcd mod revision. MD5 of content is used as revision of other data sources.
+type RevisionDataSource interface {
+	DataSource
+	Revision() string
+}
+
+// Register registers a dataSource creator function to the registry.
+// The creator is unaware of the address, it only works with a single --config, use RegisterDataSource instead.
 func Register(scheme string, creator DataSourceCreatorFunc) {
-	datasourceBuilders[scheme] = creator
+	RegisterDataSource(scheme, func(string) DataSource { return creator() })
+}
+
+// RegisterDataSource registers a dataSource factory to the registry, which creates data source by address
+func RegisterDataSource(scheme string, factory DataSourceFactory) {
+	datasourceBuilders[scheme] = factory
 }
 
 // CreateDataSource creates a dataSource witch has been registered
@@ -58,5 +98,5 @@ func NewDataSource(configAddr string) (DataSource, error) {
 	if !exist {
 		return nil, ErrInvalidDataSource
 	}
-	return creatorFunc(), nil
+	return creatorFunc(configAddr), nil
 }
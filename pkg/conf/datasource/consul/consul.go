@@ -0,0 +1,158 @@
+package consul
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/5idu/pilot/pkg/client/consul"
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/util/xgo"
+	"github.com/5idu/pilot/pkg/xlog"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/pkg/errors"
+)
+
+// retryInterval interval to retry blocking queries after failed
+const retryInterval = 3 * time.Second
+
+type consulDataSource struct {
+	client *consul.Client
+	key    string
+	// format declared by url, falls back to extension of key
+	format string
+	logger *xlog.Logger
+
+	mu sync.Mutex
+	// modifyIndex of the key last read or written, for compare-and-swap
+	modifyIndex uint64
+
+	changed chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewDataSource creates a data source of consul kv key, changes are watched by blocking queries.
+// client is the consul client, it must be useful and should be release by User.
+func NewDataSource(client *consul.Client, key string, watch bool) conf.DataSource {
+	ds := &consulDataSource{
+		client: client,
+		key:    key,
+		logger: xlog.With(xlog.String("mod", "consul datasource")),
+	}
+	ds.ctx, ds.cancel = context.WithCancel(context.Background())
+	if watch {
+		ds.changed = make(chan struct{}, 1)
+		xgo.Go(ds.watch)
+	}
+	return ds
+}
+
+// ReadConfig ...
+func (ds *consulDataSource) ReadConfig() ([]byte, error) {
+	pair, _, err := ds.client.KV().Get(ds.key, (&api.QueryOptions{}).WithContext(ds.ctx))
+	if err != nil {
+		return nil, err
+	}
+	if pair == nil {
+		return nil, errors.Errorf("consul key not found: %s", ds.key)
+	}
+	ds.mu.Lock()
+	ds.modifyIndex = pair.ModifyIndex
+	ds.mu.Unlock()
+	return pair.Value, nil
+}
+
+// WriteConfig writes content if the key not modified since last read
+func (ds *consulDataSource) WriteConfig(content []byte) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	pair := &api.KVPair{Key: ds.key, Value: content, ModifyIndex: ds.modifyIndex}
+	ok, _, err := ds.client.KV().CAS(pair, (&api.WriteOptions{}).WithContext(ds.ctx))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return conf.ErrConfigConflict
+	}
+	// index of the write is unknown, read again for the next compare-and-swap
+	latest, _, err := ds.client.KV().Get(ds.key, (&api.QueryOptions{}).WithContext(ds.ctx))
+	if err != nil {
+		return err
+	}
+	if latest != nil {
+		ds.modifyIndex = latest.ModifyIndex
+	}
+	return nil
+}
+
+// Format returns format declared by url, falls back to extension of key
+func (ds *consulDataSource) Format() string {
+	if ds.format != "" {
+		return ds.format
+	}
+	return path.Ext(ds.key)
+}
+
+// Revision returns modify index of the key last read or written
+func (ds *consulDataSource) Revision() string {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return strconv.FormatUint(ds.modifyIndex, 10)
+}
+
+// IsConfigChanged ...
+func (ds *consulDataSource) IsConfigChanged() <-chan struct{} {
+	return ds.changed
+}
+
+// Close stops watching
+func (ds *consulDataSource) Close() error {
+	ds.cancel()
+	return nil
+}
+
+// watch notifies once the key modified, by blocking queries
+func (ds *consulDataSource) watch() {
+	defer close(ds.changed)
+	var waitIndex uint64
+	for {
+		pair, meta, err := ds.client.KV().Get(ds.key, (&api.QueryOptions{WaitIndex: waitIndex}).WithContext(ds.ctx))
+		if ds.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			ds.logger.Error("watch key", xlog.String("key", ds.key), xlog.FieldErr(err))
+			select {
+			case <-ds.ctx.Done():
+				return
+			case <-time.After(retryInterval):
+			}
+			continue
+		}
+		// index goes backwards if consul restored from snapshot, start over
+		if meta.LastIndex < waitIndex {
+			waitIndex = 0
+		} else {
+			waitIndex = meta.LastIndex
+		}
+		if pair == nil {
+			ds.logger.Warn("watch key deleted", xlog.String("key", ds.key))
+			continue
+		}
+
+		ds.mu.Lock()
+		changed := ds.modifyIndex != 0 && pair.ModifyIndex != ds.modifyIndex
+		ds.mu.Unlock()
+		if changed {
+			select {
+			case ds.changed <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package consul
+
+import (
+	"testing"
+	"time"
+
+	"github.com/5idu/pilot/pkg/client/consul/consultest"
+	"github.com/5idu/pilot/pkg/conf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsulDataSource(t *testing.T) {
+	srv := consultest.NewServer()
+	defer srv.Close()
+	srv.Put("app/config.yaml", []byte("a: 1"))
+
+	ds := NewDataSource(srv.Config().MustBuild(), "app/config.yaml", true)
+	defer ds.Close()
+
+	content, err := ds.ReadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "a: 1", string(content))
+	assert.Equal(t, ".yaml", ds.(conf.FormatDataSource).Format())
+	revision := ds.(conf.RevisionDataSource).Revision()
+	assert.NotEqual(t, "0", revision)
+
+	// changed by others
+	srv.Put("app/config.yaml", []byte("a: 2"))
+	select {
+	case <-ds.IsConfigChanged():
+	case <-time.After(3 * time.Second):
+		t.Fatal("change not notified")
+	}
+	content, err = ds.ReadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "a: 2", string(content))
+	assert.NotEqual(t, revision, ds.(conf.RevisionDataSource).Revision())
+
+	// compare-and-swap by modify index last read
+	writable := ds.(conf.WritableDataSource)
+	assert.Nil(t, writable.WriteConfig([]byte("a: 3")))
+	srv.Put("app/config.yaml", []byte("a: 4"))
+	assert.ErrorIs(t, writable.WriteConfig([]byte("a: 5")), conf.ErrConfigConflict)
+
+	_, err = NewDataSource(srv.Config().MustBuild(), "app/missing.yaml", false).ReadConfig()
+	assert.NotNil(t, err)
+}
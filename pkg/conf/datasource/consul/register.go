@@ -0,0 +1,43 @@
+package consul
+
+import (
+	"github.com/5idu/pilot/pkg/client/consul"
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/flag"
+	"github.com/5idu/pilot/pkg/util/xnet"
+	"github.com/5idu/pilot/pkg/xlog"
+)
+
+// DataSourceConsul defines consul scheme
+const DataSourceConsul = "consul"
+
+func init() {
+	conf.RegisterDataSource(DataSourceConsul, func(configAddr string) conf.DataSource {
+		var (
+			watch = flag.Bool("watch")
+		)
+		if configAddr == "" {
+			xlog.Panic("new consul dataSource, configAddr is empty")
+			return nil
+		}
+		// configAddr is a string in this format:
+		// consul://ip:port?key=XXX&token=XXX&dc=XXX&scheme=https&caFile=XXX&username=XXX&password=XXX&wait=5m&format=XXX
+		urlObj, err := xnet.ParseURL(configAddr)
+		if err != nil {
+			xlog.Panic("parse configAddr error", xlog.Any("error", err))
+			return nil
+		}
+		consulConf := consul.DefaultConfig()
+		consulConf.Address = urlObj.Host
+		consulConf.Scheme = urlObj.QueryString("scheme", consulConf.Scheme)
+		consulConf.Datacenter = urlObj.Query().Get("dc")
+		consulConf.Token = urlObj.Query().Get("token")
+		consulConf.UserName = urlObj.Query().Get("username")
+		consulConf.Password = urlObj.Query().Get("password")
+		consulConf.CaFile = urlObj.Query().Get("caFile")
+		consulConf.WaitTime = urlObj.QueryDuration("wait", consulConf.WaitTime)
+		ds := NewDataSource(consulConf.MustBuild(), urlObj.Query().Get("key"), watch)
+		ds.(*consulDataSource).format = urlObj.Query().Get("format")
+		return ds
+	})
+}
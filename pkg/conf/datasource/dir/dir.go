@@ -0,0 +1,188 @@
+package dir
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/util/xcrypto"
+	"github.com/5idu/pilot/pkg/util/xgo"
+	"github.com/5idu/pilot/pkg/xlog"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+// defaultDebounce events within the duration trigger one reload
+const defaultDebounce = 100 * time.Millisecond
+
+// extensions of config files loaded from the directory
+var extensions = map[string]bool{".yaml": true, ".yml": true, ".json": true, ".toml": true}
+
+// dirDataSource loads all config files in a directory, merged in lexical order of file names,
+// the latter one overrides the former. Content is provided in json.
+type dirDataSource struct {
+	dir      string
+	debounce time.Duration
+	logger   *xlog.Logger
+	changed  chan struct{}
+	done     chan struct{}
+	// closeOnce closes done once, Close may be called again on reload or shutdown
+	closeOnce sync.Once
+
+	mu sync.Mutex
+	// md5 of merged content last read
+	md5 string
+}
+
+// NewDataSource returns a data source of config files in dir.
+// Files and the directory itself could be symlinks, such as Kubernetes ConfigMap volumes,
+// whose `..data` symlink is swapped on update.
+func NewDataSource(dir string, watch bool, debounce time.Duration) *dirDataSource {
+	absoluteDir, err := filepath.Abs(dir)
+	if err != nil {
+		xlog.Panic("new datasource", xlog.Any("err", err))
+	}
+	ds := &dirDataSource{
+		dir:      absoluteDir,
+		debounce: debounce,
+		logger:   xlog.With(xlog.String("mod", "dir datasource")),
+		done:     make(chan struct{}),
+	}
+	if watch {
+		ds.changed = make(chan struct{}, 1)
+		// watching starts before returned, so no change is missed after the first read
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			xlog.Panic("new dir watcher", xlog.Any("err", err))
+		}
+		if err := w.Add(absoluteDir); err != nil {
+			xlog.Panic("watch dir", xlog.String("dir", absoluteDir), xlog.Any("err", err))
+		}
+		xgo.Go(func() { ds.watch(w) })
+	}
+	return ds
+}
+
+// ReadConfig reads and merges all config files
+func (ds *dirDataSource) ReadConfig() ([]byte, error) {
+	content, err := ds.read()
+	if err != nil {
+		return nil, err
+	}
+	ds.mu.Lock()
+	ds.md5 = xcrypto.Md5(string(content))
+	ds.mu.Unlock()
+	return content, nil
+}
+
+func (ds *dirDataSource) read() ([]byte, error) {
+	files, err := ds.files()
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]interface{})
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		unmarshal, err := conf.GetDecoder(filepath.Ext(file))
+		if err != nil {
+			return nil, err
+		}
+		data := make(map[string]interface{})
+		if err := unmarshal(content, &data); err != nil {
+			return nil, errors.Wrapf(err, "decode %s", file)
+		}
+		conf.MergeMap(merged, data)
+	}
+	return json.Marshal(merged)
+}
+
+// files returns config files in lexical order, hidden files such as `..data` are skipped
+func (ds *dirDataSource) files() ([]string, error) {
+	entries, err := os.ReadDir(ds.dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || !extensions[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+		path := filepath.Join(ds.dir, name)
+		// follow symlinks
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Format content is merged into json
+func (ds *dirDataSource) Format() string {
+	return "json"
+}
+
+// Layer implements conf.LayeredDataSource
+func (ds *dirDataSource) Layer() conf.Layer {
+	return conf.LayerFile
+}
+
+// IsConfigChanged ...
+func (ds *dirDataSource) IsConfigChanged() <-chan struct{} {
+	return ds.changed
+}
+
+// Close stops watching
+func (ds *dirDataSource) Close() error {
+	ds.closeOnce.Do(func() { close(ds.done) })
+	return nil
+}
+
+// watch notifies once files in dir changed, events are debounced
+func (ds *dirDataSource) watch(w *fsnotify.Watcher) {
+	defer close(ds.changed)
+	defer w.Close()
+
+	timer := time.NewTimer(ds.debounce)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ds.done:
+			return
+		case event := <-w.Events:
+			// any change of the dir, including `..data` symlink swapped by kubernetes,
+			// is checked by the md5 of merged content
+			ds.logger.Debug("read watch event", xlog.String("event", event.String()))
+			timer.Reset(ds.debounce)
+		case err := <-w.Errors:
+			ds.logger.Error("read watch error", xlog.Any("err", err))
+		case <-timer.C:
+			content, err := ds.read()
+			if err != nil {
+				ds.logger.Error("read dir", xlog.String("dir", ds.dir), xlog.Any("err", err))
+				continue
+			}
+			ds.mu.Lock()
+			changed := xcrypto.Md5(string(content)) != ds.md5
+			ds.mu.Unlock()
+			if changed {
+				select {
+				case ds.changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
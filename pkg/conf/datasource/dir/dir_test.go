@@ -0,0 +1,86 @@
+package dir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/5idu/pilot/pkg/conf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"10-base.yaml":  "app:\n  name: base\n  port: 8080\n",
+		"20-app.json":   `{"app": {"name": "app"}}`,
+		"30-db.toml":    "[db]\ndsn = \"mysql\"\n",
+		".hidden.yaml":  "app:\n  name: hidden\n",
+		"readme.txt":    "app.name = txt",
+		"00-empty.yaml": "",
+	}
+	for name, content := range files {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	cfg := conf.New()
+	assert.Nil(t, cfg.LoadFromDataSource(NewDataSource(dir, false, defaultDebounce), nil))
+	assert.Equal(t, "app", cfg.GetString("app.name"))
+	assert.Equal(t, 8080, cfg.GetInt("app.port"))
+	assert.Equal(t, "mysql", cfg.GetString("db.dsn"))
+}
+
+// TestWatchConfigMap simulates updates of kubernetes ConfigMap volume
+func TestWatchConfigMap(t *testing.T) {
+	dir := t.TempDir()
+	publish := func(version, content string) {
+		data := filepath.Join(dir, version)
+		assert.Nil(t, os.Mkdir(data, 0755))
+		assert.Nil(t, os.WriteFile(filepath.Join(data, "app.yaml"), []byte(content), 0644))
+		assert.Nil(t, os.Symlink(version, filepath.Join(dir, "..data_tmp")))
+		assert.Nil(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	}
+	publish("..2024_01", "app:\n  rate: 1\n")
+	assert.Nil(t, os.Symlink(filepath.Join("..data", "app.yaml"), filepath.Join(dir, "app.yaml")))
+
+	ds := NewDataSource(dir, true, 50*time.Millisecond)
+	defer ds.Close()
+	cfg := conf.New()
+	assert.Nil(t, cfg.LoadFromDataSource(ds, nil))
+	assert.Equal(t, 1, cfg.GetInt("app.rate"))
+
+	publish("..2024_02", "app:\n  rate: 2\n")
+	assert.Eventually(t, func() bool { return cfg.GetInt("app.rate") == 2 }, 2*time.Second, 10*time.Millisecond)
+
+	// closed again by the deferred Close
+	assert.Nil(t, ds.Close())
+}
+
+func TestWatchDebounce(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte("rate: 0\n"), 0644))
+	ds := NewDataSource(dir, true, 100*time.Millisecond)
+	defer ds.Close()
+	_, err := ds.ReadConfig()
+	assert.Nil(t, err)
+
+	for i := 1; i <= 5; i++ {
+		assert.Nil(t, os.WriteFile(path, []byte("rate: "+string(rune('0'+i))+"\n"), 0644))
+	}
+	select {
+	case <-ds.IsConfigChanged():
+	case <-time.After(2 * time.Second):
+		t.Fatal("change not notified")
+	}
+	content, err := ds.ReadConfig()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"rate": 5}`, string(content))
+	select {
+	case <-ds.IsConfigChanged():
+		t.Fatal("changes not debounced")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
@@ -0,0 +1,31 @@
+package dir
+
+import (
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/flag"
+	"github.com/5idu/pilot/pkg/util/xnet"
+	"github.com/5idu/pilot/pkg/xlog"
+)
+
+// DataSourceDir defines dir scheme
+const DataSourceDir = "dir"
+
+func init() {
+	conf.RegisterDataSource(DataSourceDir, func(configAddr string) conf.DataSource {
+		var (
+			watch = flag.Bool("watch")
+		)
+		if configAddr == "" {
+			xlog.Panic("new dir dataSource, configAddr is empty")
+			return nil
+		}
+		// configAddr is a string in this format:
+		// dir:///etc/app/conf.d?debounce=100ms, or dir://conf.d for relative path
+		urlObj, err := xnet.ParseURL(configAddr)
+		if err != nil {
+			xlog.Panic("parse configAddr error", xlog.Any("error", err))
+			return nil
+		}
+		return NewDataSource(urlObj.Host+urlObj.Path, watch, urlObj.QueryDuration("debounce", defaultDebounce))
+	})
+}
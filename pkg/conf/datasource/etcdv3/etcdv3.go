@@ -3,6 +3,10 @@ package etcdv3
 import (
 	"context"
 	"encoding/json"
+	"path"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/5idu/pilot/pkg/client/etcdv3"
@@ -26,6 +30,16 @@ type etcdv3DataSource struct {
 	// logger *xlog.Logger
 
 	changed chan struct{}
+
+	// format declared by url, used if metadata.format not provided
+	format string
+	// metaFormat format declared by metadata.format of the latest config
+	metaFormat atomic.Value
+
+	mu sync.Mutex
+	// last config read or written, with its mod revision for compare-and-swap
+	last        config
+	modRevision int64
 }
 
 // NewDataSource new a etcdv3DataSource instance.
@@ -70,10 +84,63 @@ func (s *etcdv3DataSource) ReadConfig() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.metaFormat.Store(v.Metadata.Format)
+	s.mu.Lock()
+	s.last = v
+	s.modRevision = resp.Kvs[0].ModRevision
+	s.mu.Unlock()
 
 	return []byte(v.Content), nil
 }
 
+// Format returns metadata.format of config, falls back to format of url and extension of key
+func (s *etcdv3DataSource) Format() string {
+	if format, _ := s.metaFormat.Load().(string); format != "" {
+		return format
+	}
+	if s.format != "" {
+		return s.format
+	}
+	return path.Ext(s.propertyKey)
+}
+
+// Revision returns mod revision of the config last read or written
+func (s *etcdv3DataSource) Revision() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strconv.FormatInt(s.modRevision, 10)
+}
+
+// WriteConfig writes content if the key not modified since last read, metadata is kept
+func (s *etcdv3DataSource) WriteConfig(content []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v := s.last
+	v.Content = string(content)
+	v.Metadata.Timestamp = int(time.Now().Unix())
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(s.propertyKey), "=", s.modRevision)).
+		Then(clientv3.OpPut(s.propertyKey, string(value))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return conf.ErrConfigConflict
+	}
+	s.last = v
+	s.modRevision = resp.Header.GetRevision()
+	return nil
+}
+
 // IsConfigChanged ...
 func (s *etcdv3DataSource) IsConfigChanged() <-chan struct{} {
 	return s.changed
@@ -12,17 +12,16 @@ import (
 const DataSourceEtcdv3 = "etcdv3"
 
 func init() {
-	conf.Register(DataSourceEtcdv3, func() conf.DataSource {
+	conf.RegisterDataSource(DataSourceEtcdv3, func(configAddr string) conf.DataSource {
 		var (
-			configAddr = flag.String("config")
-			watch      = flag.Bool("watch")
+			watch = flag.Bool("watch")
 		)
 		if configAddr == "" {
 			xlog.Panic("new etcd dataSource, configAddr is empty")
 			return nil
 		}
 		// configAddr is a string in this format:
-		// etcdv3://ip:port?basicAuth=true&username=XXX&password=XXX&key=XXX&certFile=XXX&keyFile=XXX&caCert=XXX&secure=XXX
+		// etcdv3://ip:port?basicAuth=true&username=XXX&password=XXX&key=XXX&certFile=XXX&keyFile=XXX&caCert=XXX&secure=XXX&format=XXX
 
 		urlObj, err := xnet.ParseURL(configAddr)
 		if err != nil {
@@ -38,6 +37,8 @@ func init() {
 		etcdConf.CaCert = urlObj.Query().Get("caCert")
 		etcdConf.UserName = urlObj.Query().Get("username")
 		etcdConf.Password = urlObj.Query().Get("password")
-		return NewDataSource(etcdConf.MustBuild(), urlObj.Query().Get("key"), watch)
+		ds := NewDataSource(etcdConf.MustBuild(), urlObj.Query().Get("key"), watch)
+		ds.(*etcdv3DataSource).format = urlObj.Query().Get("format")
+		return ds
 	})
 }
@@ -4,7 +4,10 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/util/xcrypto"
 	"github.com/5idu/pilot/pkg/util/xfile"
 	"github.com/5idu/pilot/pkg/util/xgo"
 	"github.com/5idu/pilot/pkg/xlog"
@@ -18,6 +21,10 @@ type fileDataSource struct {
 	dir         string
 	enableWatch bool
 	changed     chan struct{}
+
+	mu sync.Mutex
+	// md5 of content last read or written
+	md5 string
 }
 
 // NewDataSource returns new fileDataSource.
@@ -38,7 +45,43 @@ func NewDataSource(path string, watch bool) *fileDataSource {
 
 // ReadConfig ...
 func (fp *fileDataSource) ReadConfig() (content []byte, err error) {
-	return os.ReadFile(fp.path)
+	content, err = os.ReadFile(fp.path)
+	if err != nil {
+		return nil, err
+	}
+	fp.mu.Lock()
+	fp.md5 = xcrypto.Md5(string(content))
+	fp.mu.Unlock()
+	return content, nil
+}
+
+// WriteConfig writes content into file if the file not changed since last read
+func (fp *fileDataSource) WriteConfig(content []byte) error {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	current, err := os.ReadFile(fp.path)
+	if err != nil {
+		return err
+	}
+	if xcrypto.Md5(string(current)) != fp.md5 {
+		return conf.ErrConfigConflict
+	}
+	if err := xfile.WriteAtomic(fp.path, content); err != nil {
+		return err
+	}
+	fp.md5 = xcrypto.Md5(string(content))
+	return nil
+}
+
+// Format returns the extension of config file
+func (fp *fileDataSource) Format() string {
+	return filepath.Ext(fp.path)
+}
+
+// Layer implements conf.LayeredDataSource
+func (fp *fileDataSource) Layer() conf.Layer {
+	return conf.LayerFile
 }
 
 // Close ...
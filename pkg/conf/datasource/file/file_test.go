@@ -0,0 +1,38 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/5idu/pilot/pkg/conf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	assert.Nil(t, os.WriteFile(path, []byte("[log]\nlevel = \"info\"\naddr = \"${HOSTNAME:localhost}\"\n"), 0644))
+
+	cfg := conf.New()
+	assert.Nil(t, cfg.LoadLayerFromDataSource(conf.LayerFile, path, NewDataSource(path, false), nil))
+	assert.Nil(t, cfg.Set("log.level", "debug"))
+	assert.Nil(t, cfg.WriteConfig())
+
+	// values are persisted, placeholders are kept
+	reloaded := conf.New()
+	assert.Nil(t, reloaded.LoadFromDataSource(NewDataSource(path, false), nil))
+	assert.Equal(t, "debug", reloaded.GetString("log.level"))
+	content, _ := os.ReadFile(path)
+	assert.Contains(t, string(content), "${HOSTNAME:localhost}")
+
+	origin, _ := cfg.OriginOf("log.level")
+	assert.Equal(t, conf.LayerFile, origin.Layer)
+
+	// changed by others since last read
+	assert.Nil(t, os.WriteFile(path, []byte("[log]\nlevel = \"warn\"\n"), 0644))
+	assert.Nil(t, cfg.Set("log.level", "error"))
+	assert.True(t, errors.Is(cfg.WriteConfig(), conf.ErrConfigConflict))
+	assert.Equal(t, "error", cfg.GetString("log.level"))
+}
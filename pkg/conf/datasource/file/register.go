@@ -10,10 +10,9 @@ import (
 const DataSourceFile = "file"
 
 func init() {
-	conf.Register(DataSourceFile, func() conf.DataSource {
+	conf.RegisterDataSource(DataSourceFile, func(configAddr string) conf.DataSource {
 		var (
 			watchConfig = flag.Bool("watch")
-			configAddr  = flag.String("config")
 		)
 		if configAddr == "" {
 			xlog.Panic("new file dataSource, configAddr is empty")
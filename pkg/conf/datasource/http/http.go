@@ -0,0 +1,300 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime"
+	nethttp "net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/util/xcrypto"
+	"github.com/5idu/pilot/pkg/xlog"
+
+	"github.com/pkg/errors"
+)
+
+// Config of http data source
+type Config struct {
+	// URL to fetch config from, without options of data source
+	URL string
+	// Format of config, falls back to Content-Type of response and extension of url path
+	Format string
+	// Interval of polling, or the max backoff of retrying long poll which returned immediately
+	Interval time.Duration
+	// LongPoll requests again right after the last response, server holds the request
+	// until config changed or Wait elapsed, which is sent as `Prefer: wait=<seconds>`
+	LongPoll bool
+	Wait     time.Duration
+	// Token bearer token
+	Token string
+	// Username and Password of basic auth
+	Username string
+	Password string
+	// CaFile custom CA to verify server certificates
+	CaFile   string
+	Insecure bool
+}
+
+// DefaultConfig ...
+func DefaultConfig() *Config {
+	return &Config{
+		Interval: 30 * time.Second,
+		Wait:     60 * time.Second,
+	}
+}
+
+type httpDataSource struct {
+	config *Config
+	client *nethttp.Client
+	logger *xlog.Logger
+
+	mu sync.Mutex
+	// etag and md5 of content last read
+	etag        string
+	md5         string
+	contentType string
+	// pending content fetched by watching, returned by the next ReadConfig
+	pending []byte
+
+	changed chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	// watching starts after the first read, which sets the etag to compare with
+	watchOnce sync.Once
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewDataSource creates a data source fetching config from config.URL
+func NewDataSource(config *Config, watch bool) (conf.DataSource, error) {
+	transport := nethttp.DefaultTransport.(*nethttp.Transport).Clone()
+	if config.CaFile != "" || config.Insecure {
+		tlsConfig := &tls.Config{InsecureSkipVerify: config.Insecure}
+		if config.CaFile != "" {
+			pem, err := os.ReadFile(config.CaFile)
+			if err != nil {
+				return nil, errors.Wrap(err, "read ca file")
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificate found in ca file: %s", config.CaFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	ds := &httpDataSource{
+		config: config,
+		client: &nethttp.Client{Transport: transport},
+		logger: xlog.With(xlog.String("mod", "http datasource")),
+	}
+	ds.ctx, ds.cancel = context.WithCancel(context.Background())
+	if watch {
+		ds.changed = make(chan struct{}, 1)
+	}
+	return ds, nil
+}
+
+// ReadConfig returns content fetched by watching, or fetches it from server
+func (ds *httpDataSource) ReadConfig() ([]byte, error) {
+	ds.mu.Lock()
+	if pending := ds.pending; pending != nil {
+		ds.pending = nil
+		ds.mu.Unlock()
+		return pending, nil
+	}
+	ds.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ds.ctx, 10*time.Second)
+	defer cancel()
+	content, _, err := ds.fetch(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if ds.changed != nil {
+		ds.watchOnce.Do(func() {
+			ds.wg.Add(1)
+			go ds.watch()
+		})
+	}
+	return content, nil
+}
+
+// fetch gets config from server, with If-None-Match if conditional.
+// Returns whether content changed since last fetched.
+func (ds *httpDataSource) fetch(ctx context.Context, conditional bool) ([]byte, bool, error) {
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, ds.config.URL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	ds.mu.Lock()
+	etag := ds.etag
+	ds.mu.Unlock()
+	if conditional && etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if conditional && ds.config.LongPoll {
+		req.Header.Set("Prefer", "wait="+strconv.Itoa(int(ds.config.Wait.Seconds())))
+	}
+	if ds.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+ds.config.Token)
+	} else if ds.config.Username != "" {
+		req.SetBasicAuth(ds.config.Username, ds.config.Password)
+	}
+
+	resp, err := ds.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == nethttp.StatusNotModified {
+		return nil, false, nil
+	}
+	if resp.StatusCode != nethttp.StatusOK {
+		return nil, false, fmt.Errorf("fetch config from %s: %s", ds.config.URL, resp.Status)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	sum := xcrypto.Md5(string(content))
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	changed := sum != ds.md5
+	ds.etag = resp.Header.Get("ETag")
+	ds.md5 = sum
+	ds.contentType = resp.Header.Get("Content-Type")
+	return content, changed, nil
+}
+
+// Format returns the format declared by url, Content-Type of response or extension of url path
+func (ds *httpDataSource) Format() string {
+	if ds.config.Format != "" {
+		return ds.config.Format
+	}
+	ds.mu.Lock()
+	contentType := ds.contentType
+	ds.mu.Unlock()
+	// generic types say nothing about the format
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil &&
+		mediaType != "text/plain" && mediaType != "application/octet-stream" {
+		return mediaType
+	}
+	if u, err := url.Parse(ds.config.URL); err == nil {
+		return path.Ext(u.Path)
+	}
+	return ""
+}
+
+// Revision returns ETag of the content last read, or its md5 if server does not support ETag
+func (ds *httpDataSource) Revision() string {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if ds.etag != "" {
+		return ds.etag
+	}
+	return ds.md5
+}
+
+// IsConfigChanged ...
+func (ds *httpDataSource) IsConfigChanged() <-chan struct{} {
+	return ds.changed
+}
+
+// Close stops watching
+func (ds *httpDataSource) Close() error {
+	ds.closeOnce.Do(func() {
+		ds.cancel()
+		ds.wg.Wait()
+		if ds.changed != nil {
+			close(ds.changed)
+		}
+	})
+	return nil
+}
+
+// minBackoff the first delay of retrying long poll which returned immediately
+const minBackoff = 100 * time.Millisecond
+
+func (ds *httpDataSource) watch() {
+	defer ds.wg.Done()
+	var failures int
+	for {
+		// polling waits before requests, long poll waits only after failed
+		if !ds.config.LongPoll && !ds.sleep(ds.config.Interval) {
+			return
+		}
+		timeout := 10 * time.Second
+		if ds.config.LongPoll {
+			timeout += ds.config.Wait
+		}
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(ds.ctx, timeout)
+		content, changed, err := ds.fetch(ctx, true)
+		cancel()
+
+		switch {
+		case ds.ctx.Err() != nil:
+			return
+		case err != nil:
+			ds.logger.Error("watch config", xlog.String("url", ds.config.URL), xlog.FieldErr(err))
+		case changed:
+			ds.mu.Lock()
+			ds.pending = content
+			ds.mu.Unlock()
+			select {
+			case ds.changed <- struct{}{}:
+			default:
+			}
+		}
+		if !ds.config.LongPoll {
+			continue
+		}
+
+		// server not holding requests returns immediately, such as failed or not supporting long poll
+		if err == nil && (changed || time.Since(start) >= ds.config.Wait/2) {
+			failures = 0
+			continue
+		}
+		if !ds.sleep(backoff(failures, ds.config.Interval)) {
+			return
+		}
+		failures++
+	}
+}
+
+// backoff doubles the delay from minBackoff by failures up to limit, with jitter of half the delay
+func backoff(failures int, limit time.Duration) time.Duration {
+	delay := limit
+	if failures < 16 && minBackoff<<failures < limit {
+		delay = minBackoff << failures
+	}
+	if delay < 2 {
+		return delay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+// sleep waits for d, returns false if closed
+func (ds *httpDataSource) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ds.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
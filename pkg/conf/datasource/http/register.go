@@ -0,0 +1,79 @@
+package http
+
+import (
+	"net/url"
+
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/flag"
+	"github.com/5idu/pilot/pkg/util/xnet"
+	"github.com/5idu/pilot/pkg/xlog"
+)
+
+const (
+	// DataSourceHTTP defines http scheme
+	DataSourceHTTP = "http"
+	// DataSourceHTTPS defines https scheme
+	DataSourceHTTPS = "https"
+)
+
+// options of data source in query, which are not sent to the server
+var options = []string{"format", "interval", "longPoll", "wait", "token", "username", "password", "caFile", "insecure"}
+
+func init() {
+	creator := func(configAddr string) conf.DataSource {
+		var (
+			watch = flag.Bool("watch")
+		)
+		if configAddr == "" {
+			xlog.Panic("new http dataSource, configAddr is empty")
+			return nil
+		}
+		config, err := parseConfig(configAddr)
+		if err != nil {
+			xlog.Panic("parse configAddr error", xlog.Any("error", err))
+			return nil
+		}
+		ds, err := NewDataSource(config, watch)
+		if err != nil {
+			xlog.Panic("new http dataSource error", xlog.Any("error", err))
+			return nil
+		}
+		return ds
+	}
+	conf.RegisterDataSource(DataSourceHTTP, creator)
+	conf.RegisterDataSource(DataSourceHTTPS, creator)
+}
+
+// parseConfig parses configAddr in this format:
+// https://user:pass@ip:port/path?format=yaml&interval=30s&longPoll=true&wait=60s&token=XXX&caFile=XXX&insecure=false
+func parseConfig(configAddr string) (*Config, error) {
+	urlObj, err := xnet.ParseURL(configAddr)
+	if err != nil {
+		return nil, err
+	}
+	config := DefaultConfig()
+	config.Format = urlObj.Query().Get("format")
+	config.Interval = urlObj.QueryDuration("interval", config.Interval)
+	config.LongPoll = urlObj.QueryBool("longPoll", false)
+	config.Wait = urlObj.QueryDuration("wait", config.Wait)
+	config.Token = urlObj.Query().Get("token")
+	config.Username = urlObj.Query().Get("username")
+	config.Password = urlObj.Query().Get("password")
+	if urlObj.User != nil {
+		config.Username = urlObj.Username()
+		config.Password, _ = urlObj.Password()
+	}
+	config.CaFile = urlObj.Query().Get("caFile")
+	config.Insecure = urlObj.QueryBool("insecure", false)
+
+	// request url without options and user info
+	requestURL, _ := url.Parse(configAddr)
+	query := requestURL.Query()
+	for _, option := range options {
+		query.Del(option)
+	}
+	requestURL.User = nil
+	requestURL.RawQuery = query.Encode()
+	config.URL = requestURL.String()
+	return config, nil
+}
@@ -2,17 +2,28 @@ package nacos
 
 import (
 	"log"
+	"path"
+	"strings"
+	"sync"
 
 	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/util/xcrypto"
 	"github.com/5idu/pilot/pkg/util/xgo"
 	"github.com/nacos-group/nacos-sdk-go/v2/clients/config_client"
 	"github.com/nacos-group/nacos-sdk-go/v2/vo"
+	"github.com/pkg/errors"
 )
 
 type nacosDataSource struct {
 	client config_client.IConfigClient
 	group  string
 	dataID string
+	// format declared by url, falls back to extension of dataID
+	format string
+
+	mu sync.Mutex
+	// md5 of content last read or written, for compare-and-swap
+	md5 string
 
 	changed chan struct{}
 }
@@ -41,10 +52,43 @@ func (ds *nacosDataSource) ReadConfig() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	ds.mu.Lock()
+	ds.md5 = xcrypto.Md5(configData)
+	ds.mu.Unlock()
 
 	return []byte(configData), nil
 }
 
+// WriteConfig publishes content if the config not modified since last read
+func (ds *nacosDataSource) WriteConfig(content []byte) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	published, err := ds.client.PublishConfig(vo.ConfigParam{
+		Group:   ds.group,
+		DataId:  ds.dataID,
+		Content: string(content),
+		Type:    strings.TrimPrefix(ds.Format(), "."),
+		CasMd5:  ds.md5,
+	})
+	if err != nil {
+		return errors.Wrap(err, "publish config")
+	}
+	if !published {
+		return conf.ErrConfigConflict
+	}
+	ds.md5 = xcrypto.Md5(string(content))
+	return nil
+}
+
+// Format returns the format of config
+func (ds *nacosDataSource) Format() string {
+	if ds.format != "" {
+		return ds.format
+	}
+	return path.Ext(ds.dataID)
+}
+
 func (ds *nacosDataSource) watch() {
 	ds.client.ListenConfig(vo.ConfigParam{
 		Group:  ds.group,
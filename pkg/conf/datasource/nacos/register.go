@@ -15,17 +15,16 @@ import (
 const DataSourceNacos = "nacos"
 
 func init() {
-	conf.Register(DataSourceNacos, func() conf.DataSource {
+	conf.RegisterDataSource(DataSourceNacos, func(configAddr string) conf.DataSource {
 		var (
-			configAddr = flag.String("config")
-			watch      = flag.Bool("watch")
+			watch = flag.Bool("watch")
 		)
 		if configAddr == "" {
 			xlog.Panic("new nacos dataSource, configAddr is empty")
 			return nil
 		}
 		// configAddr is a string in this format:
-		// nacos://ip:port?dataId=xx&group=xx&namespaceId=xx&timeout=10000&accessKey=xx&secretKey=xx&notLoadCacheAtStart=true&updateCacheWhenEmpty=true
+		// nacos://ip:port?dataId=xx&group=xx&namespaceId=xx&timeout=10000&accessKey=xx&secretKey=xx&notLoadCacheAtStart=true&updateCacheWhenEmpty=true&format=yaml
 		urlObj, err := xnet.ParseURL(configAddr)
 		if err != nil {
 			xlog.Panic("parse configAddr error", xlog.Any("error", err))
@@ -58,7 +57,9 @@ func init() {
 			xlog.Panic("create config client error", xlog.Any("error", err))
 			return nil
 		}
-		return NewDataSource(client, urlObj.Query().Get("group"), urlObj.Query().Get("dataId"), watch)
+		ds := NewDataSource(client, urlObj.Query().Get("group"), urlObj.Query().Get("dataId"), watch)
+		ds.(*nacosDataSource).format = urlObj.Query().Get("format")
+		return ds
 	})
 }
 
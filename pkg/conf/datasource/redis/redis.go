@@ -0,0 +1,167 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/5idu/pilot/pkg/client/redis"
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/util/xgo"
+	"github.com/5idu/pilot/pkg/xlog"
+
+	"github.com/pkg/errors"
+	goredis "github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+)
+
+type redisDataSource struct {
+	client *redis.Client
+	key    string
+	// format declared by url, falls back to extension of key.
+	// Hashes are always read as json.
+	format string
+	logger *xlog.Logger
+
+	mu   sync.Mutex
+	hash bool
+
+	pubsub  *goredis.PubSub
+	changed chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewDataSource creates a data source of redis key, which is a string of config content,
+// or a hash whose fields are config keys like `server.http.port`.
+// Changes are notified by messages of channel, falls back to keyspace notifications of key,
+// which requires `notify-keyspace-events` of redis server contains `K` and `$h` or `A`.
+// client is the redis client, it must be useful and should be release by User.
+func NewDataSource(client *redis.Client, key, channel string, watch bool) (conf.DataSource, error) {
+	ds := &redisDataSource{
+		client: client,
+		key:    key,
+		logger: xlog.With(xlog.String("mod", "redis datasource")),
+	}
+	ds.ctx, ds.cancel = context.WithCancel(context.Background())
+	if !watch {
+		return ds, nil
+	}
+
+	if channel == "" {
+		channel = fmt.Sprintf("__keyspace@%d__:%s", client.CmdOnMaster().Options().DB, key)
+	}
+	ds.pubsub = client.CmdOnMaster().Subscribe(ds.ctx, channel)
+	// wait for confirmation, changes after created are not missed
+	if _, err := ds.pubsub.Receive(ds.ctx); err != nil {
+		ds.pubsub.Close()
+		return nil, errors.Wrap(err, "subscribe "+channel)
+	}
+	ds.changed = make(chan struct{}, 1)
+	xgo.Go(ds.watch)
+	return ds, nil
+}
+
+// ReadConfig reads content of string key, or fields of hash key encoded as json
+func (ds *redisDataSource) ReadConfig() ([]byte, error) {
+	cmd := ds.client.CmdOnMaster()
+	typ, err := cmd.Type(ds.ctx, ds.key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case "string":
+		ds.setHash(false)
+		return cmd.Get(ds.ctx, ds.key).Bytes()
+	case "hash":
+		ds.setHash(true)
+		fields, err := cmd.HGetAll(ds.ctx, ds.key).Result()
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(expandFields(fields))
+	case "none":
+		return nil, errors.Errorf("redis key not found: %s", ds.key)
+	default:
+		return nil, errors.Errorf("redis key %s is a %s, only string and hash are supported", ds.key, typ)
+	}
+}
+
+// Format returns json for hashes, or the format declared by url, falls back to extension of key
+func (ds *redisDataSource) Format() string {
+	ds.mu.Lock()
+	hash := ds.hash
+	ds.mu.Unlock()
+	if hash {
+		return "json"
+	}
+	if ds.format != "" {
+		return ds.format
+	}
+	return path.Ext(ds.key)
+}
+
+// IsConfigChanged ...
+func (ds *redisDataSource) IsConfigChanged() <-chan struct{} {
+	return ds.changed
+}
+
+// Close stops watching
+func (ds *redisDataSource) Close() error {
+	ds.cancel()
+	if ds.pubsub != nil {
+		return ds.pubsub.Close()
+	}
+	return nil
+}
+
+func (ds *redisDataSource) setHash(hash bool) {
+	ds.mu.Lock()
+	ds.hash = hash
+	ds.mu.Unlock()
+}
+
+// watch notifies on every message of the channel, until pubsub closed
+func (ds *redisDataSource) watch() {
+	defer close(ds.changed)
+	for msg := range ds.pubsub.Channel() {
+		ds.logger.Debug("config changed", xlog.String("channel", msg.Channel), xlog.String("payload", msg.Payload))
+		select {
+		case ds.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// expandFields expands fields of hash into nested maps by dots, such as `server.http.port`.
+// Values are parsed as yaml scalars, so numbers and booleans keep their types.
+func expandFields(fields map[string]string) map[string]interface{} {
+	root := make(map[string]interface{})
+	for field, raw := range fields {
+		var value interface{} = raw
+		var parsed interface{}
+		if err := yaml.Unmarshal([]byte(raw), &parsed); err == nil {
+			switch parsed.(type) {
+			case int, float64, bool:
+				value = parsed
+			}
+		}
+
+		paths := strings.Split(field, ".")
+		node := root
+		for _, p := range paths[:len(paths)-1] {
+			child, ok := node[p].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[p] = child
+			}
+			node = child
+		}
+		node[paths[len(paths)-1]] = value
+	}
+	return root
+}
@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/5idu/pilot/pkg/client/redis"
+	"github.com/5idu/pilot/pkg/conf"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(t *testing.T, s *miniredis.Miniredis) *redis.Client {
+	config := redis.DefaultConfig()
+	config.Addr = s.Addr()
+	config.EnableTrace = false
+	config.EnableMetric = false
+	client, err := config.Build()
+	assert.Nil(t, err)
+	return client
+}
+
+func waitChanged(t *testing.T, ds conf.DataSource) {
+	select {
+	case <-ds.IsConfigChanged():
+	case <-time.After(3 * time.Second):
+		t.Fatal("change not notified")
+	}
+}
+
+func TestRedisDataSourceString(t *testing.T) {
+	s := miniredis.RunT(t)
+	client := newTestClient(t, s)
+	defer client.Close()
+	assert.Nil(t, s.Set("app.yaml", "a: 1"))
+
+	ds, err := NewDataSource(client, "app.yaml", "config-changed", true)
+	assert.Nil(t, err)
+	defer ds.Close()
+
+	content, err := ds.ReadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "a: 1", string(content))
+	assert.Equal(t, ".yaml", ds.(conf.FormatDataSource).Format())
+
+	assert.Nil(t, s.Set("app.yaml", "a: 2"))
+	s.Publish("config-changed", "app.yaml")
+	waitChanged(t, ds)
+	content, err = ds.ReadConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "a: 2", string(content))
+
+	s.Del("app.yaml")
+	_, err = ds.ReadConfig()
+	assert.NotNil(t, err)
+}
+
+func TestRedisDataSourceHash(t *testing.T) {
+	s := miniredis.RunT(t)
+	client := newTestClient(t, s)
+	defer client.Close()
+	s.HSet("app", "server.http.port", "8080", "server.http.host", "0.0.0.0", "debug", "true")
+
+	ds, err := NewDataSource(client, "app", "", true)
+	assert.Nil(t, err)
+	defer ds.Close()
+
+	content, err := ds.ReadConfig()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"server":{"http":{"port":8080,"host":"0.0.0.0"}},"debug":true}`, string(content))
+	assert.Equal(t, "json", ds.(conf.FormatDataSource).Format())
+
+	// miniredis does not send keyspace notifications, publish it like redis server does
+	s.HSet("app", "debug", "false")
+	s.Publish("__keyspace@0__:app", "hset")
+	waitChanged(t, ds)
+	content, err = ds.ReadConfig()
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), `"debug":false`)
+
+	// watching stops after closed
+	assert.Nil(t, ds.Close())
+	_, ok := <-ds.IsConfigChanged()
+	assert.False(t, ok)
+}
@@ -0,0 +1,53 @@
+package redis
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/5idu/pilot/pkg/client/redis"
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/flag"
+	"github.com/5idu/pilot/pkg/util/xnet"
+	"github.com/5idu/pilot/pkg/xlog"
+)
+
+// DataSourceRedis defines redis scheme
+const DataSourceRedis = "redis"
+
+func init() {
+	conf.RegisterDataSource(DataSourceRedis, func(configAddr string) conf.DataSource {
+		var (
+			watch = flag.Bool("watch")
+		)
+		if configAddr == "" {
+			xlog.Panic("new redis dataSource, configAddr is empty")
+			return nil
+		}
+		// configAddr is a string in this format:
+		// redis://[username:password@]ip:port[/db]?key=XXX&channel=XXX&format=XXX
+		urlObj, err := xnet.ParseURL(configAddr)
+		if err != nil {
+			xlog.Panic("parse configAddr error", xlog.Any("error", err))
+			return nil
+		}
+		redisConf := redis.DefaultConfig()
+		redisConf.Addr = urlObj.Host
+		if urlObj.User != nil {
+			redisConf.Username = urlObj.Username()
+			redisConf.Password, _ = urlObj.Password()
+		}
+		if db := strings.Trim(urlObj.Path, "/"); db != "" {
+			if redisConf.DB, err = strconv.Atoi(db); err != nil {
+				xlog.Panic("parse redis db error", xlog.String("db", db), xlog.Any("error", err))
+				return nil
+			}
+		}
+		ds, err := NewDataSource(redisConf.MustBuild(), urlObj.Query().Get("key"), urlObj.Query().Get("channel"), watch)
+		if err != nil {
+			xlog.Panic("new redis dataSource error", xlog.Any("error", err))
+			return nil
+		}
+		ds.(*redisDataSource).format = urlObj.Query().Get("format")
+		return ds
+	})
+}
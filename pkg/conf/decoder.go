@@ -0,0 +1,259 @@
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/hcl"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	decoderMu sync.RWMutex
+	decoders  = make(map[string]Unmarshaller)
+)
+
+func init() {
+	RegisterDecoder(yaml.Unmarshal, "yaml", ".yml", "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml")
+	RegisterDecoder(json.Unmarshal, "json", "application/json", "text/json")
+	RegisterDecoder(toml.Unmarshal, "toml", ".tml", "application/toml", "text/toml")
+	RegisterDecoder(unmarshalHCL, "hcl", "application/hcl", "text/hcl")
+	RegisterDecoder(unmarshalProperties, "properties", ".props", "text/x-java-properties", "text/properties")
+	RegisterDecoder(unmarshalDotenv, "env", "dotenv", "text/x-dotenv")
+}
+
+// RegisterDecoder registers an Unmarshaller with the format names,
+// name could be a format name (yaml), a file extension (.yaml) or a MIME type (application/yaml).
+func RegisterDecoder(unmarshaller Unmarshaller, names ...string) {
+	decoderMu.Lock()
+	defer decoderMu.Unlock()
+	for _, name := range names {
+		decoders[normalizeFormat(name)] = unmarshaller
+	}
+}
+
+// GetDecoder returns the Unmarshaller registered with format name, file extension or MIME type.
+func GetDecoder(name string) (Unmarshaller, error) {
+	decoderMu.RLock()
+	defer decoderMu.RUnlock()
+	if unmarshaller, ok := decoders[normalizeFormat(name)]; ok {
+		return unmarshaller, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, name)
+}
+
+// normalizeFormat turns `.YAML`, `yaml` and `application/yaml; charset=utf-8` into the same key
+func normalizeFormat(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if strings.Contains(name, "/") {
+		if mediaType, _, err := mime.ParseMediaType(name); err == nil {
+			return mediaType
+		}
+	}
+	return strings.TrimPrefix(name, ".")
+}
+
+// resolveDecoder returns unmarshaller if provided, or the decoder of format declared by data source.
+func resolveDecoder(ds DataSource, unmarshaller Unmarshaller) (Unmarshaller, error) {
+	if unmarshaller != nil {
+		return unmarshaller, nil
+	}
+	if fds, ok := ds.(FormatDataSource); ok && fds.Format() != "" {
+		return GetDecoder(fds.Format())
+	}
+	return nil, ErrUnknownFormat
+}
+
+// unmarshalHCL decodes hcl, and flattens blocks which hcl decodes as list of maps
+func unmarshalHCL(content []byte, v interface{}) error {
+	var data map[string]interface{}
+	if err := hcl.Unmarshal(content, &data); err != nil {
+		return err
+	}
+	out, ok := v.(*map[string]interface{})
+	if !ok {
+		return hcl.Unmarshal(content, v)
+	}
+	if *out == nil {
+		*out = make(map[string]interface{})
+	}
+	for key, val := range data {
+		(*out)[key] = flattenHCL(val)
+	}
+	return nil
+}
+
+func flattenHCL(val interface{}) interface{} {
+	switch v := val.(type) {
+	case []map[string]interface{}:
+		merged := make(map[string]interface{})
+		for _, m := range v {
+			for key, item := range m {
+				merged[key] = flattenHCL(item)
+			}
+		}
+		return merged
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = flattenHCL(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = flattenHCL(item)
+		}
+		return v
+	}
+	return val
+}
+
+// unmarshalProperties decodes java properties, `a.b=c` will be decoded as {"a": {"b": "c"}}
+func unmarshalProperties(content []byte, v interface{}) error {
+	out, ok := v.(*map[string]interface{})
+	if !ok {
+		return fmt.Errorf("properties: unsupported type %T", v)
+	}
+	if *out == nil {
+		*out = make(map[string]interface{})
+	}
+
+	var logical string
+	for i, line := range strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n") {
+		line = strings.TrimLeft(line, " \t\f")
+		if logical == "" && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+		// odd number of trailing backslashes means the line continues
+		if n := len(line) - len(strings.TrimRight(line, `\`)); n%2 == 1 {
+			logical += line[:len(line)-1]
+			continue
+		}
+		logical += line
+
+		key, val, err := splitProperty(logical)
+		if err != nil {
+			return fmt.Errorf("properties: line %d: %w", i+1, err)
+		}
+		setNested(*out, strings.Split(key, defaultKeyDelim), val)
+		logical = ""
+	}
+	if logical != "" {
+		key, val, err := splitProperty(logical)
+		if err != nil {
+			return fmt.Errorf("properties: %w", err)
+		}
+		setNested(*out, strings.Split(key, defaultKeyDelim), val)
+	}
+	return nil
+}
+
+func splitProperty(line string) (string, string, error) {
+	var key strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; c {
+		case '\\':
+			if i+1 < len(line) {
+				i++
+				key.WriteByte(unescapeProperty(line[i]))
+			}
+		case '=', ':', ' ', '\t', '\f':
+			if key.Len() == 0 {
+				return "", "", errors.New("empty key")
+			}
+			rest := strings.TrimLeft(line[i:], " \t\f")
+			if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+				rest = strings.TrimLeft(rest[1:], " \t\f")
+			}
+			return key.String(), unescapeValue(rest), nil
+		default:
+			key.WriteByte(c)
+		}
+	}
+	if key.Len() == 0 {
+		return "", "", errors.New("empty key")
+	}
+	return key.String(), "", nil
+}
+
+func unescapeValue(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			b.WriteByte(unescapeProperty(s[i]))
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func unescapeProperty(c byte) byte {
+	switch c {
+	case 't':
+		return '\t'
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 'f':
+		return '\f'
+	}
+	return c
+}
+
+// unmarshalDotenv decodes dotenv files, `APP_NAME=xx` will be decoded as {"app": {"name": "xx"}},
+// keeps the same naming rule with LoadEnvironments
+func unmarshalDotenv(content []byte, v interface{}) error {
+	out, ok := v.(*map[string]interface{})
+	if !ok {
+		return fmt.Errorf("dotenv: unsupported type %T", v)
+	}
+	if *out == nil {
+		*out = make(map[string]interface{})
+	}
+
+	for i, line := range strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		idx := strings.Index(line, "=")
+		if idx <= 0 {
+			return fmt.Errorf("dotenv: line %d: missing '='", i+1)
+		}
+		key := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(line[:idx]), "_", defaultKeyDelim))
+		val := strings.TrimSpace(line[idx+1:])
+		switch {
+		case len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"':
+			unquoted, err := strconv.Unquote(val)
+			if err != nil {
+				return fmt.Errorf("dotenv: line %d: %w", i+1, err)
+			}
+			val = unquoted
+		case len(val) >= 2 && val[0] == '\'' && val[len(val)-1] == '\'':
+			val = val[1 : len(val)-1]
+		default:
+			if idx := strings.Index(val, " #"); idx >= 0 {
+				val = strings.TrimSpace(val[:idx])
+			}
+		}
+		setNested(*out, strings.Split(key, defaultKeyDelim), val)
+	}
+	return nil
+}
+
+func setNested(m map[string]interface{}, paths []string, val interface{}) {
+	m = deepSearch(m, paths[:len(paths)-1])
+	m[paths[len(paths)-1]] = val
+}
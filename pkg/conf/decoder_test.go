@@ -0,0 +1,56 @@
+package conf_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/5idu/pilot/pkg/conf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoders(t *testing.T) {
+	cases := []struct {
+		format  string
+		content string
+	}{
+		{"toml", "[redis]\naddr = \"127.0.0.1:6379\"\ndb = \"0\"\n"},
+		{"application/json; charset=utf-8", `{"redis": {"addr": "127.0.0.1:6379", "db": "0"}}`},
+		{".yml", "redis:\n  addr: 127.0.0.1:6379\n  db: \"0\"\n"},
+		{"hcl", "redis {\n  addr = \"127.0.0.1:6379\"\n  db = \"0\"\n}\n"},
+		{".properties", "# comment\nredis.addr = 127.0.0.1:\\\n  6379\nredis.db:0\n"},
+		{"env", "export REDIS_ADDR=\"127.0.0.1:6379\"\nREDIS_DB=0 # comment\n"},
+	}
+	for _, c := range cases {
+		unmarshal, err := conf.GetDecoder(c.format)
+		assert.Nil(t, err, c.format)
+
+		cfg := conf.New()
+		assert.Nil(t, cfg.LoadFromReader(bytes.NewBufferString(c.content), unmarshal), c.format)
+		assert.Equal(t, "127.0.0.1:6379", cfg.GetString("redis.addr"), c.format)
+		assert.Equal(t, 0, cfg.GetInt("redis.db"), c.format)
+	}
+
+	_, err := conf.GetDecoder("xml")
+	assert.True(t, errors.Is(err, conf.ErrUnsupportedFormat))
+}
+
+type formatDataSource struct {
+	format  string
+	content string
+}
+
+func (ds *formatDataSource) ReadConfig() ([]byte, error)      { return []byte(ds.content), nil }
+func (ds *formatDataSource) IsConfigChanged() <-chan struct{} { return nil }
+func (ds *formatDataSource) Close() error                     { return nil }
+func (ds *formatDataSource) Format() string                   { return ds.format }
+
+func TestLoadFromFormatDataSource(t *testing.T) {
+	cfg := conf.New()
+	assert.Nil(t, cfg.LoadFromDataSource(&formatDataSource{format: "toml", content: "name = \"pilot\""}, nil))
+	assert.Equal(t, "pilot", cfg.GetString("name"))
+
+	err := cfg.LoadFromDataSource(&formatDataSource{content: "name = \"pilot\""}, nil)
+	assert.True(t, errors.Is(err, conf.ErrUnknownFormat))
+}
@@ -0,0 +1,100 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	encoderMu sync.RWMutex
+	encoders  = make(map[string]Marshaller)
+)
+
+func init() {
+	RegisterEncoder(yaml.Marshal, "yaml", ".yml", "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml")
+	RegisterEncoder(marshalJSON, "json", "application/json", "text/json")
+	RegisterEncoder(marshalTOML, "toml", ".tml", "application/toml", "text/toml")
+	RegisterEncoder(marshalProperties, "properties", ".props", "text/x-java-properties", "text/properties")
+	RegisterEncoder(marshalDotenv, "env", "dotenv", "text/x-dotenv")
+}
+
+// RegisterEncoder registers a Marshaller with the format names, see RegisterDecoder
+func RegisterEncoder(marshaller Marshaller, names ...string) {
+	encoderMu.Lock()
+	defer encoderMu.Unlock()
+	for _, name := range names {
+		encoders[normalizeFormat(name)] = marshaller
+	}
+}
+
+// GetEncoder returns the Marshaller registered with format name, file extension or MIME type.
+func GetEncoder(name string) (Marshaller, error) {
+	encoderMu.RLock()
+	defer encoderMu.RUnlock()
+	if marshaller, ok := encoders[normalizeFormat(name)]; ok {
+		return marshaller, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, name)
+}
+
+func marshalJSON(v interface{}) ([]byte, error) {
+	return json.MarshalIndent(v, "", "  ")
+}
+
+func marshalTOML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// flatten returns sorted flattened keys and values of v
+func flatten(v interface{}) ([]string, map[string]interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported type %T", v)
+	}
+	data := make(map[string]interface{})
+	lookup("", m, data, defaultKeyDelim)
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, data, nil
+}
+
+func marshalProperties(v interface{}) ([]byte, error) {
+	keys, data, err := flatten(v)
+	if err != nil {
+		return nil, fmt.Errorf("properties: %w", err)
+	}
+	var buf bytes.Buffer
+	replacer := strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%s=%s\n", replacer.Replace(key), replacer.Replace(fmt.Sprint(data[key])))
+	}
+	return buf.Bytes(), nil
+}
+
+func marshalDotenv(v interface{}) ([]byte, error) {
+	keys, data, err := flatten(v)
+	if err != nil {
+		return nil, fmt.Errorf("dotenv: %w", err)
+	}
+	var buf bytes.Buffer
+	for _, key := range keys {
+		name := strings.ToUpper(strings.ReplaceAll(key, defaultKeyDelim, "_"))
+		fmt.Fprintf(&buf, "%s=%s\n", name, strconv.Quote(fmt.Sprint(data[key])))
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,15 @@
+package conf
+
+import "testing"
+
+// RestoreSchemas restores the registered schemas on cleanup of t, schemas registered by t are dropped
+func RestoreSchemas(t *testing.T) {
+	schemaMu.RLock()
+	saved := append([]configSchema(nil), schemas...)
+	schemaMu.RUnlock()
+	t.Cleanup(func() {
+		schemaMu.Lock()
+		defer schemaMu.Unlock()
+		schemas = saved
+	})
+}
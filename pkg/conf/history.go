@@ -0,0 +1,207 @@
+package conf
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/5idu/pilot/pkg/util/xcrypto"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// defaultHistorySize number of versions kept by default
+const defaultHistorySize = 10
+
+// ErrVersionNotFound defines an error that the version is not in history
+var ErrVersionNotFound = errors.New("config version not found")
+
+// Version is a snapshot of config applied from a source.
+// Changes are raw values, before interpolated and decrypted.
+type Version struct {
+	ID     int    `json:"id"`
+	Source string `json:"source"`
+	Layer  Layer  `json:"layer"`
+	// Revision revision declared by RevisionDataSource, or md5 of content
+	Revision string        `json:"revision"`
+	Time     time.Time     `json:"time"`
+	Changes  []ChangeEvent `json:"changes"`
+
+	// sources values of all sources after applied
+	sources []*source
+}
+
+// SetHistorySize sets the number of versions kept in history, the oldest ones are dropped
+func (c *Configuration) SetHistorySize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.historySize = size
+	c.trimHistory()
+}
+
+// SetAutoReject rejects a new snapshot of data source, if it fails to verify by Check and OnVerify,
+// or any OnChangeE handler returns error. Snapshots are verified before applied,
+// so watchers, bindings and OnChange handlers never see rejected values.
+func (c *Configuration) SetAutoReject(reject bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.autoReject = reject
+}
+
+// OnVerify registers fn to verify a new snapshot before applied, works with SetAutoReject
+func (c *Configuration) OnVerify(fn func(*Configuration) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.verifiers = append(c.verifiers, fn)
+}
+
+// OnChangeE registers change handler who reports error, works with SetAutoReject.
+// With auto reject, it's called with the new snapshot before applied, and not called again once applied.
+func (c *Configuration) OnChangeE(fn func(*Configuration) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onChangeEs = append(c.onChangeEs, fn)
+}
+
+// History returns versions in history, the oldest first
+func (c *Configuration) History() []Version {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	versions := make([]Version, 0, len(c.history))
+	for _, v := range c.history {
+		versions = append(versions, *v)
+	}
+	return versions
+}
+
+// Rollback restores values of all sources to the version, which is recorded as a new version.
+// Values are overridden again once data sources changed.
+func (c *Configuration) Rollback(id int) error {
+	c.mu.Lock()
+	var target *Version
+	for _, v := range c.history {
+		if v.ID == id {
+			target = v
+		}
+	}
+	if target == nil {
+		c.mu.Unlock()
+		return errors.Wrapf(ErrVersionNotFound, "%d", id)
+	}
+	c.restore(target.sources)
+	changes := c.rebuild()
+	c.record(target.Layer, fmt.Sprintf("rollback#%d", id), target.Revision, changes)
+	c.mu.Unlock()
+
+	if err := c.runOnChanges(true); err != nil {
+		log.Printf("rollback config to version %d: %v", id, err)
+	}
+	return nil
+}
+
+// applySource replaces values of the source loaded from data source, and records the version.
+// On reload, change handlers are called, and the snapshot is verified before applied with auto reject.
+func (c *Configuration) applySource(layer Layer, name, revision string, data map[string]interface{}, reload bool) error {
+	c.mu.RLock()
+	autoReject := c.autoReject
+	c.mu.RUnlock()
+	if reload && autoReject {
+		if err := c.verify(layer, name, data); err != nil {
+			return c.rejectSnapshot(name, err)
+		}
+	}
+
+	c.mu.Lock()
+	c.getSource(layer, name).data = data
+	changes := c.rebuild()
+	c.record(layer, name, revision, changes)
+	c.mu.Unlock()
+
+	if reload {
+		// OnChangeE handlers have been called by verify with auto reject
+		if err := c.runOnChanges(!autoReject); err != nil {
+			log.Printf("handle config changes from %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// verify checks config with data applied to the source by Check, OnVerify and OnChangeE handlers,
+// which are called without c.mu held.
+func (c *Configuration) verify(layer Layer, name string, data map[string]interface{}) error {
+	candidate := New()
+	c.mu.RLock()
+	candidate.keyDelim = c.keyDelim
+	candidate.decrypter = c.decrypter
+	candidate.sources = c.snapshot()
+	verifiers, onChangeEs := c.verifiers, c.onChangeEs
+	c.mu.RUnlock()
+
+	candidate.mu.Lock()
+	candidate.getSource(layer, name).data = data
+	candidate.rebuild()
+	candidate.mu.Unlock()
+
+	err := candidate.Check()
+	for _, fn := range verifiers {
+		err = multierr.Append(err, fn(candidate))
+	}
+	for _, fn := range onChangeEs {
+		err = multierr.Append(err, fn(candidate))
+	}
+	return err
+}
+
+// runOnChanges calls OnChange handlers, and OnChangeE handlers if withE
+func (c *Configuration) runOnChanges(withE bool) error {
+	c.mu.RLock()
+	onChanges, onChangeEs := c.onChanges, c.onChangeEs
+	c.mu.RUnlock()
+
+	for _, change := range onChanges {
+		change(c)
+	}
+	if !withE {
+		return nil
+	}
+	var errs error
+	for _, change := range onChangeEs {
+		errs = multierr.Append(errs, change(c))
+	}
+	return errs
+}
+
+func (c *Configuration) rejectSnapshot(name string, err error) error {
+	log.Printf("reject config from %s: %v", name, err)
+	c.reject(name, err)
+	return errors.WithMessage(err, "config rejected")
+}
+
+// record appends a version to history, must be called with c.mu held
+func (c *Configuration) record(layer Layer, name, revision string, changes []ChangeEvent) {
+	c.versionID++
+	c.history = append(c.history, &Version{
+		ID:       c.versionID,
+		Source:   name,
+		Layer:    layer,
+		Revision: revision,
+		Time:     time.Now(),
+		Changes:  changes,
+		sources:  c.snapshot(),
+	})
+	c.trimHistory()
+}
+
+func (c *Configuration) trimHistory() {
+	if n := len(c.history) - c.historySize; n > 0 {
+		c.history = append(c.history[:0:0], c.history[n:]...)
+	}
+}
+
+func revisionOf(ds DataSource, content []byte) string {
+	if rds, ok := ds.(RevisionDataSource); ok {
+		return rds.Revision()
+	}
+	return xcrypto.Md5(string(content))
+}
@@ -0,0 +1,121 @@
+package conf_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/conf/secret"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	cfg := conf.New()
+	remote := &changedDataSource{
+		formatDataSource: formatDataSource{format: "yaml", content: "app:\n  rate: 1\n"},
+		layer:            conf.LayerRemote,
+		changed:          make(chan struct{}),
+	}
+	assert.Nil(t, cfg.LoadLayerFromDataSource(conf.LayerRemote, "remote", remote, nil))
+	push := func(content string) {
+		remote.content = content
+		remote.changed <- struct{}{}
+	}
+
+	push("app:\n  rate: 2\n")
+	assert.Eventually(t, func() bool { return len(cfg.History()) == 2 }, time.Second, 10*time.Millisecond)
+	versions := cfg.History()
+	assert.Equal(t, "remote", versions[1].Source)
+	assert.NotEqual(t, versions[0].Revision, versions[1].Revision)
+	assert.Equal(t, []conf.ChangeEvent{{Key: "app.rate", Type: conf.ChangeUpdate, OldValue: 1, NewValue: 2}}, versions[1].Changes)
+
+	assert.Nil(t, cfg.Rollback(versions[0].ID))
+	assert.Equal(t, 1, cfg.GetInt("app.rate"))
+	versions = cfg.History()
+	assert.Len(t, versions, 3)
+	assert.Equal(t, versions[0].Revision, versions[2].Revision)
+	assert.True(t, errors.Is(cfg.Rollback(100), conf.ErrVersionNotFound))
+
+	var rejected int32
+	cfg.OnRejected(func(key string, err error) {
+		assert.Equal(t, "remote", key)
+		atomic.AddInt32(&rejected, 1)
+	})
+	cfg.SetAutoReject(true)
+	cfg.OnVerify(func(c *conf.Configuration) error {
+		if c.GetInt("app.rate") > 100 {
+			return errors.New("rate too large")
+		}
+		return nil
+	})
+	cfg.OnChangeE(func(c *conf.Configuration) error {
+		if c.GetInt("app.rate") == 50 {
+			return errors.New("rate not supported")
+		}
+		return nil
+	})
+
+	// watchers never see rejected values
+	var seen []interface{}
+	var seenMu sync.Mutex
+	cancel := cfg.Watch("app.rate", func(ev conf.ChangeEvent) {
+		seenMu.Lock()
+		defer seenMu.Unlock()
+		seen = append(seen, ev.NewValue)
+	})
+	defer cancel()
+
+	// rejected by verifier, never applied
+	push("app:\n  rate: 200\n")
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&rejected) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, cfg.GetInt("app.rate"))
+	// rejected by change handler before applied
+	push("app:\n  rate: 50\n")
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&rejected) == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, cfg.GetInt("app.rate"))
+	assert.Len(t, cfg.History(), 3)
+	time.Sleep(20 * time.Millisecond)
+	seenMu.Lock()
+	assert.Empty(t, seen)
+	seenMu.Unlock()
+
+	cfg.SetHistorySize(2)
+	assert.Equal(t, versions[1:], cfg.History())
+}
+
+func TestHistoryRawValues(t *testing.T) {
+	key, _ := secret.GenerateKey()
+	cipher := secret.NewCipher(secret.NewStaticKeyProvider("k1", map[string][]byte{"k1": key}))
+	v1, _ := cipher.Encrypt("secret1")
+	v2, _ := cipher.Encrypt("secret2")
+
+	cfg := conf.New()
+	cfg.SetDecrypter(cipher)
+	remote := &changedDataSource{
+		formatDataSource: formatDataSource{format: "yaml", content: "app:\n  conn: " + v1 + "\n"},
+		layer:            conf.LayerRemote,
+		changed:          make(chan struct{}),
+	}
+	assert.Nil(t, cfg.LoadLayerFromDataSource(conf.LayerRemote, "remote", remote, nil))
+	events := make(chan conf.ChangeEvent, 1)
+	defer cfg.Watch("app.conn", func(ev conf.ChangeEvent) { events <- ev })()
+
+	remote.content = "app:\n  conn: " + v2 + "\n"
+	remote.changed <- struct{}{}
+	select {
+	case ev := <-events:
+		// watchers get decrypted values
+		assert.Equal(t, "secret2", ev.NewValue)
+	case <-time.After(time.Second):
+		t.Fatal("change not delivered")
+	}
+
+	// history keeps encrypted values
+	versions := cfg.History()
+	assert.Equal(t, []conf.ChangeEvent{{Key: "app.conn", Type: conf.ChangeAdd, NewValue: v1}}, versions[0].Changes)
+	assert.Equal(t, []conf.ChangeEvent{{Key: "app.conn", Type: conf.ChangeUpdate, OldValue: v1, NewValue: v2}}, versions[1].Changes)
+}
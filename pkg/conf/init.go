@@ -1,57 +1,97 @@
 package conf
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/url"
+	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 
+	"github.com/5idu/pilot/pkg/conf/secret"
 	"github.com/5idu/pilot/pkg/flag"
 	"github.com/5idu/pilot/pkg/hooks"
-
-	"gopkg.in/yaml.v3"
 )
 
 const DefaultEnvPrefix = "PILOT_"
 
+// EnvConfigKeys environment of keys to decrypt config values, in format id:base64key[,id:base64key]
+const EnvConfigKeys = "PILOT_CONFIG_KEYS"
+
 func init() {
+	// flags with ConfigKey override config files, remote config and environments
+	flag.SetConfigBinder(func(key string, val interface{}) {
+		if kvs, ok := val.(map[string]string); ok {
+			m := make(map[string]interface{}, len(kvs))
+			for k, v := range kvs {
+				m[k] = v
+			}
+			val = m
+		}
+		SetLayer(LayerFlag, key, val)
+	})
+
 	flag.Register(&flag.StringFlag{Name: "envPrefix", Usage: "--envPrefix=PILOT_", Default: DefaultEnvPrefix, Action: func(key string, fs *flag.FlagSet) {
 		var envPrefix = fs.String(key)
 		defaultConfiguration.LoadEnvironments(envPrefix)
 	}})
 
-	flag.Register(&flag.StringFlag{Name: "config", Usage: "--config=config.yaml", Action: func(key string, fs *flag.FlagSet) {
+	// addresses are split by splitConfigAddrs, so that commas in urls are kept, or given repeatedly
+	flag.Register(&flag.StringSliceFlag{Name: "config", Usage: "--config=config.yaml,etcdv3://127.0.0.1:2379?key=app", NoSplit: true, Action: func(key string, fs *flag.FlagSet) {
 		hooks.Do(hooks.Stage_BeforeLoadConfig)
 
-		var configAddr = fs.String(key)
-		log.Printf("read config: %s", configAddr)
-		datasource, err := NewDataSource(configAddr)
-		if err != nil {
-			log.Fatalf("build datasource[%s] failed: %v", configAddr, err)
-		}
-		path := configAddr
-		if uri, err := url.ParseRequestURI(configAddr); err == nil {
-			path = uri.Path
+		setupDecrypter(fs)
+		// addresses are loaded in order, the latter one overrides the former within the same layer
+		for _, value := range fs.StringSlice(key) {
+			for _, configAddr := range splitConfigAddrs(value) {
+				loadConfigAddr(configAddr)
+			}
 		}
 
-		unmarshaler := yaml.Unmarshal
-		switch filepath.Ext(path) {
-		case ".yaml", ".yml":
-			unmarshaler = yaml.Unmarshal
-		case ".json":
-			unmarshaler = json.Unmarshal
-		default:
-			log.Fatalf("unsupported config type: %s", filepath.Ext(configAddr))
+		// invalid config stops booting, failures of other hooks are only logged
+		if err := hooks.DoE(context.Background(), hooks.Stage_AfterLoadConfig); err != nil {
+			var checkErr *CheckError
+			if errors.As(err, &checkErr) {
+				log.Fatalf("check config failed, %v", err)
+			}
 		}
+	}})
 
-		if err := LoadFromDataSource(datasource, unmarshaler); err != nil {
-			log.Fatalf("load config from datasource[%s] failed: %v", configAddr, err)
+	flag.Register(&flag.BoolFlag{Name: "config-check", Usage: "--config-check=false, skip checking config of registered schemas after loaded", Default: true, EnvVar: "PILOT_CONFIG_CHECK"})
+	// run after all other hooks of the stage
+	hooks.RegisterHook(hooks.Stage_AfterLoadConfig, "config-check", func(context.Context) error {
+		if !flag.Bool("config-check") {
+			return nil
 		}
-		log.Printf("load config from datasource[%s] completely!", configAddr)
+		return Check()
+	}, hooks.WithPriority(math.MinInt32))
 
-		hooks.Do(hooks.Stage_AfterLoadConfig)
+	flag.RegisterCommand(&flag.Command{
+		Name:  "config",
+		Usage: "manage config of application",
+		Commands: []*flag.Command{{
+			Name:  "check",
+			Usage: "check config loaded by --config against registered schemas",
+			Action: func(fs *flag.FlagSet) error {
+				if err := Check(); err != nil {
+					return err
+				}
+				fmt.Fprintln(fs.Output(), "config is valid")
+				return nil
+			},
+		}},
+	})
+
+	flag.Register(&flag.BoolFlag{Name: "config-auto-reject", Usage: "--config-auto-reject, reject config pushed by data sources if failed to check or apply", Default: false, EnvVar: "PILOT_CONFIG_AUTO_REJECT", Action: func(key string, fs *flag.FlagSet) {
+		SetAutoReject(fs.Bool(key))
 	}})
 
+	flag.Register(&flag.StringFlag{Name: "config-key-file", Usage: "--config-key-file=keys, keys to decrypt enc:v1: values, one id:base64key per line", EnvVar: "PILOT_CONFIG_KEY_FILE"})
+
 	flag.Register(&flag.StringFlag{Name: "config-tag", Usage: "--config-tag=mapstructure", Default: "mapstructure", Action: func(key string, fs *flag.FlagSet) {
 		defaultGetOptions.TagName = fs.String("config-tag")
 	}})
@@ -60,3 +100,84 @@ func init() {
 		log.Printf("load config watch: %v", fs.Bool(key))
 	}})
 }
+
+// schemePattern matches addresses starting with a scheme, such as etcdv3://
+var schemePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://`)
+
+// splitConfigAddrs splits comma separated addresses. A comma starts the next address only if it's followed by
+// a scheme, an absolute or relative path, or a file of registered format, others are part of the former url,
+// such as `http://127.0.0.1/config?keys=a,b`.
+func splitConfigAddrs(value string) []string {
+	var addrs []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if len(addrs) == 0 || isConfigAddr(part) {
+			addrs = append(addrs, part)
+			continue
+		}
+		addrs[len(addrs)-1] += "," + part
+	}
+	return addrs
+}
+
+func isConfigAddr(s string) bool {
+	if schemePattern.MatchString(s) || strings.HasPrefix(s, "/") || strings.HasPrefix(s, ".") {
+		return true
+	}
+	// relative file, such as config.yaml, but not a query value
+	if strings.ContainsAny(s, "=&?") {
+		return false
+	}
+	ext := filepath.Ext(s)
+	if ext == "" {
+		return false
+	}
+	_, err := GetDecoder(ext)
+	return err == nil
+}
+
+func loadConfigAddr(configAddr string) {
+	log.Printf("read config: %s", configAddr)
+	datasource, err := NewDataSource(configAddr)
+	if err != nil {
+		log.Fatalf("build datasource[%s] failed: %v", configAddr, err)
+	}
+	// data source declares its format, decoder will be resolved while loading
+	var unmarshaler Unmarshaller
+	if _, ok := datasource.(FormatDataSource); !ok {
+		path := configAddr
+		if uri, err := url.ParseRequestURI(configAddr); err == nil {
+			path = uri.Path
+		}
+		if unmarshaler, err = GetDecoder(filepath.Ext(path)); err != nil {
+			log.Fatalf("unsupported config type: %s", filepath.Ext(path))
+		}
+	}
+
+	if err := LoadLayerFromDataSource(layerOf(datasource), configAddr, datasource, unmarshaler); err != nil {
+		log.Fatalf("load config from datasource[%s] failed: %v", configAddr, err)
+	}
+	log.Printf("load config from datasource[%s] completely!", configAddr)
+}
+
+// setupDecrypter sets decrypter with keys from --config-key-file, or environment PILOT_CONFIG_KEYS
+func setupDecrypter(fs *flag.FlagSet) {
+	var (
+		provider secret.KeyProvider
+		err      error
+	)
+	if keyFile := fs.String("config-key-file"); keyFile != "" {
+		provider, err = secret.NewFileKeyProvider(keyFile)
+	} else if os.Getenv(EnvConfigKeys) != "" {
+		provider, err = secret.NewEnvKeyProvider(EnvConfigKeys)
+	} else {
+		return
+	}
+	if err != nil {
+		log.Fatalf("load config keys failed: %v", err)
+	}
+	SetDecrypter(secret.NewCipher(provider))
+}
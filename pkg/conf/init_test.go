@@ -0,0 +1,22 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitConfigAddrs(t *testing.T) {
+	for value, addrs := range map[string][]string{
+		"config.yaml": {"config.yaml"},
+		"config.yaml, etcdv3://127.0.0.1:2379?key=app": {"config.yaml", "etcdv3://127.0.0.1:2379?key=app"},
+		"base.yaml,local.toml,/etc/app/config.json":    {"base.yaml", "local.toml", "/etc/app/config.json"},
+		"./config.yaml,http://127.0.0.1/config?keys=a,b,c.yaml&app=demo,../override.yaml": {
+			"./config.yaml", "http://127.0.0.1/config?keys=a,b,c.yaml&app=demo", "../override.yaml",
+		},
+		"etcdv3://127.0.0.1:2379,127.0.0.2:2379?key=app,,": {"etcdv3://127.0.0.1:2379,127.0.0.2:2379?key=app"},
+		"": nil,
+	} {
+		assert.Equal(t, addrs, splitConfigAddrs(value), value)
+	}
+}
@@ -0,0 +1,220 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/5idu/pilot/pkg/conf/secret"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInterpolation defines an error that placeholder in config value could not be resolved
+	ErrInterpolation = errors.New("config interpolation failed")
+	// ErrDecrypt defines an error that encrypted config value could not be decrypted
+	ErrDecrypt = errors.New("config decryption failed")
+)
+
+// Decrypter decrypts encrypted config values, such as *secret.Cipher
+type Decrypter interface {
+	Decrypt(value string) (string, error)
+}
+
+// interpolator resolves placeholders in config values:
+//
+//	${ENV_VAR}            value of environment variable
+//	${ENV_VAR:default}    value of environment variable, default if not set
+//	${pilot.some.key}     value of another config key, key must contain keyDelim
+//	${pilot.some.key:def} value of another config key, def if not exists
+//	$${literal}           escaped, resolved as ${literal}
+//	enc:v1:<base64>       encrypted value, decrypted by Decrypter
+//
+// If a value is a single placeholder, the referenced value keeps its type, e.g. int.
+type interpolator struct {
+	root      map[string]interface{}
+	delim     string
+	decrypter Decrypter
+	// stack keys being resolved, used to detect cycles
+	stack []string
+}
+
+func newInterpolator(root map[string]interface{}, delim string, decrypter Decrypter) *interpolator {
+	return &interpolator{root: root, delim: delim, decrypter: decrypter}
+}
+
+// resolve resolves val which is the raw value of key path
+func (ip *interpolator) resolve(path string, val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case string:
+		if path != "" && (len(ip.stack) == 0 || ip.stack[len(ip.stack)-1] != path) {
+			ip.stack = append(ip.stack, path)
+			defer func() { ip.stack = ip.stack[:len(ip.stack)-1] }()
+		}
+		if secret.IsEncrypted(v) {
+			return ip.decrypt(v)
+		}
+		return ip.expand(v)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			resolved, err := ip.resolve(ip.join(path, key), item)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = resolved
+		}
+		return out, nil
+	case map[interface{}]interface{}:
+		out := make(map[interface{}]interface{}, len(v))
+		for key, item := range v {
+			resolved, err := ip.resolve(ip.join(path, fmt.Sprint(key)), item)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = resolved
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := ip.resolve(path, item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	}
+	return val, nil
+}
+
+// resolveKey resolves val which is the raw value of key, with error described
+func (ip *interpolator) resolveKey(key string, val interface{}) (interface{}, error) {
+	ip.stack = ip.stack[:0]
+	val, err := ip.resolve(key, val)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "resolve %s", key)
+	}
+	return val, nil
+}
+
+func (ip *interpolator) raw(key string) interface{} {
+	val, _ := searchPath(ip.root, strings.Split(key, ip.delim))
+	return val
+}
+
+func (ip *interpolator) expand(s string) (interface{}, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var (
+		b    strings.Builder
+		rest = s
+	)
+	for {
+		idx := strings.Index(rest, "${")
+		if idx < 0 {
+			b.WriteString(rest)
+			break
+		}
+		// $${ is escaped
+		if idx > 0 && rest[idx-1] == '$' {
+			b.WriteString(rest[:idx-1])
+			b.WriteString("${")
+			rest = rest[idx+2:]
+			continue
+		}
+		end := matchBrace(rest, idx+2)
+		if end < 0 {
+			return nil, errors.Wrapf(ErrInterpolation, "unclosed placeholder in %q", s)
+		}
+
+		val, err := ip.lookup(rest[idx+2 : end])
+		if err != nil {
+			return nil, err
+		}
+		// single placeholder keeps the type of referenced value
+		if idx == 0 && end == len(rest)-1 && b.Len() == 0 {
+			return val, nil
+		}
+		b.WriteString(rest[:idx])
+		b.WriteString(fmt.Sprint(val))
+		rest = rest[end+1:]
+	}
+	return b.String(), nil
+}
+
+// lookup resolves the expression inside ${}
+func (ip *interpolator) lookup(expr string) (interface{}, error) {
+	name, def, hasDefault := strings.Cut(expr, ":")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.Wrapf(ErrInterpolation, "empty placeholder ${%s}", expr)
+	}
+
+	if !strings.Contains(name, ip.delim) {
+		if val, ok := os.LookupEnv(name); ok {
+			return val, nil
+		}
+		if hasDefault {
+			return ip.expand(def)
+		}
+		return nil, errors.Wrapf(ErrInterpolation, "environment variable %s not set", name)
+	}
+
+	for i, key := range ip.stack {
+		// referencing the key itself or its parent
+		if key == name || strings.HasPrefix(key, name+ip.delim) {
+			cycle := append(append([]string{}, ip.stack[i:]...), name)
+			return nil, errors.Wrapf(ErrInterpolation, "reference cycle %s", strings.Join(cycle, " -> "))
+		}
+	}
+	raw := ip.raw(name)
+	if raw == nil {
+		if hasDefault {
+			return ip.expand(def)
+		}
+		return nil, errors.Wrapf(ErrInterpolation, "referenced key %s not exists", name)
+	}
+
+	return ip.resolve(name, raw)
+}
+
+func (ip *interpolator) decrypt(value string) (interface{}, error) {
+	if ip.decrypter == nil {
+		return nil, errors.Wrap(ErrDecrypt, "no decrypter configured, see --config-key-file")
+	}
+	plaintext, err := ip.decrypter.Decrypt(value)
+	if err != nil {
+		return nil, errors.Wrap(ErrDecrypt, err.Error())
+	}
+	return plaintext, nil
+}
+
+func (ip *interpolator) join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + ip.delim + key
+}
+
+// matchBrace returns index of the } closing the placeholder starts at start, -1 if not found
+func matchBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
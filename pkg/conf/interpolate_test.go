@@ -0,0 +1,102 @@
+package conf_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/conf/secret"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+const interpolateConfData = `
+pilot:
+  base:
+    host: ${INTERPOLATE_HOST:127.0.0.1}
+    port: 6379
+  redis:
+    addr: ${pilot.base.host}:${pilot.base.port}
+    port: ${pilot.base.port}
+    password: ${INTERPOLATE_PASSWORD}
+    prefix: $${not.resolved}
+    db: ${pilot.base.db:${INTERPOLATE_DB:1}}
+`
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("INTERPOLATE_PASSWORD", "secret")
+	defer os.Unsetenv("INTERPOLATE_PASSWORD")
+
+	cfg := conf.New()
+	assert.Nil(t, cfg.LoadFromReader(bytes.NewBufferString(interpolateConfData), yaml.Unmarshal))
+	assert.Equal(t, "127.0.0.1:6379", cfg.GetString("pilot.redis.addr"))
+	assert.Equal(t, 6379, cfg.Get("pilot.redis.port"))
+	assert.Equal(t, "secret", cfg.GetString("pilot.redis.password"))
+	assert.Equal(t, "${not.resolved}", cfg.GetString("pilot.redis.prefix"))
+	assert.Equal(t, "1", cfg.GetString("pilot.redis.db"))
+
+	var redis struct {
+		Addr string
+		Port int
+	}
+	assert.Nil(t, cfg.UnmarshalKey("pilot.redis", &redis))
+	assert.Equal(t, "127.0.0.1:6379", redis.Addr)
+	assert.Equal(t, 6379, redis.Port)
+
+	// re-evaluated when referenced key changed
+	assert.Nil(t, cfg.Set("pilot.base.host", "10.0.0.1"))
+	assert.Equal(t, "10.0.0.1:6379", cfg.GetString("pilot.redis.addr"))
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	cfg := conf.New()
+	assert.Nil(t, cfg.LoadFromReader(bytes.NewBufferString(`
+a:
+  x: ${b.x}
+b:
+  x: ${a.x}
+d:
+  x: ${d}
+c:
+  x: ${INTERPOLATE_NOT_SET}
+  y: ${c.none}
+`), yaml.Unmarshal))
+
+	var v map[string]interface{}
+	err := cfg.UnmarshalKey("a", &v)
+	assert.True(t, errors.Is(err, conf.ErrInterpolation))
+	assert.True(t, strings.Contains(err.Error(), "a.x -> b.x -> a.x"), err.Error())
+
+	err = cfg.UnmarshalKey("d", &v)
+	assert.True(t, errors.Is(err, conf.ErrInterpolation))
+
+	err = cfg.UnmarshalKey("c", &v)
+	assert.True(t, errors.Is(err, conf.ErrInterpolation))
+
+	// raw value returned if interpolation failed
+	assert.Equal(t, "${c.none}", cfg.GetString("c.y"))
+}
+
+func TestDecrypt(t *testing.T) {
+	key, _ := secret.GenerateKey()
+	cipher := secret.NewCipher(secret.NewStaticKeyProvider("k1", map[string][]byte{"k1": key}))
+	password, _ := cipher.Encrypt("secret")
+
+	cfg := conf.New()
+	assert.Nil(t, cfg.Set("pilot.redis.password", password))
+	assert.Nil(t, cfg.Set("pilot.rdb.password", "${pilot.redis.password}"))
+	// no decrypter
+	assert.Equal(t, password, cfg.GetString("pilot.redis.password"))
+	var redis struct{ Password string }
+	assert.True(t, errors.Is(cfg.UnmarshalKey("pilot.redis", &redis), conf.ErrDecrypt))
+
+	cfg.SetDecrypter(cipher)
+	assert.Equal(t, "secret", cfg.GetString("pilot.redis.password"))
+	assert.Equal(t, "secret", cfg.GetString("pilot.rdb.password"))
+	assert.Nil(t, cfg.UnmarshalKey("pilot.redis", &redis))
+	assert.Equal(t, "secret", redis.Password)
+}
@@ -0,0 +1,253 @@
+package conf
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// Layer is the precedence of config source, values of higher layer override the lower ones.
+type Layer int
+
+const (
+	// LayerDefault default values
+	LayerDefault Layer = iota
+	// LayerFile local config files
+	LayerFile
+	// LayerRemote remote config center, such as etcd or nacos
+	LayerRemote
+	// LayerEnv environments loaded by LoadEnvironments
+	LayerEnv
+	// LayerFlag command line flags
+	LayerFlag
+	// LayerOverride values set by Set or Apply
+	LayerOverride
+)
+
+func (l Layer) String() string {
+	switch l {
+	case LayerDefault:
+		return "default"
+	case LayerFile:
+		return "file"
+	case LayerRemote:
+		return "remote"
+	case LayerEnv:
+		return "env"
+	case LayerFlag:
+		return "flag"
+	case LayerOverride:
+		return "override"
+	}
+	return "unknown"
+}
+
+// MarshalText marshals layer as its name
+func (l Layer) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// LayeredDataSource is implemented by data sources who declare their layer,
+// data sources without declaration are treated as LayerRemote.
+type LayeredDataSource interface {
+	DataSource
+	Layer() Layer
+}
+
+func layerOf(ds DataSource) Layer {
+	if lds, ok := ds.(LayeredDataSource); ok {
+		return lds.Layer()
+	}
+	return LayerRemote
+}
+
+// Origin describes where the value of key comes from
+type Origin struct {
+	Layer Layer `json:"layer"`
+	// Source name of the source, such as config address
+	Source string `json:"source"`
+}
+
+// source is a set of config values loaded from the same place
+type source struct {
+	layer Layer
+	name  string
+	data  map[string]interface{}
+	// ds data source of the values, nil if not loaded from data source
+	ds DataSource
+}
+
+// getSource returns the source with layer and name, creates it if not exists.
+// Sources are kept in order of layer, and of creation within the same layer.
+// must be called with c.mu held
+func (c *Configuration) getSource(layer Layer, name string) *source {
+	for _, s := range c.sources {
+		if s.layer == layer && s.name == name {
+			return s
+		}
+	}
+
+	s := &source{layer: layer, name: name, data: make(map[string]interface{})}
+	idx := len(c.sources)
+	for i, item := range c.sources {
+		if item.layer > layer {
+			idx = i
+			break
+		}
+	}
+	c.sources = append(c.sources, nil)
+	copy(c.sources[idx+1:], c.sources[idx:])
+	c.sources[idx] = s
+	return s
+}
+
+// snapshot deep copies values of all sources, must be called with c.mu held
+func (c *Configuration) snapshot() []*source {
+	sources := make([]*source, 0, len(c.sources))
+	for _, s := range c.sources {
+		data := make(map[string]interface{})
+		MergeMap(data, s.data)
+		sources = append(sources, &source{layer: s.layer, name: s.name, data: data})
+	}
+	return sources
+}
+
+// restore sets values of sources to the snapshot, sources not in snapshot are kept.
+// must be called with c.mu held
+func (c *Configuration) restore(sources []*source) {
+	for _, s := range sources {
+		data := make(map[string]interface{})
+		MergeMap(data, s.data)
+		c.getSource(s.layer, s.name).data = data
+	}
+}
+
+// setSource replaces all values of the source, keys missing in data fall back to lower layers
+func (c *Configuration) setSource(layer Layer, name string, data map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.getSource(layer, name).data = data
+	c.rebuild()
+}
+
+// SetLayer sets config value for key in the layer
+func (c *Configuration) SetLayer(layer Layer, key string, val interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	setNested(c.getSource(layer, layer.String()).data, strings.Split(key, c.keyDelim), val)
+	c.rebuild()
+	return nil
+}
+
+// SetDefault sets default value for key, which will be overridden by any other layers
+func (c *Configuration) SetDefault(key string, val interface{}) {
+	_ = c.SetLayer(LayerDefault, key, val)
+}
+
+// OriginOf returns the source which supplies the value of key
+func (c *Configuration) OriginOf(key string) (Origin, bool) {
+	paths := strings.Split(key, c.keyDelim)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for i := len(c.sources) - 1; i >= 0; i-- {
+		s := c.sources[i]
+		if _, ok := searchPath(s.data, paths); ok {
+			return Origin{Layer: s.layer, Source: s.name}, true
+		}
+	}
+	return Origin{}, false
+}
+
+// rebuild merges all sources by precedence, notifies changes of resolved values,
+// and returns changes of raw values, before interpolated and decrypted.
+// must be called with c.mu held
+func (c *Configuration) rebuild() []ChangeEvent {
+	merged := make(map[string]interface{})
+	for _, s := range c.sources {
+		MergeMap(merged, s.data)
+	}
+	c.override = merged
+
+	var (
+		raw  = c.traverse(c.keyDelim)
+		flat = make(map[string]interface{}, len(raw))
+		ip   = newInterpolator(merged, c.keyDelim, c.decrypter)
+	)
+	// values are compared after interpolation, so keys referencing the changed ones are changed too
+	for k, v := range raw {
+		resolved, err := ip.resolveKey(k, v)
+		if err != nil {
+			log.Printf("interpolate config failed: %v", err)
+			resolved = v
+		}
+		flat[k] = resolved
+	}
+	changes := diffChanges(c.flat, flat, reflect.DeepEqual)
+	rawChanges := diffChanges(c.rawFlat, raw, reflect.DeepEqual)
+	c.flat, c.rawFlat = flat, raw
+
+	// cached sub trees may be stale, drop all and cache the leaves again
+	c.keyMap.Range(func(key, _ interface{}) bool {
+		c.keyMap.Delete(key)
+		return true
+	})
+	for k, v := range flat {
+		c.keyMap.Store(k, v)
+	}
+
+	if len(changes) > 0 {
+		c.notifyChanges(changes)
+	}
+	return rawChanges
+}
+
+// MergeMap deep merges src into dest, values of src take precedence unless both are maps,
+// maps of any key type, such as decoded from yaml, are merged as string maps.
+func MergeMap(dest, src map[string]interface{}) {
+	for k, sv := range src {
+		sm, ok := toStringMap(sv)
+		if !ok {
+			dest[k] = sv
+			continue
+		}
+		dm, ok := dest[k].(map[string]interface{})
+		if !ok {
+			dm = make(map[string]interface{})
+			dest[k] = dm
+		}
+		MergeMap(dm, sm)
+	}
+}
+
+func searchPath(m map[string]interface{}, paths []string) (interface{}, bool) {
+	var cur interface{} = m
+	for _, p := range paths {
+		cm, ok := toStringMap(cur)
+		if !ok {
+			return nil, false
+		}
+		v, ok := cm[p]
+		if !ok {
+			return nil, false
+		}
+		cur = v
+	}
+	return cur, true
+}
+
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		return cast.ToStringMap(m), true
+	}
+	return nil, false
+}
+
+func sourceName(ds DataSource) string {
+	return fmt.Sprintf("%T(%p)", ds, ds)
+}
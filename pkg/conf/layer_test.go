@@ -0,0 +1,77 @@
+package conf_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/5idu/pilot/pkg/conf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type changedDataSource struct {
+	formatDataSource
+	layer   conf.Layer
+	changed chan struct{}
+}
+
+func (ds *changedDataSource) IsConfigChanged() <-chan struct{} { return ds.changed }
+func (ds *changedDataSource) Layer() conf.Layer                { return ds.layer }
+
+func TestLayers(t *testing.T) {
+	cfg := conf.New()
+	cfg.SetDefault("app.port", 8080)
+	cfg.SetDefault("app.host", "0.0.0.0")
+
+	file := &formatDataSource{format: "yaml", content: "app:\n  port: 9090\n  name: file\n"}
+	assert.Nil(t, cfg.LoadLayerFromDataSource(conf.LayerFile, "config.yaml", file, nil))
+
+	remote := &changedDataSource{
+		formatDataSource: formatDataSource{format: "yaml", content: "app:\n  name: remote\nlayertest:\n  app:\n    name: remote\n"},
+		layer:            conf.LayerRemote,
+		changed:          make(chan struct{}),
+	}
+	assert.Nil(t, cfg.LoadFromDataSource(remote, nil))
+
+	os.Setenv("LAYERTEST_APP_PORT", "7070")
+	defer os.Unsetenv("LAYERTEST_APP_PORT")
+	os.Setenv("LAYERTEST_APP_NAME", "env")
+	defer os.Unsetenv("LAYERTEST_APP_NAME")
+	cfg.LoadEnvironments("LAYERTEST_")
+	assert.Nil(t, cfg.SetLayer(conf.LayerFlag, "layertest.app.port", "6060"))
+
+	assert.Equal(t, "0.0.0.0", cfg.GetString("app.host"))
+	assert.Equal(t, 9090, cfg.GetInt("app.port"))
+	assert.Equal(t, "remote", cfg.GetString("app.name"))
+	assert.Equal(t, "6060", cfg.GetString("layertest.app.port"))
+	// env layer overrides remote layer
+	assert.Equal(t, "env", cfg.GetString("layertest.app.name"))
+
+	origin, ok := cfg.OriginOf("app.port")
+	assert.True(t, ok)
+	assert.Equal(t, conf.Origin{Layer: conf.LayerFile, Source: "config.yaml"}, origin)
+	origin, _ = cfg.OriginOf("app.host")
+	assert.Equal(t, conf.LayerDefault, origin.Layer)
+	origin, _ = cfg.OriginOf("layertest.app.port")
+	assert.Equal(t, conf.LayerFlag, origin.Layer)
+	origin, _ = cfg.OriginOf("layertest.app.name")
+	assert.Equal(t, conf.LayerEnv, origin.Layer)
+	_, ok = cfg.OriginOf("app.none")
+	assert.False(t, ok)
+
+	// key deleted in remote layer falls back to file layer
+	changed := make(chan struct{})
+	cfg.OnChange(func(*conf.Configuration) { close(changed) })
+	remote.content = "app:\n  host: 127.0.0.1\n"
+	remote.changed <- struct{}{}
+	<-changed
+	assert.Equal(t, "file", cfg.GetString("app.name"))
+	assert.Equal(t, "127.0.0.1", cfg.GetString("app.host"))
+
+	// override layer wins
+	assert.Nil(t, cfg.Set("app.port", 1))
+	assert.Equal(t, 1, cfg.GetInt("app.port"))
+	sub := cfg.Sub("app")
+	assert.Equal(t, "file", sub.GetString("name"))
+	assert.Equal(t, 1, sub.GetInt("port"))
+}
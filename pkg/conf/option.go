@@ -5,6 +5,10 @@ type (
 	GetOption  func(o *GetOptions)
 	GetOptions struct {
 		TagName string
+		// Validate validates the decoded struct with `validate` tags of go-playground/validator
+		Validate bool
+		// ErrorUnknownKeys reports keys under the subtree which are not decoded into any field
+		ErrorUnknownKeys bool
 	}
 )
 
@@ -25,3 +29,17 @@ func TagNameJSON() GetOption {
 func TagNameYAML() GetOption {
 	return TagName("yaml")
 }
+
+// ValidateStruct validates the decoded struct with `validate` tags
+func ValidateStruct() GetOption {
+	return func(o *GetOptions) {
+		o.Validate = true
+	}
+}
+
+// ErrorUnknownKeys reports keys not decoded into any field, such as typos
+func ErrorUnknownKeys() GetOption {
+	return func(o *GetOptions) {
+		o.ErrorUnknownKeys = true
+	}
+}
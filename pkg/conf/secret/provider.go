@@ -0,0 +1,187 @@
+package secret
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// KeyProvider provides data keys by id
+type KeyProvider interface {
+	// Key returns the data key with id
+	Key(ctx context.Context, id string) ([]byte, error)
+	// PrimaryKeyID returns id of the key used to encrypt
+	PrimaryKeyID() string
+}
+
+type staticKeyProvider struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// NewStaticKeyProvider constructs a KeyProvider with keys, primary is the id of key used to encrypt
+func NewStaticKeyProvider(primary string, keys map[string][]byte) KeyProvider {
+	return &staticKeyProvider{primary: primary, keys: keys}
+}
+
+// Key implements KeyProvider
+func (p *staticKeyProvider) Key(_ context.Context, id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+	return key, nil
+}
+
+// PrimaryKeyID implements KeyProvider
+func (p *staticKeyProvider) PrimaryKeyID() string {
+	return p.primary
+}
+
+// ParseKeys parses keys in format `id:base64key`, separated by comma or newline,
+// lines start with # are ignored, the first key is the primary one.
+func ParseKeys(content string) (string, map[string][]byte, error) {
+	var (
+		primary string
+		keys    = make(map[string][]byte)
+	)
+	fields := strings.FieldsFunc(content, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' })
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" || strings.HasPrefix(field, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(field, ":")
+		if !ok || id == "" {
+			return "", nil, fmt.Errorf("invalid key %q, should be id:base64key", field)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid key %s: %v", id, err)
+		}
+		if len(key) != KeySize {
+			return "", nil, fmt.Errorf("invalid key %s: size should be %d, got %d", id, KeySize, len(key))
+		}
+		if primary == "" {
+			primary = id
+		}
+		keys[id] = key
+	}
+	if primary == "" {
+		return "", nil, fmt.Errorf("%w: no keys provided", ErrKeyNotFound)
+	}
+	return primary, keys, nil
+}
+
+// FormatKey formats key as `id:base64key`, which could be parsed by ParseKeys
+func FormatKey(id string, key []byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString(key)
+}
+
+// NewFileKeyProvider constructs a KeyProvider with keys in file, see ParseKeys for the format
+func NewFileKeyProvider(path string) (KeyProvider, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	primary, keys, err := ParseKeys(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+	return NewStaticKeyProvider(primary, keys), nil
+}
+
+// NewEnvKeyProvider constructs a KeyProvider with keys in environment variable, see ParseKeys for the format
+func NewEnvKeyProvider(name string) (KeyProvider, error) {
+	primary, keys, err := ParseKeys(os.Getenv(name))
+	if err != nil {
+		return nil, fmt.Errorf("env %s: %w", name, err)
+	}
+	return NewStaticKeyProvider(primary, keys), nil
+}
+
+// KMS is the interface of key management service, which keeps master keys and wraps data keys with them
+type KMS interface {
+	Encrypt(ctx context.Context, masterKeyID string, plaintext []byte) ([]byte, error)
+	Decrypt(ctx context.Context, masterKeyID string, ciphertext []byte) ([]byte, error)
+}
+
+type kmsKeyProvider struct {
+	kms         KMS
+	masterKeyID string
+	primary     string
+	wrapped     map[string][]byte
+
+	mu   sync.Mutex
+	keys map[string][]byte
+}
+
+// NewKMSKeyProvider constructs a KeyProvider with data keys wrapped by the master key of kms,
+// data keys are unwrapped on first use and cached.
+func NewKMSKeyProvider(kms KMS, masterKeyID string, primary string, wrapped map[string][]byte) KeyProvider {
+	return &kmsKeyProvider{
+		kms:         kms,
+		masterKeyID: masterKeyID,
+		primary:     primary,
+		wrapped:     wrapped,
+		keys:        make(map[string][]byte),
+	}
+}
+
+// Key implements KeyProvider
+func (p *kmsKeyProvider) Key(ctx context.Context, id string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[id]; ok {
+		return key, nil
+	}
+	wrapped, ok := p.wrapped[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+	key, err := p.kms.Decrypt(ctx, p.masterKeyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap key %s: %w", id, err)
+	}
+	p.keys[id] = key
+	return key, nil
+}
+
+// PrimaryKeyID implements KeyProvider
+func (p *kmsKeyProvider) PrimaryKeyID() string {
+	return p.primary
+}
+
+// LocalKMS is a KMS keeping master keys in memory, stands in for a real KMS in development and tests
+type LocalKMS struct {
+	masterKeys map[string][]byte
+}
+
+// NewLocalKMS constructs a LocalKMS with master keys
+func NewLocalKMS(masterKeys map[string][]byte) *LocalKMS {
+	return &LocalKMS{masterKeys: masterKeys}
+}
+
+// Encrypt implements KMS
+func (k *LocalKMS) Encrypt(ctx context.Context, masterKeyID string, plaintext []byte) ([]byte, error) {
+	value, err := NewCipher(NewStaticKeyProvider(masterKeyID, k.masterKeys)).Encrypt(string(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// Decrypt implements KMS
+func (k *LocalKMS) Decrypt(ctx context.Context, masterKeyID string, ciphertext []byte) ([]byte, error) {
+	if id, err := KeyID(string(ciphertext)); err != nil || id != masterKeyID {
+		return nil, fmt.Errorf("%w: not wrapped by master key %s", ErrInvalidCiphertext, masterKeyID)
+	}
+	value, err := NewCipher(NewStaticKeyProvider(masterKeyID, k.masterKeys)).Decrypt(string(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
@@ -0,0 +1,129 @@
+package secret
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/5idu/pilot/pkg/util/xcrypto"
+)
+
+// Prefix of encrypted value, enc:v1:<base64>
+const Prefix = "enc:v1:"
+
+// KeySize size of data key, AES-256
+const KeySize = 32
+
+const nonceSize = 12
+
+var (
+	// ErrKeyNotFound defines an error that key id not provided by KeyProvider
+	ErrKeyNotFound = errors.New("secret key not found")
+	// ErrInvalidCiphertext defines an error that value is not a valid encrypted value
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+)
+
+// IsEncrypted reports whether value is encrypted
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// GenerateKey generates a random data key
+func GenerateKey() ([]byte, error) {
+	return xcrypto.Bytes(KeySize)
+}
+
+// Cipher encrypts and decrypts values with keys from KeyProvider.
+// The id of key is stored within encrypted value, so values encrypted by
+// the former keys could be decrypted as long as the keys are still provided.
+type Cipher struct {
+	provider KeyProvider
+}
+
+// NewCipher constructs a Cipher
+func NewCipher(provider KeyProvider) *Cipher {
+	return &Cipher{provider: provider}
+}
+
+// Encrypt encrypts plaintext with primary key
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	id := c.provider.PrimaryKeyID()
+	if len(id) == 0 || len(id) > 255 {
+		return "", fmt.Errorf("invalid primary key id %q", id)
+	}
+	aead, err := c.aead(id)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := xcrypto.Bytes(nonceSize)
+	if err != nil {
+		return "", err
+	}
+
+	// payload: len(id) | id | nonce | sealed
+	payload := make([]byte, 0, 1+len(id)+nonceSize+len(plaintext)+aead.Overhead())
+	payload = append(payload, byte(len(id)))
+	payload = append(payload, id...)
+	payload = append(payload, nonce...)
+	payload = aead.Seal(payload, nonce, []byte(plaintext), []byte(id))
+	return Prefix + base64.StdEncoding.EncodeToString(payload), nil
+}
+
+// Decrypt decrypts value with the key which encrypted it
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", ErrInvalidCiphertext
+	}
+	payload, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, Prefix))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCiphertext, err)
+	}
+	if len(payload) < 1 || len(payload) < 1+int(payload[0])+nonceSize {
+		return "", ErrInvalidCiphertext
+	}
+	idLen := int(payload[0])
+	id := string(payload[1 : 1+idLen])
+	nonce := payload[1+idLen : 1+idLen+nonceSize]
+
+	aead, err := c.aead(id)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := aead.Open(nil, nonce, payload[1+idLen+nonceSize:], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("%w: key %s: %v", ErrInvalidCiphertext, id, err)
+	}
+	return string(plaintext), nil
+}
+
+// KeyID returns id of the key which encrypted value
+func KeyID(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", ErrInvalidCiphertext
+	}
+	payload, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, Prefix))
+	if err != nil || len(payload) < 1 || len(payload) < 1+int(payload[0]) {
+		return "", ErrInvalidCiphertext
+	}
+	return string(payload[1 : 1+int(payload[0])]), nil
+}
+
+func (c *Cipher) aead(id string) (cipher.AEAD, error) {
+	key, err := c.provider.Key(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(key)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
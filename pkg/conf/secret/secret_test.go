@@ -0,0 +1,84 @@
+package secret
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCipherRotation(t *testing.T) {
+	k1, _ := GenerateKey()
+	k2, _ := GenerateKey()
+
+	old := NewCipher(NewStaticKeyProvider("k1", map[string][]byte{"k1": k1}))
+	value, err := old.Encrypt("root:secret@tcp(127.0.0.1:3306)/db")
+	assert.Nil(t, err)
+	assert.True(t, IsEncrypted(value))
+
+	// k2 becomes primary, k1 still decrypts the former values
+	primary, keys, err := ParseKeys(FormatKey("k2", k2) + "\n# rotated\n" + FormatKey("k1", k1))
+	assert.Nil(t, err)
+	rotated := NewCipher(NewStaticKeyProvider(primary, keys))
+	plaintext, err := rotated.Decrypt(value)
+	assert.Nil(t, err)
+	assert.Equal(t, "root:secret@tcp(127.0.0.1:3306)/db", plaintext)
+
+	value, _ = rotated.Encrypt("password")
+	id, _ := KeyID(value)
+	assert.Equal(t, "k2", id)
+	_, err = old.Decrypt(value)
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+
+	_, err = rotated.Decrypt(value[:len(value)-4] + "AAAA")
+	assert.True(t, errors.Is(err, ErrInvalidCiphertext))
+}
+
+func TestEnvKeyProvider(t *testing.T) {
+	key, _ := GenerateKey()
+	os.Setenv("SECRET_TEST_KEYS", FormatKey("env", key))
+	defer os.Unsetenv("SECRET_TEST_KEYS")
+
+	provider, err := NewEnvKeyProvider("SECRET_TEST_KEYS")
+	assert.Nil(t, err)
+	assert.Equal(t, "env", provider.PrimaryKeyID())
+
+	_, err = NewEnvKeyProvider("SECRET_TEST_KEYS_NOT_SET")
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+}
+
+func TestKMSKeyProvider(t *testing.T) {
+	master, _ := GenerateKey()
+	kms := NewLocalKMS(map[string][]byte{"master": master})
+
+	dataKey, _ := GenerateKey()
+	wrapped, err := kms.Encrypt(context.Background(), "master", dataKey)
+	assert.Nil(t, err)
+
+	c := NewCipher(NewKMSKeyProvider(kms, "master", "data", map[string][]byte{"data": wrapped}))
+	value, err := c.Encrypt("secret")
+	assert.Nil(t, err)
+	plaintext, err := c.Decrypt(value)
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", plaintext)
+}
+
+func TestKeyIDInvalid(t *testing.T) {
+	for _, value := range []string{
+		"plain",
+		Prefix,
+		Prefix + "!",
+		// id of 5 bytes truncated to 2
+		Prefix + base64.StdEncoding.EncodeToString([]byte{5, 'a', 'b'}),
+	} {
+		_, err := KeyID(value)
+		assert.ErrorIs(t, err, ErrInvalidCiphertext, value)
+	}
+
+	// wrapped keys are not trusted
+	_, err := NewLocalKMS(nil).Decrypt(context.Background(), "master", []byte(Prefix))
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+}
@@ -0,0 +1,157 @@
+package conf
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ChangeType type of config change
+type ChangeType int
+
+const (
+	// ChangeAdd key added
+	ChangeAdd ChangeType = iota + 1
+	// ChangeUpdate value of key updated
+	ChangeUpdate
+	// ChangeDelete key deleted
+	ChangeDelete
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case ChangeAdd:
+		return "add"
+	case ChangeUpdate:
+		return "update"
+	case ChangeDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// MarshalText marshals type as its name
+func (t ChangeType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// ChangeEvent describes the change of a leaf key
+type ChangeEvent struct {
+	Key      string      `json:"key"`
+	Type     ChangeType  `json:"type"`
+	OldValue interface{} `json:"oldValue,omitempty"`
+	NewValue interface{} `json:"newValue,omitempty"`
+}
+
+// watcher delivers events to fn one by one, in the order of changes
+type watcher struct {
+	key string
+	fn  func(ChangeEvent)
+
+	mu        sync.Mutex
+	queue     []ChangeEvent
+	running   bool
+	cancelled bool
+}
+
+// match reports whether key is the watched key or in its subtree, empty watched key matches all
+func (w *watcher) match(key, delim string) bool {
+	return w.key == "" || key == w.key || strings.HasPrefix(key, w.key+delim)
+}
+
+func (w *watcher) deliver(events []ChangeEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cancelled {
+		return
+	}
+	w.queue = append(w.queue, events...)
+	if !w.running {
+		w.running = true
+		go w.drain()
+	}
+}
+
+func (w *watcher) drain() {
+	for {
+		w.mu.Lock()
+		if len(w.queue) == 0 || w.cancelled {
+			w.running = false
+			w.mu.Unlock()
+			return
+		}
+		events := w.queue
+		w.queue = nil
+		w.mu.Unlock()
+
+		for _, ev := range events {
+			w.fn(ev)
+		}
+	}
+}
+
+func (w *watcher) cancel() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.cancelled = true
+	w.queue = nil
+}
+
+// Watch calls fn on changes of key and keys in its subtree, matched on keyDelim boundaries,
+// e.g. watching `a.b` receives changes of `a.b` and `a.b.c`, but not `a.bc`.
+// Events are delivered to fn one at a time in the order of changes.
+// The returned func stops watching.
+func (c *Configuration) Watch(key string, fn func(ev ChangeEvent)) (cancel func()) {
+	w := &watcher{key: key, fn: fn}
+	c.mu.Lock()
+	c.watchers = append(c.watchers, w)
+	c.mu.Unlock()
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, item := range c.watchers {
+			if item == w {
+				c.watchers = append(c.watchers[:i:i], c.watchers[i+1:]...)
+				break
+			}
+		}
+		w.cancel()
+	}
+}
+
+// diffChanges returns events between old and new flattened config, sorted by key
+func diffChanges(old, new map[string]interface{}, equal func(a, b interface{}) bool) []ChangeEvent {
+	var events []ChangeEvent
+	for key, val := range new {
+		orig, ok := old[key]
+		switch {
+		case !ok:
+			events = append(events, ChangeEvent{Key: key, Type: ChangeAdd, NewValue: val})
+		case !equal(orig, val):
+			events = append(events, ChangeEvent{Key: key, Type: ChangeUpdate, OldValue: orig, NewValue: val})
+		}
+	}
+	for key, orig := range old {
+		if _, ok := new[key]; !ok {
+			events = append(events, ChangeEvent{Key: key, Type: ChangeDelete, OldValue: orig})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Key < events[j].Key })
+	return events
+}
+
+// notifyChanges delivers events to the matched watchers, must be called with c.mu held
+func (c *Configuration) notifyChanges(events []ChangeEvent) {
+	for _, w := range c.watchers {
+		var matched []ChangeEvent
+		for _, ev := range events {
+			if w.match(ev.Key, c.keyDelim) {
+				matched = append(matched, ev)
+			}
+		}
+		if len(matched) > 0 {
+			w.deliver(matched)
+		}
+	}
+}
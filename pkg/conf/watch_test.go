@@ -0,0 +1,93 @@
+package conf_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/5idu/pilot/pkg/conf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []conf.ChangeEvent
+}
+
+func (r *eventRecorder) record(ev conf.ChangeEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+func (r *eventRecorder) get() []conf.ChangeEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]conf.ChangeEvent{}, r.events...)
+}
+
+func TestWatch(t *testing.T) {
+	cfg := conf.New()
+	ds := &changedDataSource{
+		formatDataSource: formatDataSource{format: "yaml", content: "a:\n  b: 1\n  bc: 1\n  d:\n    e: 1\n"},
+		layer:            conf.LayerFile,
+		changed:          make(chan struct{}),
+	}
+	assert.Nil(t, cfg.LoadFromDataSource(ds, nil))
+
+	var exact, subtree, all eventRecorder
+	cfg.Watch("a.b", exact.record)
+	cancel := cfg.Watch("a.d", subtree.record)
+	cfg.Watch("", all.record)
+
+	assert.Nil(t, cfg.Set("a.bc", 2))
+	assert.Nil(t, cfg.Set("a.b", 2))
+	assert.Nil(t, cfg.Set("a.d.f", 1))
+	// a.d.e deleted from data source
+	ds.content = "a:\n  b: 1\n  bc: 1\n"
+	ds.changed <- struct{}{}
+
+	assert.Eventually(t, func() bool { return len(subtree.get()) == 2 }, time.Second, 10*time.Millisecond)
+	cancel()
+	assert.Nil(t, cfg.Set("a.d.f", 2))
+
+	assert.Eventually(t, func() bool { return len(all.get()) == 5 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []conf.ChangeEvent{{Key: "a.b", Type: conf.ChangeUpdate, OldValue: 1, NewValue: 2}}, exact.get())
+	assert.Equal(t, []conf.ChangeEvent{
+		{Key: "a.d.f", Type: conf.ChangeAdd, NewValue: 1},
+		{Key: "a.d.e", Type: conf.ChangeDelete, OldValue: 1},
+	}, subtree.get())
+	assert.Equal(t, "a.bc", all.get()[0].Key)
+}
+
+func TestWatchSerialized(t *testing.T) {
+	cfg := conf.New()
+	var (
+		mu      sync.Mutex
+		running bool
+		values  []interface{}
+	)
+	cfg.Watch("counter", func(ev conf.ChangeEvent) {
+		mu.Lock()
+		assert.False(t, running)
+		running = true
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		running = false
+		values = append(values, ev.NewValue)
+		mu.Unlock()
+	})
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, cfg.Set("counter", i))
+	}
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(values) == 10
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []interface{}{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, values)
+}
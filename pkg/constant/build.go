@@ -0,0 +1,52 @@
+package constant
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// build info, could be injected by:
+// go build -ldflags "-X github.com/5idu/pilot/pkg/constant.buildVersion=v1.0.0 -X github.com/5idu/pilot/pkg/constant.buildCommit=xxx"
+var (
+	buildVersion string
+	buildCommit  string
+	buildTime    string
+)
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	if buildVersion == "" {
+		buildVersion = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if buildCommit == "" {
+				buildCommit = setting.Value
+			}
+		case "vcs.time":
+			if buildTime == "" {
+				buildTime = setting.Value
+			}
+		}
+	}
+}
+
+func BuildVersion() string {
+	return buildVersion
+}
+
+func BuildCommit() string {
+	return buildCommit
+}
+
+func BuildTime() string {
+	return buildTime
+}
+
+func GoVersion() string {
+	return runtime.Version()
+}
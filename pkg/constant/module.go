@@ -8,8 +8,10 @@ const (
 	ModuleClientGrpc
 	ModuleClientRedis
 	ModuleClientEtcd
+	ModuleClientConsul
 
 	ModuleRegistryEtcd
+	ModuleRegistryConsul
 
 	ModuleStoreMongoDB
 	ModuleStoreRDB
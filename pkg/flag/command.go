@@ -0,0 +1,193 @@
+package flag
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// Command is a subcommand of application selected by arguments after global flags,
+// such as `app serve`, `app migrate up` or `app job run <name>`.
+// Each command has its own flagset, which also accepts global flags, such as `app serve --watch`.
+// Global flags are applied before flags of commands.
+type Command struct {
+	// Name of command
+	Name string
+	// Usage one line summary, shown in command list of parent
+	Usage string
+	// Description detailed help, shown in usage of the command
+	Description string
+	// ArgsUsage usage of positional arguments, such as `<name>`
+	ArgsUsage string
+	// Flags of the command, parsed after the command name
+	Flags []Flag
+	// Commands subcommands, such as `up` of `app migrate up`
+	Commands []*Command
+	// Action runs the command, positional arguments are fs.Args().
+	// Commands without Action print their usage.
+	Action func(fs *FlagSet) error
+	// Hidden commands are not shown in usage, docs and completions
+	Hidden bool
+
+	flagset *FlagSet
+}
+
+// RegisterCommand registers commands of the application.
+func RegisterCommand(cmds ...*Command) {
+	flagset.RegisterCommand(cmds...)
+}
+
+// RegisterCommand registers commands to provided flagset.
+func (fs *FlagSet) RegisterCommand(cmds ...*Command) {
+	fs.commands = append(fs.commands, cmds...)
+}
+
+// SelectedCommand returns the command selected by arguments, nil if no command selected.
+func SelectedCommand() *Command {
+	return flagset.SelectedCommand()
+}
+
+// SelectedCommand returns the command selected by arguments of provided flagset.
+func (fs *FlagSet) SelectedCommand() *Command {
+	return fs.selected
+}
+
+// RunCommand runs the command selected by arguments, must be called after Parse.
+// It returns false if no command selected, the application runs as usual.
+func RunCommand() (bool, error) {
+	return flagset.RunCommand()
+}
+
+// RunCommand runs the command selected by arguments of provided flagset.
+func (fs *FlagSet) RunCommand() (bool, error) {
+	cmd := fs.selected
+	if cmd == nil {
+		return false, nil
+	}
+	if cmd.Action == nil {
+		cmd.flagset.PrintUsage()
+		return true, fmt.Errorf("missing subcommand of %q", cmd.flagset.Name())
+	}
+	return true, cmd.Action(cmd.flagset)
+}
+
+// FlagSet returns flagset of the command, nil before parsed.
+func (c *Command) FlagSet() *FlagSet {
+	return c.flagset
+}
+
+// selectCommand parses args of the command selected by args[0] and its subcommands recursively.
+// No command is selected if args[0] matches no command of the global flagset, it's left as a positional argument,
+// but unknown subcommands of a selected command are errors.
+func selectCommand(root, parent *FlagSet, cmds []*Command, args []string) (*Command, error) {
+	if len(cmds) == 0 || len(args) == 0 {
+		return nil, nil
+	}
+	var cmd *Command
+	for _, c := range cmds {
+		if c.Name == args[0] {
+			cmd = c
+			break
+		}
+	}
+	if cmd == nil {
+		if parent == root {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unknown command %q of %q", args[0], parent.Name())
+	}
+
+	fs := newFlagSet(flag.NewFlagSet(parent.path()+" "+cmd.Name, parent.ErrorHandling()), cmd.Flags)
+	fs.FlagSet.SetOutput(parent.Output())
+	fs.commands = cmd.Commands
+	fs.command = cmd
+	fs.root = root
+	cmd.flagset = fs
+	fs.apply()
+	// global flags share values, actions and config keys with the global flagset, unless redefined by the command.
+	// Their environments have been applied by the global flagset.
+	root.FlagSet.VisitAll(func(f *flag.Flag) {
+		if fs.FlagSet.Lookup(f.Name) != nil {
+			return
+		}
+		fs.FlagSet.Var(f.Value, f.Name, f.Usage)
+		fs.actions[f.Name] = root.actions[f.Name]
+		if key, ok := root.configKeys[f.Name]; ok {
+			fs.configKeys[f.Name] = key
+		}
+	})
+
+	if err := fs.FlagSet.Parse(args[1:]); err != nil {
+		return nil, err
+	}
+	if err := fs.applyEnvirons(); err != nil {
+		return nil, err
+	}
+	fs.doActions()
+
+	// positional arguments of commands without subcommands, such as `app job run <name>`
+	if len(cmd.Commands) == 0 || fs.NArg() == 0 {
+		return cmd, nil
+	}
+	return selectCommand(root, fs, cmd.Commands, fs.Args())
+}
+
+// PrintUsage prints usage of the flagset, with its commands and flags.
+func (fs *FlagSet) PrintUsage() {
+	out := fs.Output()
+	usage := fs.path() + " [flags]"
+	if len(visibleCommands(fs.commands)) > 0 {
+		usage += " <command>"
+	}
+	if fs.command != nil && fs.command.ArgsUsage != "" {
+		usage += " " + fs.command.ArgsUsage
+	}
+	fmt.Fprintf(out, "Usage: %s\n", usage)
+	if fs.command != nil {
+		if desc := fs.command.Description; desc != "" {
+			fmt.Fprintf(out, "\n%s\n", strings.TrimSpace(desc))
+		} else if fs.command.Usage != "" {
+			fmt.Fprintf(out, "\n%s\n", fs.command.Usage)
+		}
+	}
+
+	if len(visibleCommands(fs.commands)) > 0 {
+		fmt.Fprintf(out, "\nCommands:\n")
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		for _, cmd := range visibleCommands(fs.commands) {
+			fmt.Fprintf(w, "  %s\t%s\n", cmd.Name, cmd.Usage)
+		}
+		_ = w.Flush()
+	}
+
+	fmt.Fprintf(out, "\nFlags:\n")
+	fs.PrintDefaults()
+}
+
+// Root returns the global flagset, which the command flagset belongs to.
+func (fs *FlagSet) Root() *FlagSet {
+	if fs.root == nil {
+		return fs
+	}
+	return fs.root
+}
+
+// path returns the command line of the flagset, such as `app migrate up`
+func (fs *FlagSet) path() string {
+	if fs.command == nil {
+		return filepath.Base(fs.Name())
+	}
+	return fs.Name()
+}
+
+func visibleCommands(cmds []*Command) []*Command {
+	visible := make([]*Command, 0, len(cmds))
+	for _, cmd := range cmds {
+		if !cmd.Hidden {
+			visible = append(visible, cmd)
+		}
+	}
+	return visible
+}
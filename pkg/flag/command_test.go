@@ -0,0 +1,115 @@
+package flag
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFlagSet(flags ...Flag) *FlagSet {
+	fs := newFlagSet(flag.NewFlagSet("app", flag.ContinueOnError), flags)
+	fs.FlagSet.SetOutput(&bytes.Buffer{})
+	return fs
+}
+
+func TestCommand(t *testing.T) {
+	var (
+		config  string
+		applied []string
+		ran     []string
+	)
+	newFlagSet := func() *FlagSet {
+		applied, ran = nil, nil
+		fs := newTestFlagSet(&StringFlag{Name: "config", Action: func(name string, fs *FlagSet) {
+			config = fs.String(name)
+			applied = append(applied, "config")
+		}})
+		fs.RegisterCommand(
+			&Command{
+				Name:  "serve",
+				Flags: []Flag{&IntFlag{Name: "port", Default: 8080}},
+				Action: func(fs *FlagSet) error {
+					ran = append(ran, "serve")
+					assert.Equal(t, int64(9090), fs.Int("port"))
+					return nil
+				},
+			},
+			&Command{
+				Name: "migrate",
+				Commands: []*Command{
+					{Name: "up", Usage: "migrate up", Action: func(fs *FlagSet) error {
+						ran = append(ran, "migrate up")
+						return nil
+					}},
+				},
+			},
+			&Command{
+				Name: "job",
+				Commands: []*Command{{Name: "run", ArgsUsage: "<name>", Action: func(fs *FlagSet) error {
+					ran = append(ran, "job run "+fs.Arg(0))
+					return nil
+				}}},
+			},
+		)
+		return fs
+	}
+
+	// global flags before and after command
+	fs := newFlagSet()
+	assert.Nil(t, fs.parse([]string{"--config=a.yaml", "serve", "--port=9090"}))
+	assert.Equal(t, "a.yaml", config)
+	ok, err := fs.RunCommand()
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"serve"}, ran)
+
+	fs = newFlagSet()
+	assert.Nil(t, fs.parse([]string{"serve", "--port=9090", "--config=b.yaml"}))
+	assert.Equal(t, "b.yaml", config)
+	assert.Equal(t, []string{"config"}, applied)
+	assert.Equal(t, "b.yaml", fs.String("config"))
+	_, err = fs.RunCommand()
+	assert.Nil(t, err)
+
+	fs = newFlagSet()
+	assert.Nil(t, fs.parse([]string{"migrate", "up"}))
+	assert.Equal(t, "up", fs.SelectedCommand().Name)
+	_, err = fs.RunCommand()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"migrate up"}, ran)
+
+	fs = newFlagSet()
+	assert.Nil(t, fs.parse([]string{"job", "run", "cleanup"}))
+	_, err = fs.RunCommand()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"job run cleanup"}, ran)
+
+	// usage printed if subcommand missing
+	fs = newFlagSet()
+	assert.Nil(t, fs.parse([]string{"migrate"}))
+	ok, err = fs.RunCommand()
+	assert.True(t, ok)
+	assert.NotNil(t, err)
+	out := fs.SelectedCommand().FlagSet().Output().(*bytes.Buffer).String()
+	assert.Contains(t, out, "Usage: app migrate [flags] <command>")
+	assert.Contains(t, out, "up  migrate up")
+
+	// unknown subcommand of selected command
+	fs = newFlagSet()
+	assert.NotNil(t, fs.parse([]string{"migrate", "unknown"}))
+
+	// positional arguments matching no command
+	fs = newFlagSet()
+	assert.Nil(t, fs.parse([]string{"--config=c.yaml", "foo", "bar"}))
+	assert.Nil(t, fs.SelectedCommand())
+	assert.Equal(t, []string{"foo", "bar"}, fs.Args())
+
+	// no command selected
+	fs = newFlagSet()
+	assert.Nil(t, fs.parse([]string{"--config=c.yaml"}))
+	ok, err = fs.RunCommand()
+	assert.False(t, ok)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,147 @@
+package flag
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Shells supported by GenCompletion
+var Shells = []string{"bash", "zsh", "fish"}
+
+// GenCompletion writes completion script of shell for the flagset and its commands.
+func GenCompletion(w io.Writer, shell string) error { return flagset.GenCompletion(w, shell) }
+
+// GenCompletion writes completion script of shell for provided flagset and its commands.
+// Global flags are completed after every command.
+func (fs *FlagSet) GenCompletion(w io.Writer, shell string) error {
+	root := fs.docTree()
+	ew := &errWriter{w: w}
+	switch shell {
+	case "bash":
+		genBashCompletion(ew, root)
+	case "zsh":
+		genZshCompletion(ew, root)
+	case "fish":
+		genFishCompletion(ew, root)
+	default:
+		return fmt.Errorf("unsupported shell %q, should be one of %s", shell, strings.Join(Shells, ", "))
+	}
+	return ew.err
+}
+
+// completionCases returns words to complete by command path relative to the root, such as ` migrate up`
+func completionCases(root *docNode, word func(name, usage string, flag bool) string) ([]string, map[string][]string) {
+	cases := make(map[string][]string)
+	var paths []string
+	root.walk(func(n *docNode) {
+		path := strings.TrimPrefix(n.path, root.path)
+		var words []string
+		for _, child := range n.children {
+			words = append(words, word(child.command.Name, child.command.Usage, false))
+		}
+		for _, f := range n.flags {
+			words = append(words, word(f.Name, f.Usage, true))
+		}
+		if n != root {
+			for _, f := range root.flags {
+				words = append(words, word(f.Name, f.Usage, true))
+			}
+		}
+		paths = append(paths, path)
+		cases[path] = words
+	})
+	sort.Strings(paths)
+	return paths, cases
+}
+
+// funcName returns shell function name of the program
+func funcName(root *docNode) string {
+	return "_" + strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(root.path)
+}
+
+func genBashCompletion(ew *errWriter, root *docNode) {
+	paths, cases := completionCases(root, func(name, usage string, flag bool) string {
+		if flag {
+			return "--" + name
+		}
+		return name
+	})
+	fn := funcName(root)
+	ew.printf("# bash completion for %s\n", root.path)
+	ew.printf("%s() {\n", fn)
+	ew.printf("    local cur cmdpath i words\n")
+	ew.printf("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	ew.printf("    cmdpath=\"\"\n")
+	ew.printf("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	ew.printf("        case \"${COMP_WORDS[i]}\" in\n")
+	ew.printf("        -*) ;;\n")
+	ew.printf("        *) cmdpath=\"$cmdpath ${COMP_WORDS[i]}\" ;;\n")
+	ew.printf("        esac\n")
+	ew.printf("    done\n")
+	ew.printf("    case \"$cmdpath\" in\n")
+	for _, path := range paths {
+		ew.printf("    %q) words=%q ;;\n", path, strings.Join(cases[path], " "))
+	}
+	ew.printf("    esac\n")
+	ew.printf("    COMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	ew.printf("}\n")
+	ew.printf("complete -F %s %s\n", fn, root.path)
+}
+
+func genZshCompletion(ew *errWriter, root *docNode) {
+	escape := strings.NewReplacer(":", "\\:", "'", "'\\''", "\n", " ")
+	paths, cases := completionCases(root, func(name, usage string, flag bool) string {
+		if flag {
+			name = "--" + name
+		}
+		return "'" + escape.Replace(name) + ":" + escape.Replace(usage) + "'"
+	})
+	fn := funcName(root)
+	ew.printf("#compdef %s\n", root.path)
+	ew.printf("%s() {\n", fn)
+	ew.printf("    local cmdpath i\n")
+	ew.printf("    local -a opts\n")
+	ew.printf("    cmdpath=\"\"\n")
+	ew.printf("    for ((i = 2; i < CURRENT; i++)); do\n")
+	ew.printf("        case \"${words[i]}\" in\n")
+	ew.printf("        -*) ;;\n")
+	ew.printf("        *) cmdpath=\"$cmdpath ${words[i]}\" ;;\n")
+	ew.printf("        esac\n")
+	ew.printf("    done\n")
+	ew.printf("    case \"$cmdpath\" in\n")
+	for _, path := range paths {
+		ew.printf("    %q) opts=(%s) ;;\n", path, strings.Join(cases[path], " "))
+	}
+	ew.printf("    esac\n")
+	ew.printf("    _describe '%s' opts\n", root.path)
+	ew.printf("}\n")
+	ew.printf("compdef %s %s\n", fn, root.path)
+}
+
+func genFishCompletion(ew *errWriter, root *docNode) {
+	quote := func(s string) string {
+		return "'" + strings.NewReplacer("\\", "\\\\", "'", "\\'", "\n", " ").Replace(s) + "'"
+	}
+	ew.printf("# fish completion for %s\n", root.path)
+	for _, f := range root.flags {
+		ew.printf("complete -c %s -l %s -d %s\n", root.path, f.Name, quote(f.Usage))
+	}
+	root.walk(func(n *docNode) {
+		// condition of completing words of the node
+		cond := "__fish_use_subcommand"
+		if n != root {
+			cond = "__fish_seen_subcommand_from " + n.command.Name
+		}
+		for _, child := range n.children {
+			ew.printf("complete -c %s -f -n %s -a %s -d %s\n", root.path, quote(cond), child.command.Name, quote(child.command.Usage))
+		}
+		if n == root {
+			return
+		}
+		for _, f := range n.flags {
+			ew.printf("complete -c %s -n %s -l %s -d %s\n", root.path, quote(cond), f.Name, quote(f.Usage))
+		}
+	})
+}
@@ -0,0 +1,237 @@
+package flag
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// docNode is a flagset with its visible subcommands, to generate docs and completions
+type docNode struct {
+	path     string
+	command  *Command
+	flags    []flagDoc
+	children []*docNode
+}
+
+// flagDoc describes a flag
+type flagDoc struct {
+	Name    string
+	Type    string
+	Usage   string
+	Default string
+	EnvVar  string
+}
+
+// docTree returns the flagset and its visible subcommands recursively.
+// Flags of commands only include their own flags, global flags are described by the global flagset.
+func (fs *FlagSet) docTree() *docNode {
+	if fs.command != nil {
+		return describeCommand(fs.Name(), fs.command)
+	}
+	fs.apply()
+	return fs.describe()
+}
+
+func describeCommand(path string, cmd *Command) *docNode {
+	fs := newFlagSet(flag.NewFlagSet(path, flag.ContinueOnError), cmd.Flags)
+	fs.FlagSet.SetOutput(io.Discard)
+	fs.commands = cmd.Commands
+	fs.command = cmd
+	fs.apply()
+	return fs.describe()
+}
+
+func (fs *FlagSet) describe() *docNode {
+	node := &docNode{path: fs.path(), command: fs.command}
+	fs.FlagSet.VisitAll(func(f *flag.Flag) {
+		node.flags = append(node.flags, flagDoc{
+			Name:    f.Name,
+			Type:    typeName(f),
+			Usage:   f.Usage,
+			Default: f.DefValue,
+			EnvVar:  fs.envVars[f.Name],
+		})
+	})
+	for _, cmd := range visibleCommands(fs.commands) {
+		node.children = append(node.children, describeCommand(node.path+" "+cmd.Name, cmd))
+	}
+	return node
+}
+
+// walk calls fn with the node and its descendants in depth first order
+func (n *docNode) walk(fn func(*docNode)) {
+	fn(n)
+	for _, child := range n.children {
+		child.walk(fn)
+	}
+}
+
+// typeName returns type of the flag value, such as string or duration
+func typeName(f *flag.Flag) string {
+	if v, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && v.IsBoolFlag() {
+		return "bool"
+	}
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return "value"
+	}
+	switch getter.Get().(type) {
+	case bool:
+		return "bool"
+	case int, int64:
+		return "int"
+	case uint, uint64:
+		return "uint"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case time.Duration:
+		return "duration"
+	case []string:
+		return "strings"
+	case map[string]string:
+		return "map"
+	}
+	return "value"
+}
+
+// GenMarkdown writes markdown docs of the flagset and its commands.
+func GenMarkdown(w io.Writer) error { return flagset.GenMarkdown(w) }
+
+// GenMarkdown writes markdown docs of provided flagset and its commands.
+func (fs *FlagSet) GenMarkdown(w io.Writer) error {
+	ew := &errWriter{w: w}
+	fs.docTree().walk(func(n *docNode) {
+		if n.command == nil {
+			ew.printf("# %s\n\n", n.path)
+		} else {
+			ew.printf("## %s\n\n", n.path)
+			if desc := commandDescription(n.command); desc != "" {
+				ew.printf("%s\n\n", desc)
+			}
+		}
+		ew.printf("```\n%s\n```\n\n", usageLine(n))
+
+		if len(n.children) > 0 {
+			ew.printf("Commands:\n\n")
+			for _, child := range n.children {
+				ew.printf("- `%s`: %s\n", child.path, child.command.Usage)
+			}
+			ew.printf("\n")
+		}
+		if len(n.flags) > 0 {
+			ew.printf("| Flag | Type | Default | Env | Usage |\n")
+			ew.printf("| --- | --- | --- | --- | --- |\n")
+			for _, f := range n.flags {
+				ew.printf("| `--%s` | %s | %s | %s | %s |\n", f.Name, f.Type, markdownCode(f.Default), markdownCode(f.EnvVar), markdownEscape(f.Usage))
+			}
+			ew.printf("\n")
+		}
+	})
+	return ew.err
+}
+
+// GenMan writes man page of the flagset and its commands.
+func GenMan(w io.Writer) error { return flagset.GenMan(w) }
+
+// GenMan writes man page of provided flagset and its commands.
+func (fs *FlagSet) GenMan(w io.Writer) error {
+	ew := &errWriter{w: w}
+	root := fs.docTree()
+	ew.printf(".TH %s 1\n", strings.ToUpper(manEscape(strings.ReplaceAll(root.path, " ", "-"))))
+	ew.printf(".SH NAME\n%s\n", manEscape(root.path))
+	if root.command != nil {
+		if desc := commandDescription(root.command); desc != "" {
+			ew.printf(" \\- %s\n", manEscape(desc))
+		}
+	}
+	ew.printf(".SH SYNOPSIS\n.B %s\n", manEscape(usageLine(root)))
+	ew.printf(".SH OPTIONS\n")
+	writeManFlags(ew, root.flags)
+
+	if len(root.children) > 0 {
+		ew.printf(".SH COMMANDS\n")
+		for _, child := range root.children {
+			child.walk(func(n *docNode) {
+				ew.printf(".SS %s\n", manEscape(n.path))
+				if desc := commandDescription(n.command); desc != "" {
+					ew.printf("%s\n.PP\n", manEscape(desc))
+				}
+				ew.printf("Usage: %s\n", manEscape(usageLine(n)))
+				writeManFlags(ew, n.flags)
+			})
+		}
+	}
+	return ew.err
+}
+
+func writeManFlags(ew *errWriter, flags []flagDoc) {
+	for _, f := range flags {
+		ew.printf(".TP\n\\fB\\-\\-%s\\fR", manEscape(f.Name))
+		if f.Type != "bool" {
+			ew.printf("=\\fI%s\\fR", f.Type)
+		}
+		ew.printf("\n%s", manEscape(f.Usage))
+		if f.Default != "" {
+			ew.printf(" (default: %s)", manEscape(f.Default))
+		}
+		if f.EnvVar != "" {
+			ew.printf(" (env: %s)", manEscape(f.EnvVar))
+		}
+		ew.printf("\n")
+	}
+}
+
+func usageLine(n *docNode) string {
+	usage := n.path + " [flags]"
+	if len(n.children) > 0 {
+		usage += " <command>"
+	}
+	if n.command != nil && n.command.ArgsUsage != "" {
+		usage += " " + n.command.ArgsUsage
+	}
+	return usage
+}
+
+func commandDescription(cmd *Command) string {
+	if desc := strings.TrimSpace(cmd.Description); desc != "" {
+		return desc
+	}
+	return cmd.Usage
+}
+
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + s + "`"
+}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
+
+func manEscape(s string) string {
+	s = strings.NewReplacer("\\", "\\\\", "-", "\\-", "\n", " ").Replace(s)
+	// lines starting with a dot or quote are requests of roff
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = "\\&" + s
+	}
+	return s
+}
+
+// errWriter keeps the first error of writes
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
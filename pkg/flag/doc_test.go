@@ -0,0 +1,83 @@
+package flag
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newDocFlagSet() *FlagSet {
+	fs := newTestFlagSet(append([]Flag{
+		&StringFlag{Name: "config", Usage: "--config=config.yaml", EnvVar: "APP_CONFIG"},
+	}, defaultFlags...)...)
+	fs.RegisterCommand(defaultCommands...)
+	fs.RegisterCommand(&Command{
+		Name:  "migrate",
+		Usage: "migrate database",
+		Commands: []*Command{{
+			Name:  "up",
+			Usage: "migrate up | to the latest",
+			Flags: []Flag{&IntFlag{Name: "steps", Usage: "steps to migrate", Default: 1}},
+		}},
+	})
+	return fs
+}
+
+func TestGenMarkdown(t *testing.T) {
+	fs := newDocFlagSet()
+	var buf bytes.Buffer
+	assert.Nil(t, fs.GenMarkdown(&buf))
+	out := buf.String()
+	assert.Contains(t, out, "# app\n")
+	assert.Contains(t, out, "| `--config` | string |  | `APP_CONFIG` | --config=config.yaml |")
+	assert.Contains(t, out, "| `--help` | bool |")
+	assert.Contains(t, out, "- `app migrate`: migrate database")
+	assert.Contains(t, out, "## app migrate up\n\nmigrate up | to the latest")
+	assert.Contains(t, out, "| `--steps` | int | `1` |  | steps to migrate |")
+	// hidden commands are not documented
+	assert.NotContains(t, out, "completion")
+
+	buf.Reset()
+	assert.Nil(t, fs.GenMan(&buf))
+	out = buf.String()
+	assert.Contains(t, out, ".TH APP 1\n")
+	assert.Contains(t, out, ".TP\n\\fB\\-\\-config\\fR=\\fIstring\\fR\n\\-\\-config=config.yaml (env: APP_CONFIG)\n")
+	assert.Contains(t, out, ".SS app migrate up\n")
+}
+
+func TestGenCompletion(t *testing.T) {
+	fs := newDocFlagSet()
+	assert.Nil(t, fs.parse([]string{"completion", "bash"}))
+	_, err := fs.RunCommand()
+	assert.Nil(t, err)
+	out := fs.Output().(*bytes.Buffer).String()
+	assert.Contains(t, out, "complete -F _app app\n")
+	assert.Contains(t, out, `"") words="migrate --config --help" ;;`)
+	assert.Contains(t, out, `" migrate up") words="--steps --config --help" ;;`)
+
+	var buf bytes.Buffer
+	assert.Nil(t, fs.GenCompletion(&buf, "zsh"))
+	assert.Contains(t, buf.String(), `" migrate") opts=('up:migrate up | to the latest' '--config:--config=config.yaml' '--help:`)
+
+	buf.Reset()
+	assert.Nil(t, fs.GenCompletion(&buf, "fish"))
+	assert.Contains(t, buf.String(), "complete -c app -f -n '__fish_use_subcommand' -a migrate -d 'migrate database'\n")
+	assert.Contains(t, buf.String(), "complete -c app -n '__fish_seen_subcommand_from up' -l steps -d 'steps to migrate'\n")
+
+	assert.NotNil(t, fs.GenCompletion(&buf, "powershell"))
+}
+
+func TestHelpValue(t *testing.T) {
+	fs := newDocFlagSet()
+	fs.flags[1].(*HelpFlag).Action = nil
+	assert.Nil(t, fs.parse([]string{"--help=markdown"}))
+	assert.Equal(t, HelpMarkdown, fs.FlagSet.Lookup("help").Value.String())
+
+	fs = newDocFlagSet()
+	fs.flags[1].(*HelpFlag).Action = nil
+	assert.Nil(t, fs.parse([]string{"--help"}))
+	assert.Equal(t, HelpText, fs.FlagSet.Lookup("help").Value.String())
+
+	assert.NotNil(t, newDocFlagSet().parse([]string{"--help=pdf"}))
+}
@@ -6,21 +6,45 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var (
 	flagset *FlagSet
+	// configBinder writes values of flags with ConfigKey into config
+	configBinder func(key string, val interface{})
 )
 
 func init() {
 	// procName := filepath.Base(os.Args[0])
 	// nfs := flag.NewFlagSet(procName, flag.ExitOnError)
-	flagset = &FlagSet{
-		FlagSet:  flag.CommandLine,
-		flags:    defaultFlags,
-		actions:  make(map[string]func(string, *FlagSet)),
-		environs: make(map[string]string),
+	flagset = newFlagSet(flag.CommandLine, defaultFlags)
+	flagset.commands = defaultCommands
+}
+
+func newFlagSet(set *flag.FlagSet, flags []Flag) *FlagSet {
+	fs := &FlagSet{
+		FlagSet:    set,
+		flags:      flags,
+		actions:    make(map[string]func(string, *FlagSet)),
+		environs:   make(map[string]string),
+		envVars:    make(map[string]string),
+		configKeys: make(map[string]string),
 	}
+	fs.FlagSet.Usage = fs.PrintUsage
+	return fs
+}
+
+// NewFlagSet creates a flagset with flags, which returns errors of parsing instead of exiting.
+func NewFlagSet(name string, flags ...Flag) *FlagSet {
+	return newFlagSet(flag.NewFlagSet(name, flag.ContinueOnError), flags)
+}
+
+// SetConfigBinder sets the function to write values of flags with ConfigKey into config,
+// which is called after parsed and before actions of flags.
+// It is set by package conf, values are written into the flag layer of the default configuration.
+func SetConfigBinder(fn func(key string, val interface{})) {
+	configBinder = fn
 }
 
 // Flag ...
@@ -36,6 +60,21 @@ type (
 		flags    []Flag
 		actions  map[string]func(string, *FlagSet)
 		environs map[string]string
+		// envVars names of environments of flags
+		envVars map[string]string
+		// configKeys config keys of flags
+		configKeys map[string]string
+
+		// commands could be selected by arguments after flags
+		commands []*Command
+		// command the flagset belongs to, nil for the global flagset
+		command *Command
+		// root the global flagset, nil for the global flagset
+		root *FlagSet
+		// applied flags have been applied to the flagset
+		applied bool
+		// selected command selected by arguments, only set in the global flagset
+		selected *Command
 	}
 )
 
@@ -79,19 +118,76 @@ func (fs *FlagSet) Lookup(name string) *flag.Flag {
 	return flag
 }
 
-// Parse parses provided flagset.
+// Parse parses provided flagset, and the command selected by arguments after flags.
 func (fs *FlagSet) Parse() error {
+	return fs.parse(os.Args[1:])
+}
+
+// ParseArgs parses provided flagset by arguments without the program name, such as os.Args[1:].
+func (fs *FlagSet) ParseArgs(arguments []string) error {
+	return fs.parse(arguments)
+}
+
+func (fs *FlagSet) parse(arguments []string) error {
 	if fs.Parsed() {
 		return nil
 	}
+	fs.apply()
+
+	if err := fs.FlagSet.Parse(arguments); err != nil {
+		return err
+	}
+	if err := fs.applyEnvirons(); err != nil {
+		return err
+	}
+	fs.doActions()
+
+	cmd, err := selectCommand(fs, fs, fs.commands, fs.FlagSet.Args())
+	if err != nil {
+		return err
+	}
+	fs.selected = cmd
+	return nil
+}
+
+// apply applies flags to the flagset once
+func (fs *FlagSet) apply() {
+	if fs.applied {
+		return
+	}
+	fs.applied = true
 	for _, f := range fs.flags {
 		f.Apply(fs)
 	}
+}
 
-	if err := fs.FlagSet.Parse(os.Args[1:]); err != nil {
-		return err
+// applyEnvirons sets flags not given by arguments from their EnvVar,
+// which are treated as set by arguments, so their actions will be called.
+func (fs *FlagSet) applyEnvirons() error {
+	given := make(map[string]bool)
+	fs.FlagSet.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, env := range fs.environs {
+		if env == "" || given[name] {
+			continue
+		}
+		if err := fs.FlagSet.Set(name, env); err != nil {
+			return fmt.Errorf("invalid value %q of environment for flag -%s: %w", env, name, err)
+		}
 	}
+	return nil
+}
 
+// doActions writes values into config and calls actions of flags set by arguments
+func (fs *FlagSet) doActions() {
+	if configBinder != nil {
+		fs.FlagSet.Visit(func(f *flag.Flag) {
+			if key, ok := fs.configKeys[f.Name]; ok {
+				configBinder(key, flagValue(f))
+			}
+		})
+	}
 	fs.FlagSet.Visit(func(f *flag.Flag) {
 		// do action hook after parse flagset
 		if action, ok := fs.actions[f.Name]; ok && action != nil {
@@ -101,8 +197,14 @@ func (fs *FlagSet) Parse() error {
 			fs.environs[f.Name] = env
 		}
 	})
+}
 
-	return nil
+// flagValue returns typed value of flag, such as int or time.Duration
+func flagValue(f *flag.Flag) interface{} {
+	if getter, ok := f.Value.(flag.Getter); ok {
+		return getter.Get()
+	}
+	return f.Value.String()
 }
 
 // BoolE parses bool flag of the flagset with error returned.
@@ -215,6 +317,97 @@ func (fs *FlagSet) Float64(name string) float64 {
 	return ret
 }
 
+// DurationE parses duration flag of the flagset with error returned.
+func DurationE(name string) (time.Duration, error) { return flagset.DurationE(name) }
+
+// DurationE parses duration flag of provided flagset with error returned.
+func (fs *FlagSet) DurationE(name string) (time.Duration, error) {
+	flag := fs.Lookup(name)
+	if flag != nil {
+		return time.ParseDuration(flag.Value.String())
+	}
+
+	return 0, fmt.Errorf("undefined flag name: %s", name)
+}
+
+// Duration parses duration flag of the flagset.
+func Duration(name string) time.Duration { return flagset.Duration(name) }
+
+// Duration parses duration flag of provided flagset.
+func (fs *FlagSet) Duration(name string) time.Duration {
+	ret, _ := fs.DurationE(name)
+	return ret
+}
+
+// StringSliceE parses string slice flag of the flagset with error returned.
+func StringSliceE(name string) ([]string, error) { return flagset.StringSliceE(name) }
+
+// StringSliceE parses string slice flag of provided flagset with error returned.
+func (fs *FlagSet) StringSliceE(name string) ([]string, error) {
+	flag := fs.Lookup(name)
+	if flag == nil {
+		return nil, fmt.Errorf("undefined flag name: %s", name)
+	}
+	if v, ok := flag.Value.(*stringSliceValue); ok {
+		return v.Get().([]string), nil
+	}
+	return splitList(flag.Value.String()), nil
+}
+
+// StringSlice parses string slice flag of the flagset.
+func StringSlice(name string) []string { return flagset.StringSlice(name) }
+
+// StringSlice parses string slice flag of provided flagset.
+func (fs *FlagSet) StringSlice(name string) []string {
+	ret, _ := fs.StringSliceE(name)
+	return ret
+}
+
+// StringMapE parses string map flag of the flagset with error returned.
+func StringMapE(name string) (map[string]string, error) { return flagset.StringMapE(name) }
+
+// StringMapE parses string map flag of provided flagset with error returned.
+func (fs *FlagSet) StringMapE(name string) (map[string]string, error) {
+	flag := fs.Lookup(name)
+	if flag == nil {
+		return nil, fmt.Errorf("undefined flag name: %s", name)
+	}
+	if v, ok := flag.Value.(*stringMapValue); ok {
+		return v.Get().(map[string]string), nil
+	}
+	return nil, fmt.Errorf("flag %s is not a string map", name)
+}
+
+// StringMap parses string map flag of the flagset.
+func StringMap(name string) map[string]string { return flagset.StringMap(name) }
+
+// StringMap parses string map flag of provided flagset.
+func (fs *FlagSet) StringMap(name string) map[string]string {
+	ret, _ := fs.StringMapE(name)
+	return ret
+}
+
+// applyField records environment, config key and action of the field.
+func (fs *FlagSet) applyField(field, envVar, configKey string, action func(string, *FlagSet)) {
+	fs.actions[field] = action
+	if envVar != "" {
+		fs.environs[field] = os.Getenv(envVar)
+		fs.envVars[field] = envVar
+	}
+	if configKey != "" {
+		fs.configKeys[field] = configKey
+	}
+}
+
+// fields splits comma separated names of flag
+func fields(name string) []string {
+	names := strings.Split(name, ",")
+	for i := range names {
+		names[i] = strings.TrimSpace(names[i])
+	}
+	return names
+}
+
 // BoolFlag is a bool flag implements of Flag interface.
 type BoolFlag struct {
 	Name     string
@@ -222,111 +415,220 @@ type BoolFlag struct {
 	EnvVar   string
 	Default  bool
 	Variable *bool
-	Action   func(string, *FlagSet)
+	// ConfigKey writes the value into config if set by argument or EnvVar, see SetConfigBinder
+	ConfigKey string
+	Action    func(string, *FlagSet)
 }
 
 // Apply implements of Flag Apply function.
 func (f *BoolFlag) Apply(set *FlagSet) {
-	for _, field := range strings.Split(f.Name, ",") {
-		field = strings.TrimSpace(field)
+	for _, field := range fields(f.Name) {
 		if f.Variable != nil {
 			set.FlagSet.BoolVar(f.Variable, field, f.Default, f.Usage)
 		} else {
 			set.FlagSet.Bool(field, f.Default, f.Usage)
 		}
-
-		set.actions[field] = f.Action
-		set.environs[field] = os.Getenv(f.EnvVar)
+		set.applyField(field, f.EnvVar, f.ConfigKey, f.Action)
 	}
 }
 
 // StringFlag is a string flag implements of Flag interface.
 type StringFlag struct {
-	Name     string
-	Usage    string
-	EnvVar   string
-	Default  string
-	Variable *string
+	Name      string
+	Usage     string
+	EnvVar    string
+	Default   string
+	Variable  *string
+	ConfigKey string
 	// Action hooked after call fs.Parse()
 	Action func(string, *FlagSet)
 }
 
 // Apply implements of Flag Apply function.
 func (f *StringFlag) Apply(set *FlagSet) {
-	for _, field := range strings.Split(f.Name, ",") {
-		field = strings.TrimSpace(field)
+	for _, field := range fields(f.Name) {
 		if f.Variable != nil {
 			set.FlagSet.StringVar(f.Variable, field, f.Default, f.Usage)
 		} else {
 			set.FlagSet.String(field, f.Default, f.Usage)
 		}
-		set.actions[field] = f.Action
-		set.environs[field] = os.Getenv(f.EnvVar)
+		set.applyField(field, f.EnvVar, f.ConfigKey, f.Action)
 	}
 }
 
 // IntFlag is an int flag implements of Flag interface.
 type IntFlag struct {
-	Name     string
-	Usage    string
-	Default  int
-	Variable *int
-	Action   func(string, *FlagSet)
+	Name      string
+	Usage     string
+	EnvVar    string
+	Default   int
+	Variable  *int
+	ConfigKey string
+	Action    func(string, *FlagSet)
 }
 
 // Apply implements of Flag Apply function.
 func (f *IntFlag) Apply(set *FlagSet) {
-	for _, field := range strings.Split(f.Name, ",") {
-		field = strings.TrimSpace(field)
+	for _, field := range fields(f.Name) {
 		if f.Variable != nil {
 			set.FlagSet.IntVar(f.Variable, field, f.Default, f.Usage)
 		} else {
 			set.FlagSet.Int(field, f.Default, f.Usage)
 		}
-		set.actions[field] = f.Action
+		set.applyField(field, f.EnvVar, f.ConfigKey, f.Action)
+	}
+}
+
+// Int64Flag is an int64 flag implements of Flag interface.
+type Int64Flag struct {
+	Name      string
+	Usage     string
+	EnvVar    string
+	Default   int64
+	Variable  *int64
+	ConfigKey string
+	Action    func(string, *FlagSet)
+}
+
+// Apply implements of Flag Apply function.
+func (f *Int64Flag) Apply(set *FlagSet) {
+	for _, field := range fields(f.Name) {
+		if f.Variable != nil {
+			set.FlagSet.Int64Var(f.Variable, field, f.Default, f.Usage)
+		} else {
+			set.FlagSet.Int64(field, f.Default, f.Usage)
+		}
+		set.applyField(field, f.EnvVar, f.ConfigKey, f.Action)
 	}
 }
 
 // UintFlag is an uint flag implements of Flag interface.
 type UintFlag struct {
-	Name     string
-	Usage    string
-	Default  uint
-	Variable *uint
-	Action   func(string, *FlagSet)
+	Name      string
+	Usage     string
+	EnvVar    string
+	Default   uint
+	Variable  *uint
+	ConfigKey string
+	Action    func(string, *FlagSet)
 }
 
 // Apply implements of Flag Apply function.
 func (f *UintFlag) Apply(set *FlagSet) {
-	for _, field := range strings.Split(f.Name, ",") {
-		field = strings.TrimSpace(field)
+	for _, field := range fields(f.Name) {
 		if f.Variable != nil {
 			set.FlagSet.UintVar(f.Variable, field, f.Default, f.Usage)
 		} else {
 			set.FlagSet.Uint(field, f.Default, f.Usage)
 		}
-		set.actions[field] = f.Action
+		set.applyField(field, f.EnvVar, f.ConfigKey, f.Action)
 	}
 }
 
 // Float64Flag is a float flag implements of Flag interface.
 type Float64Flag struct {
-	Name     string
-	Usage    string
-	Default  float64
-	Variable *float64
-	Action   func(string, *FlagSet)
+	Name      string
+	Usage     string
+	EnvVar    string
+	Default   float64
+	Variable  *float64
+	ConfigKey string
+	Action    func(string, *FlagSet)
 }
 
 // Apply implements of Flag Apply function.
 func (f *Float64Flag) Apply(set *FlagSet) {
-	for _, field := range strings.Split(f.Name, ",") {
-		field = strings.TrimSpace(field)
+	for _, field := range fields(f.Name) {
 		if f.Variable != nil {
 			set.FlagSet.Float64Var(f.Variable, field, f.Default, f.Usage)
 		} else {
 			set.FlagSet.Float64(field, f.Default, f.Usage)
 		}
-		set.actions[field] = f.Action
+		set.applyField(field, f.EnvVar, f.ConfigKey, f.Action)
+	}
+}
+
+// DurationFlag is a duration flag implements of Flag interface, such as `--timeout=3s`.
+type DurationFlag struct {
+	Name      string
+	Usage     string
+	EnvVar    string
+	Default   time.Duration
+	Variable  *time.Duration
+	ConfigKey string
+	Action    func(string, *FlagSet)
+}
+
+// Apply implements of Flag Apply function.
+func (f *DurationFlag) Apply(set *FlagSet) {
+	for _, field := range fields(f.Name) {
+		if f.Variable != nil {
+			set.FlagSet.DurationVar(f.Variable, field, f.Default, f.Usage)
+		} else {
+			set.FlagSet.Duration(field, f.Default, f.Usage)
+		}
+		set.applyField(field, f.EnvVar, f.ConfigKey, f.Action)
+	}
+}
+
+// StringSliceFlag is a string slice flag implements of Flag interface.
+// Values are separated by comma or given repeatedly, such as `--tag=a,b --tag=c`.
+// Default is replaced by the values given.
+type StringSliceFlag struct {
+	Name      string
+	Usage     string
+	EnvVar    string
+	Default   []string
+	Variable  *[]string
+	ConfigKey string
+	Action    func(string, *FlagSet)
+	// NoSplit takes every value as a whole, values could only be given repeatedly, such as urls containing comma
+	NoSplit bool
+}
+
+// Apply implements of Flag Apply function.
+func (f *StringSliceFlag) Apply(set *FlagSet) {
+	for _, field := range fields(f.Name) {
+		value := newStringSliceValue(f.Default, f.Variable)
+		value.noSplit = f.NoSplit
+		set.FlagSet.Var(value, field, f.Usage)
+		set.applyField(field, f.EnvVar, f.ConfigKey, f.Action)
+	}
+}
+
+// StringMapFlag is a string map flag implements of Flag interface.
+// Pairs are separated by comma or given repeatedly, such as `--label=a=1,b=2 --label=c=3`.
+// Default is replaced by the pairs given.
+type StringMapFlag struct {
+	Name      string
+	Usage     string
+	EnvVar    string
+	Default   map[string]string
+	Variable  *map[string]string
+	ConfigKey string
+	Action    func(string, *FlagSet)
+}
+
+// Apply implements of Flag Apply function.
+func (f *StringMapFlag) Apply(set *FlagSet) {
+	for _, field := range fields(f.Name) {
+		set.FlagSet.Var(newStringMapValue(f.Default, f.Variable), field, f.Usage)
+		set.applyField(field, f.EnvVar, f.ConfigKey, f.Action)
+	}
+}
+
+// HelpFlag is a help flag implements of Flag interface, `--help` prints usage,
+// and `--help=<format>` prints docs in format, such as `--help=markdown`.
+type HelpFlag struct {
+	Name   string
+	Usage  string
+	Action func(string, *FlagSet)
+}
+
+// Apply implements of Flag Apply function.
+func (f *HelpFlag) Apply(set *FlagSet) {
+	for _, field := range fields(f.Name) {
+		set.FlagSet.Var(new(helpValue), field, f.Usage)
+		set.applyField(field, "", "", f.Action)
 	}
 }
@@ -0,0 +1,67 @@
+package flag
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedFlags(t *testing.T) {
+	t.Setenv("TEST_FLAG_TIMEOUT", "3s")
+	t.Setenv("TEST_FLAG_PORT", "9090")
+	t.Setenv("TEST_FLAG_DEBUG", "true")
+
+	var (
+		bound   = make(map[string]interface{})
+		actions []string
+		tags    []string
+	)
+	SetConfigBinder(func(key string, val interface{}) {
+		bound[key] = val
+	})
+	defer SetConfigBinder(nil)
+
+	fs := newTestFlagSet(
+		&IntFlag{Name: "port", Default: 8080, EnvVar: "TEST_FLAG_PORT", ConfigKey: "server.http.port"},
+		&Int64Flag{Name: "size", Default: 1 << 40},
+		&DurationFlag{Name: "timeout", Default: time.Second, EnvVar: "TEST_FLAG_TIMEOUT", ConfigKey: "server.http.timeout",
+			Action: func(name string, fs *FlagSet) {
+				actions = append(actions, name)
+			}},
+		&BoolFlag{Name: "debug", EnvVar: "TEST_FLAG_DEBUG"},
+		&StringSliceFlag{Name: "tag", Default: []string{"default"}, Variable: &tags, ConfigKey: "server.http.tags"},
+		&StringMapFlag{Name: "label", EnvVar: "TEST_FLAG_LABEL"},
+		&Float64Flag{Name: "ratio", Default: 0.5, ConfigKey: "server.http.ratio"},
+		&StringSliceFlag{Name: "addr", NoSplit: true},
+	)
+	assert.Nil(t, fs.parse([]string{"--port=7070", "--tag=a,b", "--tag=c", "--label=zone=a,idc=b",
+		"--addr=a.yaml", "--addr=http://127.0.0.1/config?keys=a,b"}))
+
+	// arguments override environments
+	assert.Equal(t, int64(7070), fs.Int("port"))
+	assert.Equal(t, int64(1<<40), fs.Int("size"))
+	assert.Equal(t, 3*time.Second, fs.Duration("timeout"))
+	assert.True(t, fs.Bool("debug"))
+	assert.Equal(t, []string{"a", "b", "c"}, tags)
+	assert.Equal(t, []string{"a", "b", "c"}, fs.StringSlice("tag"))
+	assert.Equal(t, []string{"a.yaml", "http://127.0.0.1/config?keys=a,b"}, fs.StringSlice("addr"))
+	assert.Equal(t, map[string]string{"zone": "a", "idc": "b"}, fs.StringMap("label"))
+
+	// actions of flags set by environments are called too
+	assert.Equal(t, []string{"timeout"}, actions)
+	// defaults are not written into config
+	assert.Equal(t, map[string]interface{}{
+		"server.http.port":    7070,
+		"server.http.timeout": 3 * time.Second,
+		"server.http.tags":    []string{"a", "b", "c"},
+	}, bound)
+
+	_, err := fs.StringMapE("tag")
+	assert.NotNil(t, err)
+	_, err = fs.DurationE("unknown")
+	assert.NotNil(t, err)
+
+	t.Setenv("TEST_FLAG_LABEL", "invalid")
+	assert.NotNil(t, newTestFlagSet(&StringMapFlag{Name: "label", EnvVar: "TEST_FLAG_LABEL"}).parse(nil))
+}
@@ -1,17 +1,53 @@
 package flag
 
 import (
+	"fmt"
 	"os"
+	"strings"
 )
 
 var defaultFlags = []Flag{
-	// HelpFlag prints usage of application.
-	&BoolFlag{
+	// HelpFlag prints usage of application, or docs in markdown or man format.
+	&HelpFlag{
 		Name:  "help",
-		Usage: "--help, show help information",
+		Usage: "--help, show help information, --help=markdown or --help=man generates docs",
 		Action: func(name string, fs *FlagSet) {
-			fs.PrintDefaults()
+			var err error
+			switch fs.FlagSet.Lookup(name).Value.String() {
+			case HelpMarkdown:
+				err = fs.GenMarkdown(fs.Output())
+			case HelpMan:
+				err = fs.GenMan(fs.Output())
+			case HelpText:
+				fs.PrintUsage()
+			default:
+				return
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
 			os.Exit(0)
 		},
 	},
 }
+
+var defaultCommands = []*Command{
+	// CompletionCommand prints completion script of shell.
+	{
+		Name:      "completion",
+		Usage:     "generate completion script of shell",
+		ArgsUsage: "<" + strings.Join(Shells, "|") + ">",
+		Description: `Generate completion script of shell, such as:
+
+    source <(app completion bash)`,
+		Hidden: true,
+		Action: func(fs *FlagSet) error {
+			if fs.NArg() != 1 {
+				fs.PrintUsage()
+				return fmt.Errorf("shell is required, should be one of %s", strings.Join(Shells, ", "))
+			}
+			return fs.Root().GenCompletion(fs.Output(), fs.Arg(0))
+		},
+	},
+}
@@ -0,0 +1,156 @@
+package flag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// stringSliceValue implements flag.Getter of []string
+type stringSliceValue struct {
+	value *[]string
+	// changed the default is replaced by the first value set
+	changed bool
+	// noSplit takes every value as a whole
+	noSplit bool
+}
+
+func newStringSliceValue(val []string, p *[]string) *stringSliceValue {
+	if p == nil {
+		p = new([]string)
+	}
+	*p = append([]string(nil), val...)
+	return &stringSliceValue{value: p}
+}
+
+func (s *stringSliceValue) Set(val string) error {
+	if !s.changed {
+		*s.value = nil
+		s.changed = true
+	}
+	if s.noSplit {
+		if val = strings.TrimSpace(val); val != "" {
+			*s.value = append(*s.value, val)
+		}
+		return nil
+	}
+	*s.value = append(*s.value, splitList(val)...)
+	return nil
+}
+
+func (s *stringSliceValue) Get() interface{} {
+	return append([]string(nil), *s.value...)
+}
+
+func (s *stringSliceValue) String() string {
+	if s.value == nil {
+		return ""
+	}
+	return strings.Join(*s.value, ",")
+}
+
+// stringMapValue implements flag.Getter of map[string]string
+type stringMapValue struct {
+	value   *map[string]string
+	changed bool
+}
+
+func newStringMapValue(val map[string]string, p *map[string]string) *stringMapValue {
+	if p == nil {
+		p = new(map[string]string)
+	}
+	*p = make(map[string]string, len(val))
+	for k, v := range val {
+		(*p)[k] = v
+	}
+	return &stringMapValue{value: p}
+}
+
+func (s *stringMapValue) Set(val string) error {
+	pairs := splitList(val)
+	kvs := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("invalid pair %q, should be key=value", pair)
+		}
+		kvs[k] = v
+	}
+	if !s.changed {
+		*s.value = make(map[string]string, len(kvs))
+		s.changed = true
+	}
+	for k, v := range kvs {
+		(*s.value)[k] = v
+	}
+	return nil
+}
+
+func (s *stringMapValue) Get() interface{} {
+	kvs := make(map[string]string, len(*s.value))
+	for k, v := range *s.value {
+		kvs[k] = v
+	}
+	return kvs
+}
+
+// String returns pairs sorted by key
+func (s *stringMapValue) String() string {
+	if s.value == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*s.value))
+	for k, v := range *s.value {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// splitList splits comma separated values, empty values are dropped
+func splitList(val string) []string {
+	var list []string
+	for _, v := range strings.Split(val, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+const (
+	// HelpText prints usage, by `--help`
+	HelpText = "text"
+	// HelpMarkdown prints docs in markdown, by `--help=markdown`
+	HelpMarkdown = "markdown"
+	// HelpMan prints man page, by `--help=man`
+	HelpMan = "man"
+)
+
+// helpValue is a bool-like flag value, which accepts formats of help too
+type helpValue string
+
+func (h *helpValue) IsBoolFlag() bool { return true }
+
+func (h *helpValue) Set(val string) error {
+	switch val {
+	case "true":
+		*h = HelpText
+	case "false":
+		*h = ""
+	case HelpText, HelpMarkdown, HelpMan:
+		*h = helpValue(val)
+	default:
+		return fmt.Errorf("unknown help format %q, should be one of %s, %s, %s", val, HelpText, HelpMarkdown, HelpMan)
+	}
+	return nil
+}
+
+func (h *helpValue) Get() interface{} { return string(*h) }
+
+func (h *helpValue) String() string {
+	if h == nil {
+		return ""
+	}
+	return string(*h)
+}
@@ -0,0 +1,169 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout timeout of every single check
+var DefaultTimeout = 3 * time.Second
+
+// Kind of checker
+type Kind int
+
+const (
+	// KindLiveness checker reports whether process should be restarted
+	KindLiveness Kind = iota
+	// KindReadiness checker reports whether process could serve traffic
+	KindReadiness
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindLiveness:
+		return "liveness"
+	case KindReadiness:
+		return "readiness"
+	}
+	return "unknown"
+}
+
+// Checker checks the health of a component, returns nil if healthy
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is an adapter to allow the use of ordinary functions as Checker
+type CheckerFunc func(ctx context.Context) error
+
+// Check implements Checker interface
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+type entry struct {
+	kind    Kind
+	checker Checker
+}
+
+var (
+	mu       sync.RWMutex
+	checkers = make(map[string]entry)
+)
+
+// Register registers a checker with unique name, the former one with same name will be replaced
+func Register(kind Kind, name string, checker Checker) {
+	mu.Lock()
+	defer mu.Unlock()
+	checkers[name] = entry{kind: kind, checker: checker}
+}
+
+// RegisterLiveness registers a liveness checker
+func RegisterLiveness(name string, checker Checker) {
+	Register(KindLiveness, name, checker)
+}
+
+// RegisterReadiness registers a readiness checker
+func RegisterReadiness(name string, checker Checker) {
+	Register(KindReadiness, name, checker)
+}
+
+// Unregister removes the checker by name
+func Unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(checkers, name)
+}
+
+// Names returns names of the registered checkers
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(checkers))
+	for name := range checkers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Report result of checks
+type Report struct {
+	Healthy bool `json:"healthy"`
+	// Checks result of every checker, "ok" or error message
+	Checks map[string]string `json:"checks"`
+}
+
+// CheckLiveness runs all liveness checkers
+func CheckLiveness(ctx context.Context) Report {
+	return check(ctx, func(kind Kind) bool { return kind == KindLiveness })
+}
+
+// CheckReadiness runs all readiness checkers
+func CheckReadiness(ctx context.Context) Report {
+	return check(ctx, func(kind Kind) bool { return kind == KindReadiness })
+}
+
+// Check runs all checkers
+func Check(ctx context.Context) Report {
+	return check(ctx, func(Kind) bool { return true })
+}
+
+// Healthy reports whether all checkers passed
+func Healthy(ctx context.Context) bool {
+	return Check(ctx).Healthy
+}
+
+func check(ctx context.Context, filter func(Kind) bool) Report {
+	mu.RLock()
+	selected := make(map[string]Checker)
+	for name, e := range checkers {
+		if filter(e.kind) {
+			selected[name] = e.checker
+		}
+	}
+	mu.RUnlock()
+
+	var (
+		rmu    sync.Mutex
+		wg     sync.WaitGroup
+		report = Report{Healthy: true, Checks: make(map[string]string, len(selected))}
+	)
+	for name, checker := range selected {
+		wg.Add(1)
+		go func(name string, checker Checker) {
+			defer wg.Done()
+			err := runCheck(ctx, checker)
+
+			rmu.Lock()
+			defer rmu.Unlock()
+			if err != nil {
+				report.Healthy = false
+				report.Checks[name] = err.Error()
+				return
+			}
+			report.Checks[name] = "ok"
+		}(name, checker)
+	}
+	wg.Wait()
+	return report
+}
+
+func runCheck(ctx context.Context, checker Checker) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.Check(ctx)
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
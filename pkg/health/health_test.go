@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	RegisterLiveness("live", CheckerFunc(func(ctx context.Context) error { return nil }))
+	RegisterReadiness("ready", CheckerFunc(func(ctx context.Context) error { return errors.New("not ready") }))
+	defer Unregister("live")
+	defer Unregister("ready")
+
+	live := CheckLiveness(context.Background())
+	assert.True(t, live.Healthy)
+	assert.Equal(t, map[string]string{"live": "ok"}, live.Checks)
+
+	ready := CheckReadiness(context.Background())
+	assert.False(t, ready.Healthy)
+	assert.Equal(t, "not ready", ready.Checks["ready"])
+
+	assert.False(t, Healthy(context.Background()))
+	assert.Equal(t, []string{"live", "ready"}, Names())
+
+	Unregister("ready")
+	assert.True(t, Healthy(context.Background()))
+}
+
+func TestCheckTimeout(t *testing.T) {
+	timeout := DefaultTimeout
+	DefaultTimeout = 50 * time.Millisecond
+	defer func() { DefaultTimeout = timeout }()
+
+	RegisterReadiness("slow", CheckerFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}))
+	defer Unregister("slow")
+
+	report := CheckReadiness(context.Background())
+	assert.False(t, report.Healthy)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"])
+}
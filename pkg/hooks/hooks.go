@@ -1,11 +1,24 @@
 package hooks
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
 )
 
 var (
-	globalHooks = make([][]func(), StageMax)
+	mu          sync.RWMutex
+	globalHooks = make([][]*Hook, StageMax)
+	// stageTimeouts max duration of stages, no limit if not set
+	stageTimeouts = make([]time.Duration, StageMax)
+	// seq registration sequence of hooks
+	seq    int
+	logger = defaultLogger
 )
 
 type Stage int
@@ -22,6 +35,12 @@ func (s Stage) String() string {
 		return "BeforeStop"
 	case Stage_AfterStop:
 		return "AfterStop"
+	case Stage_AfterRun:
+		return "AfterRun"
+	case Stage_BeforeRegister:
+		return "BeforeRegister"
+	case Stage_AfterUnregister:
+		return "AfterUnregister"
 	}
 
 	return "Unknown"
@@ -33,28 +52,194 @@ const (
 	Stage_BeforeRun
 	Stage_BeforeStop
 	Stage_AfterStop
+	// Stage_AfterRun servers are serving
+	Stage_AfterRun
+	// Stage_BeforeRegister servers are going to be registered into registry
+	Stage_BeforeRegister
+	// Stage_AfterUnregister servers have been unregistered from registry
+	Stage_AfterUnregister
 	StageMax
 )
 
+// continueOnError hooks of stopping stages all run even if some failed,
+// other stages stop at the first error.
+func (s Stage) continueOnError() bool {
+	return s == Stage_BeforeStop || s == Stage_AfterStop || s == Stage_AfterUnregister
+}
+
+// Hook is a named function of stage
+type Hook struct {
+	Name string
+	// Priority hooks with higher priority run first,
+	// hooks with the same priority run in reverse registration order
+	Priority int
+	// Timeout max duration of the hook, no limit if not set
+	Timeout time.Duration
+	// Fn must return once ctx done. The stage doesn't wait for a hook timeout,
+	// a hook ignoring ctx keeps running concurrently with later hooks and stages.
+	Fn func(ctx context.Context) error
+
+	seq int
+}
+
+// Option overrides the default settings of Hook
+type Option func(h *Hook)
+
+// WithPriority sets priority of hook, which defaults to 0
+func WithPriority(priority int) Option {
+	return func(h *Hook) {
+		h.Priority = priority
+	}
+}
+
+// WithTimeout sets max duration of hook
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *Hook) {
+		h.Timeout = timeout
+	}
+}
+
 // Register 注册一个defer函数
 func Register(stage Stage, fns ...func()) {
-	globalHooks[stage] = append(globalHooks[stage], fns...)
+	for _, fn := range fns {
+		if fn == nil {
+			continue
+		}
+		fn := fn
+		RegisterHook(stage, "", func(context.Context) error {
+			fn()
+			return nil
+		})
+	}
 }
 
-// Do 执行
-func Do(stage Stage) {
-	fmt.Printf("hook stage (%s)...\n", stage)
+// RegisterHook registers a named hook of stage, which is canceled by ctx if timeout.
+// fn must honor ctx, it's abandoned rather than stopped if timeout.
+func RegisterHook(stage Stage, name string, fn func(ctx context.Context) error, opts ...Option) {
+	if stage >= StageMax || fn == nil {
+		return
+	}
+	h := &Hook{Name: name, Fn: fn}
+	for _, opt := range opts {
+		opt(h)
+	}
 
+	mu.Lock()
+	defer mu.Unlock()
+	seq++
+	h.seq = seq
+	globalHooks[stage] = append(globalHooks[stage], h)
+}
+
+// SetStageTimeout sets max duration of all hooks of stage
+func SetStageTimeout(stage Stage, timeout time.Duration) {
 	if stage >= StageMax {
 		return
 	}
+	mu.Lock()
+	defer mu.Unlock()
+	stageTimeouts[stage] = timeout
+}
 
-	for i := len(globalHooks[stage]) - 1; i >= 0; i-- {
-		fn := globalHooks[stage][i]
-		if fn != nil {
-			fn()
+// SetLogger sets the function to log executions of hooks, which is set by package xlog.
+// Hooks can't import xlog, which loads its config by hooks.
+func SetLogger(fn func(stage Stage, name string, cost time.Duration, err error)) {
+	mu.Lock()
+	defer mu.Unlock()
+	logger = fn
+}
+
+// defaultLogger logs hooks by name, or the whole stage if name is empty
+func defaultLogger(stage Stage, name string, cost time.Duration, err error) {
+	if name != "" {
+		name = " " + name
+	}
+	if err != nil {
+		log.Printf("hook stage (%s)%s failed, cost %s: %v", stage, name, cost, err)
+		return
+	}
+	log.Printf("hook stage (%s)%s done, cost %s", stage, name, cost)
+}
+
+// Do 执行, errors are only logged
+func Do(stage Stage) {
+	_ = DoE(context.Background(), stage)
+}
+
+// DoE runs hooks of stage by priority, it returns the first error.
+// Hooks of stopping stages, such as BeforeStop, all run and their errors are combined.
+// Hooks are abandoned once ctx done or timeout, the stage goes on without waiting for them,
+// so hooks must return once their ctx done.
+func DoE(ctx context.Context, stage Stage) (err error) {
+	if stage >= StageMax {
+		return nil
+	}
+
+	mu.RLock()
+	hooks := append([]*Hook(nil), globalHooks[stage]...)
+	timeout := stageTimeouts[stage]
+	logf := logger
+	mu.RUnlock()
+
+	sort.SliceStable(hooks, func(i, j int) bool {
+		if hooks[i].Priority != hooks[j].Priority {
+			return hooks[i].Priority > hooks[j].Priority
 		}
+		return hooks[i].seq > hooks[j].seq
+	})
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
-	fmt.Printf("hook stage (%s)... done\n", stage)
+	start := time.Now()
+	for _, h := range hooks {
+		if e := h.run(ctx, stage, logf); e != nil {
+			err = multierr.Append(err, e)
+			if !stage.continueOnError() {
+				break
+			}
+		}
+	}
+	logf(stage, "", time.Since(start), err)
+	return err
+}
+
+func (h *Hook) run(ctx context.Context, stage Stage, logf func(Stage, string, time.Duration, error)) (err error) {
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	if ctx.Done() == nil {
+		// never canceled, such as context.Background()
+		err = h.Fn(ctx)
+	} else {
+		done := make(chan error, 1)
+		go func() {
+			done <- h.Fn(ctx)
+		}()
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			// the hook goes on in background, but the stage doesn't wait for it
+			err = ctx.Err()
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("hook %s of stage %s: %w", h.name(), stage, err)
+	}
+	logf(stage, h.name(), time.Since(start), err)
+	return err
+}
+
+func (h *Hook) name() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	return fmt.Sprintf("#%d", h.seq)
 }
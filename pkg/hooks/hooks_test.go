@@ -1,12 +1,30 @@
 package hooks
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// resetHooks clears registered hooks, which are restored on cleanup
+func resetHooks(t *testing.T) {
+	mu.Lock()
+	saved := globalHooks
+	globalHooks = make([][]*Hook, StageMax)
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		globalHooks = saved
+		mu.Unlock()
+	})
+}
+
 func TestHooksRegister(t *testing.T) {
+	resetHooks(t)
 	var str string
 	type args struct {
 		fns []func()
@@ -40,7 +58,7 @@ func TestHooksRegister(t *testing.T) {
 
 func TestHooksDo(t *testing.T) {
 	var str string
-	globalHooks[Stage_AfterLoadConfig] = append(globalHooks[Stage_AfterLoadConfig],
+	Register(Stage_AfterLoadConfig,
 		func() { str += "1," },
 		func() { str += "2," },
 		func() { str += "3," },
@@ -57,7 +75,7 @@ func TestHooksDo(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			Do(Stage_AfterLoadConfig)
-			Do(7)
+			Do(StageMax)
 			Do(Stage_BeforeRun)
 			Do(Stage_BeforeStop)
 			Do(Stage_AfterStop)
@@ -65,3 +83,84 @@ func TestHooksDo(t *testing.T) {
 		})
 	}
 }
+
+// recorder records names of hooks, which may run concurrently after abandoned
+type recorder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *recorder) add(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names = append(r.names, name)
+}
+
+func (r *recorder) reset() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := r.names
+	r.names = nil
+	return names
+}
+
+func TestDoE(t *testing.T) {
+	resetHooks(t)
+	var order recorder
+	RegisterHook(Stage_BeforeRun, "low", func(ctx context.Context) error {
+		order.add("low")
+		return nil
+	}, WithPriority(-1))
+	RegisterHook(Stage_BeforeRun, "high", func(ctx context.Context) error {
+		order.add("high")
+		return nil
+	}, WithPriority(1))
+	RegisterHook(Stage_BeforeRun, "failed", func(ctx context.Context) error {
+		order.add("failed")
+		return errors.New("not ready")
+	})
+
+	// startup stages stop at the first error
+	err := DoE(context.Background(), Stage_BeforeRun)
+	assert.ErrorContains(t, err, "hook failed of stage BeforeRun: not ready")
+	assert.Equal(t, []string{"high", "failed"}, order.reset())
+
+	// stopping stages run all hooks, and abandon hooks timeout
+	hangDone := make(chan struct{})
+	RegisterHook(Stage_AfterUnregister, "hang", func(ctx context.Context) error {
+		defer close(hangDone)
+		order.add("hang")
+		<-ctx.Done()
+		// returns later than the stage goes on
+		time.Sleep(100 * time.Millisecond)
+		order.add("hang done")
+		return nil
+	}, WithTimeout(10*time.Millisecond), WithPriority(1))
+	RegisterHook(Stage_AfterUnregister, "next", func(ctx context.Context) error {
+		order.add("next")
+		return nil
+	})
+	start := time.Now()
+	err = DoE(context.Background(), Stage_AfterUnregister)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+	<-hangDone
+	assert.Equal(t, []string{"hang", "next", "hang done"}, order.reset())
+
+	// stage timeout bounds all hooks
+	SetStageTimeout(Stage_AfterRun, 10*time.Millisecond)
+	defer SetStageTimeout(Stage_AfterRun, 0)
+	slowDone := make(chan struct{})
+	RegisterHook(Stage_AfterRun, "slow", func(ctx context.Context) error {
+		defer close(slowDone)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+	err = DoE(context.Background(), Stage_AfterRun)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	<-slowDone
+}
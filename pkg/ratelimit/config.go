@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// ModeLocal limits requests in process
+	ModeLocal = "local"
+	// ModeRedis limits requests across processes by redis
+	ModeRedis = "redis"
+
+	// AlgorithmTokenBucket allows Burst requests at most, refilled at Limit per Window
+	AlgorithmTokenBucket = "tokenbucket"
+	// AlgorithmSlidingWindow allows Limit requests in any Window
+	AlgorithmSlidingWindow = "slidingwindow"
+
+	// KeyIP limits requests by ip of client
+	KeyIP = "ip"
+	// KeyAID limits requests by aid of metadata, or header
+	KeyAID = "aid"
+	// KeyHeaderPrefix limits requests by value of header or metadata, such as header:x-user-id
+	KeyHeaderPrefix = "header:"
+)
+
+// Config config of rate limit, such as pilot.server.grpc.ratelimit
+type Config struct {
+	Enable bool
+	// Mode local or redis, local by default
+	Mode string
+	// Redis name of redis client used by redis mode, such as default of pilot.redis.default
+	Redis string
+	// Rules the first rule matched is applied
+	Rules []Rule
+}
+
+// Rule limits requests of a grpc method, or a route of echo
+type Rule struct {
+	// Name full method of grpc, such as /pkg.Service/Method, or path of echo route, such as /v1/books/:id.
+	// Name ending with * matches by prefix, such as /pkg.Service/*, and * matches all.
+	Name string
+	// Algorithm tokenbucket or slidingwindow, tokenbucket by default
+	Algorithm string
+	// Limit requests allowed in Window
+	Limit int
+	// Burst capacity of token bucket, Limit by default
+	Burst int
+	// Window 1s by default
+	Window time.Duration
+	// Key limits requests of every key separately, one of ip, aid and header:<name>,
+	// all requests share the limit if empty.
+	Key string
+}
+
+// DefaultConfig ...
+func DefaultConfig() *Config {
+	return &Config{
+		Mode:  ModeLocal,
+		Redis: "default",
+	}
+}
+
+// Validate implements conf.Validator, rules are completed with defaults
+func (config *Config) Validate() error {
+	switch config.Mode {
+	case ModeLocal, ModeRedis:
+	default:
+		return fmt.Errorf("unknown ratelimit mode %q", config.Mode)
+	}
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("name of ratelimit rule %d is empty", i)
+		}
+		if rule.Algorithm == "" {
+			rule.Algorithm = AlgorithmTokenBucket
+		}
+		if rule.Algorithm != AlgorithmTokenBucket && rule.Algorithm != AlgorithmSlidingWindow {
+			return fmt.Errorf("unknown algorithm %q of ratelimit rule %s", rule.Algorithm, rule.Name)
+		}
+		if rule.Limit <= 0 {
+			return fmt.Errorf("limit of ratelimit rule %s should be positive", rule.Name)
+		}
+		if rule.Window == 0 {
+			rule.Window = time.Second
+		}
+		if rule.Window < time.Millisecond {
+			return fmt.Errorf("window of ratelimit rule %s should be 1ms at least", rule.Name)
+		}
+		if rule.Burst == 0 {
+			rule.Burst = rule.Limit
+		}
+		if rule.Burst < 0 {
+			return fmt.Errorf("burst of ratelimit rule %s should be positive", rule.Name)
+		}
+		switch {
+		case rule.Key == "", rule.Key == KeyIP, rule.Key == KeyAID:
+		case strings.HasPrefix(rule.Key, KeyHeaderPrefix) && len(rule.Key) > len(KeyHeaderPrefix):
+		default:
+			return fmt.Errorf("unknown key %q of ratelimit rule %s", rule.Key, rule.Name)
+		}
+	}
+	return nil
+}
+
+// match returns the first rule matched by name
+func (config *Config) match(name string) *Rule {
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if rule.Name == name {
+			return rule
+		}
+		if strings.HasSuffix(rule.Name, "*") && strings.HasPrefix(name, strings.TrimSuffix(rule.Name, "*")) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// rate tokens refilled per second
+func (rule *Rule) rate() float64 {
+	return float64(rule.Limit) / rule.Window.Seconds()
+}
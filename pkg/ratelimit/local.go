@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval interval to remove idle states of local store
+const sweepInterval = time.Minute
+
+// localStore keeps the state of limits in process
+type localStore struct {
+	mu        sync.Mutex
+	states    map[string]*localState
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// localState is a token bucket, or counters of the current and previous window
+type localState struct {
+	// tokens left of token bucket, or count of the current window
+	tokens float64
+	// previous count of the previous window
+	previous float64
+	// last refill time of token bucket, or start time of the current window
+	last time.Time
+	// expire the state is idle after expire
+	expire time.Time
+}
+
+func newLocalStore() *localStore {
+	return &localStore{
+		states:    make(map[string]*localState),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (s *localStore) allow(_ context.Context, rule *Rule, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	state, ok := s.states[key]
+	if !ok {
+		state = &localState{}
+		s.states[key] = state
+	}
+	if rule.Algorithm == AlgorithmSlidingWindow {
+		return state.slidingWindow(rule, now), nil
+	}
+	return state.tokenBucket(rule, now), nil
+}
+
+func (state *localState) tokenBucket(rule *Rule, now time.Time) bool {
+	burst := float64(rule.Burst)
+	if state.last.IsZero() {
+		state.tokens = burst
+	} else if elapsed := now.Sub(state.last).Seconds(); elapsed > 0 {
+		state.tokens = math.Min(burst, state.tokens+elapsed*rule.rate())
+	}
+	state.last = now
+	state.expire = now.Add(time.Duration(burst / rule.rate() * float64(time.Second)))
+	if state.tokens < 1 {
+		return false
+	}
+	state.tokens--
+	return true
+}
+
+// slidingWindow estimates requests in the last window by counts of the current and previous fixed window
+func (state *localState) slidingWindow(rule *Rule, now time.Time) bool {
+	start := now.Truncate(rule.Window)
+	switch {
+	case start.Equal(state.last):
+	case start.Sub(state.last) == rule.Window:
+		state.previous, state.tokens = state.tokens, 0
+	default:
+		state.previous, state.tokens = 0, 0
+	}
+	state.last = start
+	state.expire = start.Add(2 * rule.Window)
+
+	weight := float64(rule.Window-now.Sub(start)) / float64(rule.Window)
+	if state.previous*weight+state.tokens >= float64(rule.Limit) {
+		return false
+	}
+	state.tokens++
+	return true
+}
+
+// sweep removes idle states, which are the same as new ones
+func (s *localStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, state := range s.states {
+		if now.After(state.expire) {
+			delete(s.states, key)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/util/xgo"
+	"github.com/5idu/pilot/pkg/xlog"
+
+	"github.com/pkg/errors"
+)
+
+// store keeps the state of limits, in process or in redis
+type store interface {
+	allow(ctx context.Context, rule *Rule, key string) (bool, error)
+}
+
+// retryInterval the first interval to retry connecting redis, doubled on every failure up to maxRetryInterval
+var (
+	retryInterval    = time.Second
+	maxRetryInterval = time.Minute
+)
+
+// Limiter limits requests by rules of config, which is reloaded on changes
+type Limiter struct {
+	key     string
+	binding *conf.Binding
+	local   *localStore
+	logger  *xlog.Logger
+
+	// redis store is connected by New and config changes, or retried in background after failed
+	mu         sync.Mutex
+	redisName  string
+	redis      *redisStore
+	redisErr   error
+	connecting bool
+	failures   int
+	retryAt    time.Time
+}
+
+// New creates a Limiter by config of key, such as pilot.server.grpc.ratelimit,
+// changes of key are applied without restarting.
+func New(key string) (*Limiter, error) {
+	l := &Limiter{
+		key:    key,
+		local:  newLocalStore(),
+		logger: xlog.With(xlog.String("mod", "ratelimit"), xlog.String("key", key)),
+	}
+	binding, err := conf.Bind(key, DefaultConfig(), conf.WithOnChange(func(_, new interface{}) {
+		l.prepare(new.(*Config))
+	}))
+	if err != nil {
+		return nil, errors.WithMessage(err, "bind ratelimit config failed")
+	}
+	l.binding = binding
+	l.prepare(l.Config())
+	return l, nil
+}
+
+// Config returns the config applied
+func (l *Limiter) Config() *Config {
+	return l.binding.Load().(*Config)
+}
+
+// Allow reports whether the request of name is allowed, name is full method of grpc, or path of echo route.
+// keyOf returns the value of rule key for the request, such as ip of client for KeyIP.
+// Requests are allowed if the state of limits is unavailable, such as redis is down.
+func (l *Limiter) Allow(ctx context.Context, name string, keyOf func(key string) string) bool {
+	config := l.Config()
+	if !config.Enable {
+		return true
+	}
+	rule := config.match(name)
+	if rule == nil {
+		return true
+	}
+
+	key := rule.Algorithm + ":" + rule.Name
+	if rule.Key != "" {
+		key += ":" + keyOf(rule.Key)
+	}
+	s, err := l.store(config)
+	if err == nil {
+		var allowed bool
+		if allowed, err = s.allow(ctx, rule, key); err == nil {
+			return allowed
+		}
+	}
+	l.logger.Warn("ratelimit unavailable, request allowed", xlog.String("name", name), xlog.FieldErr(err))
+	return true
+}
+
+// Close stops reloading config, redis clients are shared and kept open
+func (l *Limiter) Close() {
+	l.binding.Close()
+}
+
+// prepare connects redis of config before requests arrive
+func (l *Limiter) prepare(config *Config) {
+	if config.Mode != ModeRedis {
+		return
+	}
+	l.mu.Lock()
+	ok := l.reconnect(config.Redis)
+	l.mu.Unlock()
+	if ok {
+		l.connect(config.Redis)
+	}
+}
+
+// store never blocks on connecting redis, it's retried in background instead
+func (l *Limiter) store(config *Config) (store, error) {
+	if config.Mode != ModeRedis {
+		return l.local, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if config.Redis == l.redisName && l.redis != nil {
+		return l.redis, nil
+	}
+	if l.reconnect(config.Redis) {
+		xgo.Go(func() { l.connect(config.Redis) })
+	}
+	if l.redisErr != nil {
+		return nil, l.redisErr
+	}
+	return nil, errors.Errorf("redis %s of ratelimit is connecting", config.Redis)
+}
+
+// reconnect marks redis of name connecting, returns false if it's connected, connecting or waiting to retry.
+// l.mu must be held.
+func (l *Limiter) reconnect(name string) bool {
+	if name == l.redisName && (l.redis != nil || l.connecting || time.Now().Before(l.retryAt)) {
+		return false
+	}
+	if name != l.redisName {
+		l.failures, l.redisErr = 0, nil
+	}
+	l.redisName, l.redis, l.connecting = name, nil, true
+	return true
+}
+
+func (l *Limiter) connect(name string) {
+	s, err := newRedisStore(name, l.key)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// redis of config changed while connecting
+	if name != l.redisName {
+		return
+	}
+	l.connecting = false
+	if err != nil {
+		delay := maxRetryInterval
+		if l.failures < 16 && retryInterval<<l.failures < maxRetryInterval {
+			delay = retryInterval << l.failures
+		}
+		l.failures++
+		l.redisErr, l.retryAt = err, time.Now().Add(delay)
+		l.logger.Warn("connect redis of ratelimit failed", xlog.String("redis", name), xlog.Duration("retry", delay), xlog.FieldErr(err))
+		return
+	}
+	l.redis, l.redisErr, l.failures, l.retryAt = s, nil, 0, time.Time{}
+}
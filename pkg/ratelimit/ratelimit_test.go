@@ -0,0 +1,209 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/5idu/pilot/pkg/client/redis"
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/constant"
+	"github.com/5idu/pilot/pkg/singleton"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	config := DefaultConfig()
+	config.Rules = []Rule{{Name: "/pkg.Service/*", Limit: 10}}
+	assert.Nil(t, config.Validate())
+	assert.Equal(t, Rule{Name: "/pkg.Service/*", Algorithm: AlgorithmTokenBucket, Limit: 10, Burst: 10, Window: time.Second}, config.Rules[0])
+	assert.Equal(t, &config.Rules[0], config.match("/pkg.Service/Method"))
+	assert.Nil(t, config.match("/pkg.Other/Method"))
+
+	for _, rule := range []Rule{
+		{Limit: 10},
+		{Name: "*", Limit: 0},
+		{Name: "*", Limit: 10, Algorithm: "leakybucket"},
+		{Name: "*", Limit: 10, Key: "cookie"},
+		{Name: "*", Limit: 10, Key: KeyHeaderPrefix},
+	} {
+		config.Rules = []Rule{rule}
+		assert.NotNil(t, config.Validate(), rule)
+	}
+	config.Rules, config.Mode = nil, "cluster"
+	assert.NotNil(t, config.Validate())
+}
+
+func TestLocalStore(t *testing.T) {
+	s := newLocalStore()
+	now := time.Now().Truncate(time.Second)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	bucket := &Rule{Algorithm: AlgorithmTokenBucket, Limit: 2, Burst: 4, Window: time.Second}
+	for i := 0; i < 4; i++ {
+		allowed, _ := s.allow(ctx, bucket, "bucket")
+		assert.True(t, allowed)
+	}
+	allowed, _ := s.allow(ctx, bucket, "bucket")
+	assert.False(t, allowed)
+	// refilled 2 tokens per second
+	now = now.Add(500 * time.Millisecond)
+	allowed, _ = s.allow(ctx, bucket, "bucket")
+	assert.True(t, allowed)
+	allowed, _ = s.allow(ctx, bucket, "bucket")
+	assert.False(t, allowed)
+
+	window := &Rule{Algorithm: AlgorithmSlidingWindow, Limit: 4, Window: time.Second}
+	for i := 0; i < 4; i++ {
+		allowed, _ := s.allow(ctx, window, "window")
+		assert.True(t, allowed)
+	}
+	allowed, _ = s.allow(ctx, window, "window")
+	assert.False(t, allowed)
+	// 3 of previous window are counted at 250ms of next window
+	now = now.Add(750 * time.Millisecond)
+	allowed, _ = s.allow(ctx, window, "window")
+	assert.True(t, allowed)
+	allowed, _ = s.allow(ctx, window, "window")
+	assert.False(t, allowed)
+
+	// idle states are removed
+	now = now.Add(sweepInterval)
+	allowed, _ = s.allow(ctx, window, "window")
+	assert.True(t, allowed)
+	assert.Len(t, s.states, 1)
+}
+
+// newConfiguration replaces the default Configuration in test, it's restored on cleanup
+func newConfiguration(t *testing.T) *conf.Configuration {
+	previous := conf.Default()
+	c := conf.New()
+	conf.SetDefaultConfiguration(c)
+	t.Cleanup(func() { conf.SetDefaultConfiguration(previous) })
+	return c
+}
+
+func TestLimiter(t *testing.T) {
+	c := newConfiguration(t)
+	key := "test.ratelimit.local"
+	require.NoError(t, c.Set(key+".enable", true))
+	require.NoError(t, c.Set(key+".rules", []map[string]interface{}{
+		{"name": "/pkg.Service/*", "limit": 1, "window": "1m", "key": KeyIP},
+	}))
+	l, err := New(key)
+	require.NoError(t, err)
+	defer l.Close()
+
+	ip := func(ip string) func(string) string {
+		return func(key string) string {
+			assert.Equal(t, KeyIP, key)
+			return ip
+		}
+	}
+	ctx := context.Background()
+	assert.True(t, l.Allow(ctx, "/pkg.Service/Method", ip("127.0.0.1")))
+	assert.False(t, l.Allow(ctx, "/pkg.Service/Method", ip("127.0.0.1")))
+	assert.True(t, l.Allow(ctx, "/pkg.Service/Method", ip("127.0.0.2")))
+	assert.True(t, l.Allow(ctx, "/pkg.Other/Method", ip("127.0.0.1")))
+
+	// changes applied without restarting
+	require.NoError(t, c.Set(key+".enable", false))
+	assert.Eventually(t, func() bool { return !l.Config().Enable }, time.Second, 10*time.Millisecond)
+	assert.True(t, l.Allow(ctx, "/pkg.Service/Method", ip("127.0.0.1")))
+
+	// invalid config is rejected
+	rejected := make(chan string, 1)
+	c.OnRejected(func(key string, _ error) { rejected <- key })
+	require.NoError(t, c.Set(key+".mode", "cluster"))
+	select {
+	case k := <-rejected:
+		assert.Equal(t, key, k)
+	case <-time.After(time.Second):
+		t.Error("invalid config not rejected")
+	}
+	assert.Equal(t, ModeLocal, l.Config().Mode)
+
+	// invalid config fails to create limiter
+	_, err = New(key)
+	assert.Error(t, err)
+}
+
+// redisConfig configures redis of name at addr, the shared client is dropped on cleanup
+func redisConfig(t *testing.T, c *conf.Configuration, name string, addr string) {
+	key := constant.ConfigKey("redis", name)
+	require.NoError(t, c.Set(key, map[string]interface{}{"addr": addr, "dialTimeout": "100ms"}))
+	t.Cleanup(func() {
+		if client, err := redis.StdConfig(name).Singleton(); err == nil {
+			client.Close()
+		}
+		singleton.Store(constant.ModuleClientRedis, key, nil)
+	})
+}
+
+func TestLimiterRedis(t *testing.T) {
+	c := newConfiguration(t)
+	mr := miniredis.RunT(t)
+	redisConfig(t, c, "ratelimit", mr.Addr())
+
+	key := "test.ratelimit.redis"
+	require.NoError(t, c.Set(key, map[string]interface{}{
+		"enable": true,
+		"mode":   ModeRedis,
+		"redis":  "ratelimit",
+		"rules": []map[string]interface{}{
+			{"name": "/bucket", "limit": 2, "window": "1m"},
+			{"name": "/window", "algorithm": AlgorithmSlidingWindow, "limit": 2, "window": "1m"},
+		},
+	}))
+	l, err := New(key)
+	require.NoError(t, err)
+	defer l.Close()
+
+	ctx := context.Background()
+	for _, name := range []string{"/bucket", "/window"} {
+		assert.True(t, l.Allow(ctx, name, nil), name)
+		assert.True(t, l.Allow(ctx, name, nil), name)
+		assert.False(t, l.Allow(ctx, name, nil), name)
+	}
+	assert.True(t, mr.Exists("ratelimit:"+key+":"+AlgorithmTokenBucket+":/bucket"))
+
+	// requests are allowed if redis is down
+	mr.Close()
+	assert.True(t, l.Allow(ctx, "/bucket", nil))
+}
+
+func TestLimiterRedisDown(t *testing.T) {
+	interval := retryInterval
+	retryInterval = 50 * time.Millisecond
+	t.Cleanup(func() { retryInterval = interval })
+
+	c := newConfiguration(t)
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+	redisConfig(t, c, "ratelimit-down", addr)
+
+	key := "test.ratelimit.down"
+	require.NoError(t, c.Set(key, map[string]interface{}{
+		"enable": true,
+		"mode":   ModeRedis,
+		"redis":  "ratelimit-down",
+		"rules":  []map[string]interface{}{{"name": "*", "limit": 1, "window": "1m"}},
+	}))
+	// redis is down at startup
+	l, err := New(key)
+	require.NoError(t, err)
+	defer l.Close()
+
+	ctx := context.Background()
+	assert.True(t, l.Allow(ctx, "/a", nil))
+	assert.True(t, l.Allow(ctx, "/a", nil))
+
+	// connected in background once redis is up
+	require.NoError(t, mr.Restart())
+	assert.Eventually(t, func() bool { return !l.Allow(ctx, "/a", nil) }, 2*time.Second, 20*time.Millisecond)
+}
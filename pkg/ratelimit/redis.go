@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/5idu/pilot/pkg/client/redis"
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/constant"
+
+	"github.com/pkg/errors"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// time of redis is used by scripts, so that clocks of processes don't matter
+var (
+	// tokenBucketScript KEYS[1] hash of tokens and last refill time, ARGV rate per second and burst
+	tokenBucketScript = goredis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call("time")
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+local state = redis.call("hmget", KEYS[1], "tokens", "last")
+local tokens = tonumber(state[1])
+local last = tonumber(state[2])
+if tokens == nil or last == nil then
+	tokens = burst
+elseif now > last then
+	tokens = math.min(burst, tokens + (now - last) * rate)
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call("hset", KEYS[1], "tokens", tostring(tokens), "last", tostring(now))
+redis.call("pexpire", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return allowed
+`)
+
+	// slidingWindowScript KEYS[1] hash of counts by index of fixed window, ARGV limit and window in milliseconds
+	slidingWindowScript = goredis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local t = redis.call("time")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local index = math.floor(now / window)
+local counts = redis.call("hmget", KEYS[1], tostring(index), tostring(index - 1))
+local current = tonumber(counts[1]) or 0
+local previous = tonumber(counts[2]) or 0
+local weight = (window - now % window) / window
+if previous * weight + current >= limit then
+	return 0
+end
+redis.call("hincrby", KEYS[1], tostring(index), 1)
+for _, field in ipairs(redis.call("hkeys", KEYS[1])) do
+	if tonumber(field) < index - 1 then
+		redis.call("hdel", KEYS[1], field)
+	end
+end
+redis.call("pexpire", KEYS[1], window * 2)
+return 1
+`)
+)
+
+// redisStore keeps the state of limits in redis, shared by processes
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// newRedisStore creates store by the shared redis client of name, keys are prefixed by key of limiter
+func newRedisStore(name string, key string) (s *redisStore, err error) {
+	// building redis client panics if it failed to ping
+	defer func() {
+		if r := recover(); r != nil {
+			s, err = nil, errors.Errorf("build redis %s of ratelimit failed: %v", name, r)
+		}
+	}()
+	if !conf.Exists(constant.ConfigKey("redis", name)) {
+		return nil, errors.Errorf("redis %s of ratelimit not configured", name)
+	}
+	client, err := redis.StdConfig(name).Singleton()
+	if err != nil {
+		return nil, errors.WithMessage(err, "build redis of ratelimit failed")
+	}
+	return &redisStore{client: client, prefix: "ratelimit:" + key + ":"}, nil
+}
+
+func (s *redisStore) allow(ctx context.Context, rule *Rule, key string) (bool, error) {
+	var (
+		allowed int
+		err     error
+	)
+	keys := []string{s.prefix + key}
+	if rule.Algorithm == AlgorithmSlidingWindow {
+		allowed, err = slidingWindowScript.Run(ctx, s.client.CmdOnMaster(), keys, rule.Limit, rule.Window.Milliseconds()).Int()
+	} else {
+		allowed, err = tokenBucketScript.Run(ctx, s.client.CmdOnMaster(), keys, rule.rate(), rule.Burst).Int()
+	}
+	if err != nil {
+		return false, err
+	}
+	return allowed == 1, nil
+}
@@ -0,0 +1,115 @@
+package consul
+
+import (
+	"time"
+
+	"github.com/5idu/pilot/pkg/client/consul"
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/constant"
+	"github.com/5idu/pilot/pkg/registry"
+	"github.com/5idu/pilot/pkg/singleton"
+	"github.com/5idu/pilot/pkg/xlog"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// CheckTTL services report their health to consul before TTL elapsed
+	CheckTTL = "ttl"
+	// CheckHTTP consul probes http services by HTTPCheckPath, and grpc services by grpc health checking protocol
+	CheckHTTP = "http"
+)
+
+// StdConfig ...
+func StdConfig(name string) *Config {
+	return RawConfig(constant.ConfigKey("registry." + name))
+}
+
+// RawConfig ...
+func RawConfig(key string) *Config {
+	var config = DefaultConfig()
+	// 解析最外层配置
+	if err := conf.UnmarshalKey(key, &config); err != nil {
+		xlog.Panic("unmarshal key", xlog.String("mod", "registry.consul"), xlog.Any("error", err), xlog.String("key", key), xlog.Any("config", config))
+	}
+	// 解析嵌套配置
+	if err := conf.UnmarshalKey(key, &config.Config); err != nil {
+		xlog.Panic("unmarshal key", xlog.String("mod", "registry.consul"), xlog.Any("error", err), xlog.String("key", key), xlog.Any("config", config))
+	}
+	return config
+}
+
+// DefaultConfig ...
+func DefaultConfig() *Config {
+	return &Config{
+		Config:                         consul.DefaultConfig(),
+		ReadTimeout:                    time.Second * 3,
+		Check:                          CheckTTL,
+		ServiceTTL:                     time.Second * 15,
+		HTTPCheckPath:                  "/healthz",
+		CheckInterval:                  time.Second * 10,
+		CheckTimeout:                   time.Second * 3,
+		DeregisterCriticalServiceAfter: time.Minute,
+		logger:                         xlog.With(xlog.String("mod", "registry.consul")),
+	}
+}
+
+// Config ...
+type Config struct {
+	*consul.Config
+	ReadTimeout time.Duration
+	ConfigKey   string
+	// Check type of health check, ttl or http
+	Check string
+	// ServiceTTL ttl of CheckTTL, refreshed every 1/3 of it
+	ServiceTTL time.Duration
+	// HTTPCheckPath, CheckInterval and CheckTimeout of CheckHTTP
+	HTTPCheckPath string
+	CheckInterval time.Duration
+	CheckTimeout  time.Duration
+	// DeregisterCriticalServiceAfter services critical for the duration are deregistered by consul
+	DeregisterCriticalServiceAfter time.Duration
+	// Tags extra tags of services, scheme of service is always tagged
+	Tags   []string
+	logger *xlog.Logger
+}
+
+// Build ...
+func (config Config) Build() (registry.Registry, error) {
+	if config.ConfigKey != "" {
+		config.Config = consul.RawConfig(config.ConfigKey)
+	}
+	return newConsulRegistry(&config)
+}
+
+func (config Config) MustBuild() registry.Registry {
+	reg, err := config.Build()
+	if err != nil {
+		xlog.Panic("build registry failed", zap.Error(err))
+	}
+	return reg
+}
+
+func (config *Config) Singleton() (registry.Registry, error) {
+	if val, ok := singleton.Load(constant.ModuleRegistryConsul, config.ConfigKey); ok {
+		return val.(registry.Registry), nil
+	}
+
+	reg, err := config.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	singleton.Store(constant.ModuleRegistryConsul, config.ConfigKey, reg)
+
+	return reg, nil
+}
+
+func (config *Config) MustSingleton() registry.Registry {
+	reg, err := config.Singleton()
+	if err != nil {
+		xlog.Panic("build registry failed", zap.Error(err))
+	}
+
+	return reg
+}
@@ -0,0 +1,11 @@
+package consul
+
+import (
+	"github.com/5idu/pilot/pkg/registry"
+)
+
+func init() {
+	registry.RegisterBuilder("consul", func(confKey string) registry.Registry {
+		return RawConfig(confKey).MustBuild()
+	})
+}
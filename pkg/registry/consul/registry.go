@@ -0,0 +1,359 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/5idu/pilot/pkg/client/consul"
+	"github.com/5idu/pilot/pkg/registry"
+	"github.com/5idu/pilot/pkg/server"
+	"github.com/5idu/pilot/pkg/util/xgo"
+	"github.com/5idu/pilot/pkg/xlog"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// metaID and metaHostname reserved service meta keys, which keep fields of ServiceInfo
+	metaID       = "pilot_id"
+	metaHostname = "pilot_hostname"
+	// retryInterval interval to retry blocking queries after failed
+	retryInterval = 3 * time.Second
+)
+
+type consulRegistry struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	client *consul.Client
+	*Config
+	// services registered by service id
+	services sync.Map
+
+	once sync.Once
+}
+
+var _ registry.Registry = new(consulRegistry)
+
+func newConsulRegistry(config *Config) (*consulRegistry, error) {
+	if config.logger == nil {
+		config.logger = xlog.With(xlog.String("mod", "registry.consul"))
+	}
+	if config.Check != CheckTTL && config.Check != CheckHTTP {
+		return nil, fmt.Errorf("unknown check type of consul registry: %s", config.Check)
+	}
+	client, err := config.Config.Singleton()
+	if err != nil {
+		config.logger.Error("create consul client error", xlog.FieldExtra(map[string]interface{}{"error": err.Error(), "addr": config.Config.Address}))
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &consulRegistry{
+		ctx:    ctx,
+		cancel: cancel,
+		client: client,
+		Config: config,
+	}, nil
+}
+
+func (reg *consulRegistry) Kind() string { return "consul" }
+
+// RegisterService registers service to consul agent, with a health check of the service
+func (reg *consulRegistry) RegisterService(ctx context.Context, info *server.ServiceInfo) error {
+	ctx, cancel := reg.withTimeout(ctx)
+	defer cancel()
+
+	service, err := reg.registration(info)
+	if err != nil {
+		return err
+	}
+	if err := reg.client.Agent().ServiceRegisterOpts(service, api.ServiceRegisterOpts{ReplaceExistingChecks: true}.WithContext(ctx)); err != nil {
+		reg.logger.Error("register service", xlog.FieldExtra(map[string]interface{}{"error": err.Error(), "id": service.ID}))
+		return err
+	}
+	reg.services.Store(service.ID, service)
+
+	if reg.Check == CheckTTL {
+		// pass the check right now, instead of waiting for the first keepalive
+		if err := reg.passTTL(ctx, service.ID); err != nil {
+			reg.logger.Error("update ttl", xlog.FieldExtra(map[string]interface{}{"error": err.Error(), "id": service.ID}))
+			return err
+		}
+		reg.once.Do(func() {
+			xgo.Go(reg.doKeepalive)
+		})
+	}
+
+	reg.logger.Info("register service", xlog.FieldExtra(map[string]interface{}{"id": service.ID, "service": service.Name, "address": info.Address}))
+	return nil
+}
+
+// UnregisterService deregisters service from consul agent
+func (reg *consulRegistry) UnregisterService(ctx context.Context, info *server.ServiceInfo) error {
+	return reg.deregister(ctx, serviceID(info))
+}
+
+// GetService gets service registered with key `scheme:name/address`
+func (reg *consulRegistry) GetService(ctx context.Context, key string) (*server.ServiceInfo, error) {
+	idx := strings.LastIndex(key, "/")
+	if idx < 0 {
+		return nil, fmt.Errorf("invalid service key: %s", key)
+	}
+	services, err := reg.ListServices(ctx, key[:idx+1])
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services {
+		if service.Address == key[idx+1:] {
+			return service, nil
+		}
+	}
+	return nil, fmt.Errorf("service not found: %s", key)
+}
+
+// ListServices lists passing services with prefix `scheme:name/`
+func (reg *consulRegistry) ListServices(ctx context.Context, prefix string) ([]*server.ServiceInfo, error) {
+	scheme, name := parsePrefix(prefix)
+	entries, _, err := reg.client.Health().Service(name, scheme, true, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		reg.logger.Error("list services", xlog.FieldExtra(map[string]interface{}{"error": err.Error(), "prefix": prefix}))
+		return nil, err
+	}
+
+	services := make([]*server.ServiceInfo, 0, len(entries))
+	for _, entry := range entries {
+		services = append(services, serviceInfo(scheme, entry))
+	}
+	return services, nil
+}
+
+// WatchServices watches passing services with prefix `scheme:name/` by blocking queries
+func (reg *consulRegistry) WatchServices(ctx context.Context, prefix string) (chan registry.Endpoints, error) {
+	scheme, name := parsePrefix(prefix)
+	entries, meta, err := reg.client.Health().Service(name, scheme, true, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		reg.logger.Error("watch services", xlog.FieldExtra(map[string]interface{}{"error": err.Error(), "prefix": prefix}))
+		return nil, err
+	}
+
+	var addresses = make(chan registry.Endpoints, 10)
+	addresses <- *endpoints(scheme, entries)
+
+	xgo.Go(func() {
+		waitIndex := meta.LastIndex
+		for {
+			q := &api.QueryOptions{WaitIndex: waitIndex}
+			entries, meta, err := reg.client.Health().Service(name, scheme, true, q.WithContext(reg.ctx))
+			if reg.ctx.Err() != nil || ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				reg.logger.Error("watch services", xlog.FieldExtra(map[string]interface{}{"error": err.Error(), "prefix": prefix}))
+				select {
+				case <-reg.ctx.Done():
+					return
+				case <-ctx.Done():
+					return
+				case <-time.After(retryInterval):
+				}
+				continue
+			}
+			// blocking query returns after wait time elapsed even if nothing changed
+			if meta.LastIndex == waitIndex {
+				continue
+			}
+			// index goes backwards if consul restored from snapshot, start over
+			if meta.LastIndex < waitIndex {
+				waitIndex = 0
+			} else {
+				waitIndex = meta.LastIndex
+			}
+
+			select {
+			case addresses <- *endpoints(scheme, entries):
+			default:
+				reg.logger.Warn("invalid event")
+			}
+		}
+	})
+
+	return addresses, nil
+}
+
+// Close stops keepalive and deregisters all services
+func (reg *consulRegistry) Close() error {
+	if reg.cancel != nil {
+		reg.cancel()
+	}
+	var wg sync.WaitGroup
+	reg.services.Range(func(k, v interface{}) bool {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := reg.deregister(ctx, id); err != nil {
+				reg.logger.Error("unregister service", xlog.FieldExtra(map[string]interface{}{"error": err.Error(), "id": id}))
+			} else {
+				reg.logger.Info("unregister service", xlog.FieldExtra(map[string]interface{}{"id": id}))
+			}
+		}(k.(string))
+		return true
+	})
+	wg.Wait()
+	return nil
+}
+
+func (reg *consulRegistry) deregister(ctx context.Context, id string) error {
+	ctx, cancel := reg.withTimeout(ctx)
+	defer cancel()
+
+	err := reg.client.Agent().ServiceDeregisterOpts(id, (&api.QueryOptions{}).WithContext(ctx))
+	if err == nil {
+		reg.services.Delete(id)
+	}
+	return err
+}
+
+// doKeepalive passes ttl checks of all services every 1/3 of ServiceTTL.
+// Services are registered again if they are lost by consul agent, such as agent restarted.
+func (reg *consulRegistry) doKeepalive() {
+	ticker := time.NewTicker(reg.ServiceTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-reg.ctx.Done():
+			reg.logger.Debug("exit keepalive")
+			return
+		case <-ticker.C:
+		}
+
+		reg.services.Range(func(k, v interface{}) bool {
+			ctx, cancel := reg.withTimeout(reg.ctx)
+			defer cancel()
+			service := v.(*api.AgentServiceRegistration)
+			err := reg.passTTL(ctx, service.ID)
+			if err == nil {
+				return true
+			}
+			reg.logger.Warn("update ttl, register again", xlog.FieldExtra(map[string]interface{}{"error": err.Error(), "id": service.ID}))
+			if err := reg.client.Agent().ServiceRegisterOpts(service, api.ServiceRegisterOpts{ReplaceExistingChecks: true}.WithContext(ctx)); err != nil {
+				reg.logger.Error("register service", xlog.FieldExtra(map[string]interface{}{"error": err.Error(), "id": service.ID}))
+				return true
+			}
+			if err := reg.passTTL(ctx, service.ID); err != nil {
+				reg.logger.Error("update ttl", xlog.FieldExtra(map[string]interface{}{"error": err.Error(), "id": service.ID}))
+			}
+			return true
+		})
+	}
+}
+
+func (reg *consulRegistry) passTTL(ctx context.Context, id string) error {
+	return reg.client.Agent().UpdateTTLOpts(checkID(id), "", api.HealthPassing, (&api.QueryOptions{}).WithContext(ctx))
+}
+
+func (reg *consulRegistry) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, reg.ReadTimeout)
+}
+
+func (reg *consulRegistry) registration(info *server.ServiceInfo) (*api.AgentServiceRegistration, error) {
+	host, portStr, err := net.SplitHostPort(info.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service address %s: %w", info.Address, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service address %s: %w", info.Address, err)
+	}
+
+	meta := make(map[string]string, len(info.Metadata)+2)
+	for k, v := range info.Metadata {
+		meta[k] = v
+	}
+	meta[metaID] = info.ID
+	meta[metaHostname] = info.Hostname
+
+	id := serviceID(info)
+	check := &api.AgentServiceCheck{
+		CheckID:                        checkID(id),
+		DeregisterCriticalServiceAfter: reg.DeregisterCriticalServiceAfter.String(),
+	}
+	switch {
+	case reg.Check == CheckTTL:
+		check.TTL = reg.ServiceTTL.String()
+	case info.Scheme == "grpc":
+		check.GRPC = info.Address
+		check.Interval = reg.CheckInterval.String()
+		check.Timeout = reg.CheckTimeout.String()
+	default:
+		check.HTTP = "http://" + info.Address + reg.HTTPCheckPath
+		check.Interval = reg.CheckInterval.String()
+		check.Timeout = reg.CheckTimeout.String()
+	}
+
+	return &api.AgentServiceRegistration{
+		ID:      id,
+		Name:    info.Name,
+		Tags:    append([]string{info.Scheme}, reg.Tags...),
+		Address: host,
+		Port:    port,
+		Meta:    meta,
+		Check:   check,
+	}, nil
+}
+
+// serviceID ids of consul services, slashes are not allowed in ids
+func serviceID(info *server.ServiceInfo) string {
+	return strings.ReplaceAll(info.RegistryName(), "/", "-")
+}
+
+func checkID(serviceID string) string {
+	return "service:" + serviceID
+}
+
+func serviceInfo(scheme string, entry *api.ServiceEntry) *server.ServiceInfo {
+	service := entry.Service
+	address := service.Address
+	if address == "" {
+		address = entry.Node.Address
+	}
+
+	info := &server.ServiceInfo{
+		ID:       service.Meta[metaID],
+		Name:     service.Service,
+		Scheme:   scheme,
+		Address:  net.JoinHostPort(address, strconv.Itoa(service.Port)),
+		Hostname: service.Meta[metaHostname],
+		Metadata: make(map[string]string, len(service.Meta)),
+	}
+	for k, v := range service.Meta {
+		if k != metaID && k != metaHostname {
+			info.Metadata[k] = v
+		}
+	}
+	return info
+}
+
+func endpoints(scheme string, entries []*api.ServiceEntry) *registry.Endpoints {
+	al := &registry.Endpoints{Nodes: make(map[string]server.ServiceInfo, len(entries))}
+	for _, entry := range entries {
+		info := serviceInfo(scheme, entry)
+		al.Nodes[info.Address] = *info
+	}
+	return al
+}
+
+// parsePrefix parses scheme and name from prefix `scheme:name/`
+func parsePrefix(prefix string) (scheme, name string) {
+	scheme, name, _ = strings.Cut(strings.TrimSuffix(prefix, "/"), ":")
+	return scheme, name
+}
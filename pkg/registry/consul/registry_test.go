@@ -0,0 +1,109 @@
+package consul
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/5idu/pilot/pkg/client/consul/consultest"
+	"github.com/5idu/pilot/pkg/registry"
+	"github.com/5idu/pilot/pkg/server"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRegistry(t *testing.T, srv *consultest.Server, check string) *consulRegistry {
+	config := DefaultConfig()
+	config.Config = srv.Config()
+	config.Config.Name = srv.URL + check
+	config.Check = check
+	config.ServiceTTL = 300 * time.Millisecond
+	config.Tags = []string{"v1"}
+	reg, err := newConsulRegistry(config)
+	assert.Nil(t, err)
+	return reg
+}
+
+// waitEndpoints waits for endpoints matched, services are not passing right after registered
+func waitEndpoints(t *testing.T, watch chan registry.Endpoints, match func(registry.Endpoints) bool) {
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case endpoints := <-watch:
+			if match(endpoints) {
+				return
+			}
+		case <-timeout:
+			t.Fatal("endpoints not watched")
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	srv := consultest.NewServer()
+	defer srv.Close()
+	reg := newTestRegistry(t, srv, CheckTTL)
+	ctx := context.Background()
+
+	info := &server.ServiceInfo{
+		ID:       "demo-1",
+		Name:     "demo",
+		Scheme:   "grpc",
+		Address:  "127.0.0.1:9091",
+		Hostname: "host-1",
+		Metadata: map[string]string{"weight": "10"},
+	}
+	watch, err := reg.WatchServices(ctx, "grpc:demo/")
+	assert.Nil(t, err)
+	assert.Empty(t, (<-watch).Nodes)
+
+	assert.Nil(t, reg.RegisterService(ctx, info))
+	service := srv.Services()["grpc:demo-127.0.0.1:9091"]
+	assert.Equal(t, []string{"grpc", "v1"}, service.Tags)
+	assert.Equal(t, "10", service.Meta["weight"])
+	assert.Equal(t, api.HealthPassing, srv.CheckStatus("service:grpc:demo-127.0.0.1:9091"))
+
+	got, err := reg.GetService(ctx, info.RegistryName())
+	assert.Nil(t, err)
+	assert.Equal(t, info, got)
+	services, err := reg.ListServices(ctx, "grpc:demo/")
+	assert.Nil(t, err)
+	assert.Equal(t, []*server.ServiceInfo{info}, services)
+	services, err = reg.ListServices(ctx, "http:demo/")
+	assert.Nil(t, err)
+	assert.Empty(t, services)
+
+	waitEndpoints(t, watch, func(endpoints registry.Endpoints) bool {
+		return assert.ObjectsAreEqual(*info, endpoints.Nodes[info.Address])
+	})
+
+	// kept passing by keepalive
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, api.HealthPassing, srv.CheckStatus("service:grpc:demo-127.0.0.1:9091"))
+
+	assert.Nil(t, reg.UnregisterService(ctx, info))
+	assert.Empty(t, srv.Services())
+	waitEndpoints(t, watch, func(endpoints registry.Endpoints) bool {
+		return len(endpoints.Nodes) == 0
+	})
+
+	assert.Nil(t, reg.RegisterService(ctx, info))
+	assert.Nil(t, reg.Close())
+	assert.Empty(t, srv.Services())
+}
+
+func TestRegistryHTTPCheck(t *testing.T) {
+	srv := consultest.NewServer()
+	defer srv.Close()
+	reg := newTestRegistry(t, srv, CheckHTTP)
+	defer reg.Close()
+
+	info := &server.ServiceInfo{Name: "demo", Scheme: "http", Address: "127.0.0.1:9090"}
+	assert.Nil(t, reg.RegisterService(context.Background(), info))
+	check := srv.Services()["http:demo-127.0.0.1:9090"].Check
+	assert.Equal(t, "http://127.0.0.1:9090/healthz", check.HTTP)
+	assert.Equal(t, "", check.TTL)
+
+	assert.NotNil(t, reg.RegisterService(context.Background(), &server.ServiceInfo{Name: "demo", Scheme: "http", Address: "invalid"}))
+}
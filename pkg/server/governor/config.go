@@ -0,0 +1,75 @@
+package governor
+
+import (
+	"fmt"
+
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/constant"
+	"github.com/5idu/pilot/pkg/xlog"
+
+	"github.com/pkg/errors"
+)
+
+// Config governor server config
+type Config struct {
+	// Enable serve governor by application, true by default
+	Enable bool `json:"enable"`
+	// Host 127.0.0.1 by default, endpoints are not authenticated, expose them with care
+	Host    string `json:"host"`
+	Port    int    `json:"port"`
+	Network string `json:"network"`
+	// EnableRollback serves POST /debug/config/rollback, which changes config of the process, false by default
+	EnableRollback bool `json:"enableRollback"`
+
+	logger *xlog.Logger
+}
+
+// StdConfig represents Standard governor server config
+// which will parse config by conf package, like: pilot.governor
+func StdConfig(name string) *Config {
+	return RawConfig(constant.ConfigKey(name))
+}
+
+// RawConfig ...
+func RawConfig(key string) *Config {
+	var config = DefaultConfig()
+	if err := conf.UnmarshalKey(key, &config); err != nil && errors.Cause(err) != conf.ErrInvalidKey {
+		panic(errors.WithMessage(err, "governor server parse config error"))
+	}
+	return config
+}
+
+// DefaultConfig represents default config
+func DefaultConfig() *Config {
+	return &Config{
+		Enable:  true,
+		Host:    "127.0.0.1",
+		Port:    9990,
+		Network: "tcp4",
+		logger:  xlog.With(xlog.String("mod", "governor.server")),
+	}
+}
+
+// WithLogger ...
+func (config *Config) WithLogger(logger *xlog.Logger) *Config {
+	config.logger = logger
+	return config
+}
+
+func (config *Config) MustBuild() *Server {
+	server, err := config.Build()
+	if err != nil {
+		panic(errors.WithMessage(err, "build governor server failed"))
+	}
+	return server
+}
+
+// Build create governor server instance with builtin handlers
+func (config *Config) Build() (*Server, error) {
+	return newServer(config)
+}
+
+// Address ...
+func (config *Config) Address() string {
+	return fmt.Sprintf("%s:%d", config.Host, config.Port)
+}
@@ -0,0 +1,129 @@
+package governor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"strings"
+
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/constant"
+
+	"github.com/pkg/errors"
+)
+
+const redactedValue = "******"
+
+// secretKeywords value of config key which contains any of these keywords will be redacted
+var secretKeywords = []string{"password", "passwd", "secret", "token", "accesskey", "privatekey", "credential", "dsn"}
+
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+// defaultRoutes registered by every governor server
+var defaultRoutes = []route{
+	{"/debug/pprof/", pprof.Index},
+	{"/debug/pprof/cmdline", pprof.Cmdline},
+	{"/debug/pprof/profile", pprof.Profile},
+	{"/debug/pprof/symbol", pprof.Symbol},
+	{"/debug/pprof/trace", pprof.Trace},
+	{"/debug/config", handleConfig},
+	{"/debug/config/history", handleConfigHistory},
+	{"/debug/build", handleBuild},
+}
+
+// WriteJSON writes v as json response
+func WriteJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleConfig renders the configuration with secrets redacted
+func handleConfig(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, Redact(conf.Traverse(".")))
+}
+
+// handleConfigHistory renders versions of config with secrets redacted
+func handleConfigHistory(w http.ResponseWriter, r *http.Request) {
+	versions := conf.History()
+	for i := range versions {
+		changes := make([]conf.ChangeEvent, len(versions[i].Changes))
+		for j, change := range versions[i].Changes {
+			if isSecretKey(change.Key) {
+				if change.OldValue != nil {
+					change.OldValue = redactedValue
+				}
+				if change.NewValue != nil {
+					change.NewValue = redactedValue
+				}
+			}
+			changes[j] = change
+		}
+		versions[i].Changes = changes
+	}
+	WriteJSON(w, versions)
+}
+
+// handleConfigRollback rolls back config to the version, POST /debug/config/rollback?id=1,
+// served only if EnableRollback
+func handleConfigRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "invalid version id", http.StatusBadRequest)
+		return
+	}
+	if err := conf.Rollback(id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, conf.ErrVersionNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	WriteJSON(w, map[string]int{"id": id})
+}
+
+// handleBuild renders build info of current binary
+func handleBuild(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, map[string]string{
+		"name":      constant.AppName(),
+		"version":   constant.BuildVersion(),
+		"commit":    constant.BuildCommit(),
+		"buildTime": constant.BuildTime(),
+		"goVersion": constant.GoVersion(),
+	})
+}
+
+// Redact replaces the value of secret keys in flatten config
+func Redact(flatten map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(flatten))
+	for key, val := range flatten {
+		if isSecretKey(key) {
+			val = redactedValue
+		}
+		out[key] = val
+	}
+	return out
+}
+
+func isSecretKey(key string) bool {
+	paths := strings.Split(key, ".")
+	last := strings.ToLower(paths[len(paths)-1])
+	for _, keyword := range secretKeywords {
+		if strings.Contains(last, keyword) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package governor
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/5idu/pilot/pkg/server"
+	"github.com/5idu/pilot/pkg/util/xnet"
+	"github.com/5idu/pilot/pkg/xlog"
+
+	"github.com/pkg/errors"
+)
+
+// Server governor server, serves the admin endpoints of current process
+type Server struct {
+	*http.Server
+	listener net.Listener
+	*Config
+
+	mu       sync.RWMutex
+	mux      *http.ServeMux
+	patterns []string
+}
+
+func newServer(config *Config) (*Server, error) {
+	listener, err := net.Listen(config.Network, config.Address())
+	if err != nil {
+		return nil, errors.Wrap(err, "create governor server failed")
+	}
+	config.Port = xnet.Port(listener.Addr())
+
+	s := &Server{
+		listener: listener,
+		Config:   config,
+		mux:      http.NewServeMux(),
+		patterns: make([]string, 0),
+	}
+	s.Server = &http.Server{Handler: s.mux}
+
+	s.HandleFunc("/", s.handleIndex)
+	for _, route := range defaultRoutes {
+		s.HandleFunc(route.pattern, route.handler)
+	}
+	if config.EnableRollback {
+		s.HandleFunc("/debug/config/rollback", handleConfigRollback)
+	}
+	return s, nil
+}
+
+// HandleFunc registers the handler for the given pattern
+func (s *Server) HandleFunc(pattern string, handler http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mux.HandleFunc(pattern, handler)
+	s.patterns = append(s.patterns, pattern)
+}
+
+// handleIndex lists all patterns registered
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.RLock()
+	patterns := append([]string{}, s.patterns...)
+	s.mu.RUnlock()
+	sort.Strings(patterns)
+	WriteJSON(w, patterns)
+}
+
+func (s *Server) Healthz() bool {
+	return true
+}
+
+// Serve implements server.Server interface.
+func (s *Server) Serve() error {
+	err := s.Server.Serve(s.listener)
+	if err == http.ErrServerClosed {
+		s.logger.Info("close governor", xlog.String("addr", s.Config.Address()))
+		return nil
+	}
+	return err
+}
+
+// Stop implements server.Server interface
+// it will terminate governor server immediately
+func (s *Server) Stop() error {
+	return s.Server.Close()
+}
+
+// GracefulStop implements server.Server interface
+// it will stop governor server gracefully
+func (s *Server) GracefulStop(ctx context.Context) error {
+	return s.Server.Shutdown(ctx)
+}
+
+// Info returns server info
+func (s *Server) Info() *server.ServiceInfo {
+	hostname, err := os.Hostname()
+	if err != nil {
+		s.logger.Error("info: get hostname error")
+		return nil
+	}
+
+	info := server.ApplyOptions(
+		server.WithName("governor.server"),
+		server.WithScheme("governor"),
+		server.WithAddress(s.listener.Addr().String()),
+		server.WithHostname(hostname),
+	)
+	return &info
+}
@@ -0,0 +1,79 @@
+package governor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	out := Redact(map[string]interface{}{
+		"pilot.redis.default.addr":      "127.0.0.1:6379",
+		"pilot.redis.default.password":  "secret",
+		"pilot.rdb.default.dsn":         "root:secret@tcp(127.0.0.1:3306)/db",
+		"pilot.registry.default.ttl":    10,
+		"pilot.nacos.default.secretKey": "xx",
+	})
+	assert.Equal(t, "127.0.0.1:6379", out["pilot.redis.default.addr"])
+	assert.Equal(t, redactedValue, out["pilot.redis.default.password"])
+	assert.Equal(t, redactedValue, out["pilot.rdb.default.dsn"])
+	assert.Equal(t, 10, out["pilot.registry.default.ttl"])
+	assert.Equal(t, redactedValue, out["pilot.nacos.default.secretKey"])
+}
+
+func TestServerHandlers(t *testing.T) {
+	config := DefaultConfig()
+	assert.Equal(t, "127.0.0.1", config.Host)
+	config.Port = 0
+	s := config.MustBuild()
+	defer s.Stop()
+
+	s.HandleFunc("/debug/custom", func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, "ok")
+	})
+
+	rec := httptest.NewRecorder()
+	s.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var patterns []string
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &patterns))
+	assert.Contains(t, patterns, "/debug/config")
+	assert.Contains(t, patterns, "/debug/custom")
+	// rollback changes config, it's served only if enabled
+	assert.NotContains(t, patterns, "/debug/config/rollback")
+
+	config = DefaultConfig()
+	config.Port, config.EnableRollback = 0, true
+	s2 := config.MustBuild()
+	defer s2.Stop()
+	rec = httptest.NewRecorder()
+	s2.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/config/rollback?id=10000", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "config version not found")
+
+	rec = httptest.NewRecorder()
+	s.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/build", nil))
+	var build map[string]string
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &build))
+	assert.NotEmpty(t, build["goVersion"])
+}
+
+func TestConfigHistoryHandlers(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleConfigHistory(rec, httptest.NewRequest(http.MethodGet, "/debug/config/history", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handleConfigRollback(rec, httptest.NewRequest(http.MethodGet, "/debug/config/rollback?id=1", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handleConfigRollback(rec, httptest.NewRequest(http.MethodPost, "/debug/config/rollback?id=x", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handleConfigRollback(rec, httptest.NewRequest(http.MethodPost, "/debug/config/rollback?id=10000", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -81,6 +81,40 @@ func defaultServiceInfo() ServiceInfo {
 	return si
 }
 
+// Route describes an endpoint exposed by server, like http route or grpc method
+type Route struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// RouteLister is implemented by servers which could list their routes
+type RouteLister interface {
+	ListRoutes() []Route
+}
+
+// ServiceInfoLister is implemented by servers which serve multiple schemes,
+// such as http and grpc on the same port, each of them will be registered
+type ServiceInfoLister interface {
+	ListServiceInfos() []*ServiceInfo
+}
+
+// ServiceInfos returns all service infos of the server
+func ServiceInfos(s Server) []*ServiceInfo {
+	if lister, ok := s.(ServiceInfoLister); ok {
+		return lister.ListServiceInfos()
+	}
+	if info := s.Info(); info != nil {
+		return []*ServiceInfo{info}
+	}
+	return nil
+}
+
+// Readier is implemented by servers which bind listeners in Serve, Ready is closed once requests are accepted.
+// Servers not implementing it are ready once Serve is called, their listeners are bound when built.
+type Readier interface {
+	Ready() <-chan struct{}
+}
+
 // Server ...
 type Server interface {
 	Serve() error
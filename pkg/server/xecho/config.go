@@ -2,10 +2,14 @@ package xecho
 
 import (
 	"fmt"
+	"net"
 
 	"github.com/5idu/pilot/pkg/conf"
 	"github.com/5idu/pilot/pkg/constant"
 	"github.com/5idu/pilot/pkg/flag"
+	"github.com/5idu/pilot/pkg/health"
+	"github.com/5idu/pilot/pkg/ratelimit"
+	"github.com/5idu/pilot/pkg/util/xnet"
 	"github.com/5idu/pilot/pkg/xlog"
 
 	"github.com/labstack/echo/v4/middleware"
@@ -14,21 +18,28 @@ import (
 
 // Config HTTP config
 type Config struct {
-	Host  string
-	Port  int
-	Debug bool
+	Host string
+	Port int
+	// Network network type, tcp by default, Host of unix network is a socket address of xnet.Listen
+	Network string
+	Debug   bool
 	// ServiceAddress service address in registry info, default to 'Host:Port'
 	ServiceAddress string
 	CertFile       string
 	PrivateFile    string
 	EnableTLS      bool
+	// EnableH2C serves HTTP/2 without TLS, and HTTP/2 over connections which TLS has been terminated by listener
+	EnableH2C bool
 
 	EnableTrace  bool
 	EnableMetric bool
 
 	SlowQueryThresholdInMilli int64
 
-	logger *xlog.Logger
+	listener net.Listener
+	logger   *xlog.Logger
+	// configKey set by RawConfig, limits of routes are read from configKey.ratelimit
+	configKey string
 }
 
 // DefaultConfig ...
@@ -36,6 +47,7 @@ func DefaultConfig() *Config {
 	return &Config{
 		Host:                      flag.String("host"),
 		Port:                      9091,
+		Network:                   "tcp",
 		Debug:                     false,
 		SlowQueryThresholdInMilli: 500, // 500ms
 		logger:                    xlog.With(xlog.String("mod", "echo.server")),
@@ -58,6 +70,7 @@ func RawConfig(key string) *Config {
 	if err := conf.UnmarshalKey(key, &config); err != nil && errors.Cause(err) != conf.ErrInvalidKey {
 		panic(errors.WithMessage(err, "http server parse config error"))
 	}
+	config.configKey = key
 	return config
 }
 
@@ -67,6 +80,18 @@ func (config *Config) WithLogger(logger *xlog.Logger) *Config {
 	return config
 }
 
+// WithListener serves on listener rather than Address, such as the http listener of xmux
+func (config *Config) WithListener(listener net.Listener) *Config {
+	config.listener = listener
+	return config
+}
+
+// WithConfigKey sets key of config read by other servers, such as `http` of xmux, whose rate limits are key.ratelimit
+func (config *Config) WithConfigKey(key string) *Config {
+	config.configKey = key
+	return config
+}
+
 // WithHost ...
 func (config *Config) WithHost(host string) *Config {
 	config.Host = host
@@ -89,10 +114,21 @@ func (config *Config) MustBuild() *Server {
 
 // Build create server instance, then initialize it with necessary interceptor
 func (config *Config) Build() (*Server, error) {
+	var limiter *ratelimit.Limiter
+	if config.configKey != "" {
+		var err error
+		if limiter, err = ratelimit.New(config.configKey + ".ratelimit"); err != nil {
+			return nil, err
+		}
+	}
 	server, err := newServer(config)
 	if err != nil {
+		if limiter != nil {
+			limiter.Close()
+		}
 		return nil, err
 	}
+	server.limiter = limiter
 	server.Use(recoverMiddleware(config.logger, config.SlowQueryThresholdInMilli))
 	server.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins:     []string{"*"},
@@ -101,6 +137,9 @@ func (config *Config) Build() (*Server, error) {
 		AllowMethods:     []string{"*"},
 		AllowCredentials: true,
 	}))
+	if limiter != nil {
+		server.Use(rateLimitMiddleware(limiter))
+	}
 
 	if config.EnableTrace {
 		server.Use(traceServerInterceptor())
@@ -109,10 +148,17 @@ func (config *Config) Build() (*Server, error) {
 		server.Use(metricServerInterceptor())
 	}
 
+	// probes for kubernetes
+	server.GET("/healthz", healthHandler(health.CheckLiveness))
+	server.GET("/readyz", healthHandler(health.CheckReadiness))
+
 	return server, nil
 }
 
 // Address ...
 func (config *Config) Address() string {
+	if xnet.IsUnixNetwork(config.Network) {
+		return config.Host
+	}
 	return fmt.Sprintf("%s:%d", config.Host, config.Port)
 }
@@ -0,0 +1,119 @@
+package xecho
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var errUnknownField = errors.New("unknown field")
+
+// lookupField returns the message and descriptor of field by path, such as `book.name`,
+// fields are matched by proto name or json name, parent messages are created if not set.
+func lookupField(m protoreflect.Message, path string) (protoreflect.Message, protoreflect.FieldDescriptor, error) {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		fields := m.Descriptor().Fields()
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = fields.ByJSONName(name)
+		}
+		if fd == nil {
+			return nil, nil, errUnknownField
+		}
+		if i == len(names)-1 {
+			return m, fd, nil
+		}
+		if fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+			return nil, nil, fmt.Errorf("%s is not message", fd.Name())
+		}
+		m = m.Mutable(fd).Message()
+	}
+	return nil, nil, errUnknownField
+}
+
+// setField sets field by path with values of query or path, repeated fields accept multiple values
+func setField(m protoreflect.Message, path string, values []string) error {
+	parent, fd, err := lookupField(m, path)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	if fd.IsMap() {
+		return fmt.Errorf("map field %s is not supported", fd.Name())
+	}
+	if fd.IsList() {
+		list := parent.Mutable(fd).List()
+		for _, value := range values {
+			v, err := parseField(parent, fd, value)
+			if err != nil {
+				return err
+			}
+			list.Append(v)
+		}
+		return nil
+	}
+	v, err := parseField(parent, fd, values[len(values)-1])
+	if err != nil {
+		return err
+	}
+	parent.Set(fd, v)
+	return nil
+}
+
+func parseField(parent protoreflect.Message, fd protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(value)
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(value, 10, 32)
+		return protoreflect.ValueOfInt32(int32(v)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(value, 10, 64)
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(value, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(v)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(value, 10, 64)
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(value, 32)
+		return protoreflect.ValueOfFloat32(float32(v)), err
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(value, 64)
+		return protoreflect.ValueOfFloat64(v), err
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BytesKind:
+		v, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			v, err = base64.URLEncoding.DecodeString(value)
+		}
+		return protoreflect.ValueOfBytes(v), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(value)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		v, err := strconv.ParseInt(value, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), err
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		// well known types, such as google.protobuf.Timestamp and wrappers
+		m := parent.NewField(fd).Message()
+		if err := protojson.Unmarshal([]byte(strconv.Quote(value)), m.Interface()); err != nil {
+			if err := protojson.Unmarshal([]byte(value), m.Interface()); err != nil {
+				return protoreflect.Value{}, err
+			}
+		}
+		return protoreflect.ValueOfMessage(m), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported kind %s of field %s", fd.Kind(), fd.Name())
+}
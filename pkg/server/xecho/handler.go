@@ -7,6 +7,8 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/5idu/pilot/pkg/health"
+
 	"github.com/codegangsta/inject"
 	"github.com/labstack/echo/v4"
 	"google.golang.org/grpc/metadata"
@@ -57,7 +59,20 @@ func ProtoJSON(c echo.Context, code int, i interface{}) error {
 	return err
 }
 
+// healthHandler renders the health report, 503 returned if any check failed
+func healthHandler(check func(context.Context) health.Report) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		report := check(c.Request().Context())
+		if !report.Healthy {
+			return c.JSON(http.StatusServiceUnavailable, report)
+		}
+		return c.JSON(http.StatusOK, report)
+	}
+}
+
 // GRPCProxyWrapper ...
+//
+// Deprecated: use Server.RegisterService, which generates routes of grpc services by google.api.http annotations.
 func GRPCProxyWrapper(h interface{}) echo.HandlerFunc {
 	t := reflect.TypeOf(h)
 	if t.Kind() != reflect.Func {
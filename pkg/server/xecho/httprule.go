@@ -0,0 +1,222 @@
+package xecho
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// httpBinding is a http route of grpc method, generated by google.api.http annotation
+type httpBinding struct {
+	method       string
+	template     *pathTemplate
+	body         string
+	responseBody string
+}
+
+// httpBindings returns http routes of the method by google.api.http annotation,
+// `POST /{package.Service}/{Method}` with body `*` if not annotated.
+func httpBindings(md protoreflect.MethodDescriptor) ([]*httpBinding, error) {
+	rule, _ := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+	if rule == nil || rule.GetPattern() == nil {
+		path := fmt.Sprintf("/%s/%s", md.Parent().FullName(), md.Name())
+		return []*httpBinding{{method: http.MethodPost, template: &pathTemplate{path: path}, body: "*"}}, nil
+	}
+
+	rules := append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...)
+	bindings := make([]*httpBinding, 0, len(rules))
+	for _, rule := range rules {
+		var method, template string
+		switch pattern := rule.GetPattern().(type) {
+		case *annotations.HttpRule_Get:
+			method, template = http.MethodGet, pattern.Get
+		case *annotations.HttpRule_Put:
+			method, template = http.MethodPut, pattern.Put
+		case *annotations.HttpRule_Post:
+			method, template = http.MethodPost, pattern.Post
+		case *annotations.HttpRule_Delete:
+			method, template = http.MethodDelete, pattern.Delete
+		case *annotations.HttpRule_Patch:
+			method, template = http.MethodPatch, pattern.Patch
+		case *annotations.HttpRule_Custom:
+			method, template = pattern.Custom.GetKind(), pattern.Custom.GetPath()
+		default:
+			return nil, fmt.Errorf("unsupported http pattern of %s", md.FullName())
+		}
+		pt, err := parsePathTemplate(template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http path of %s: %w", md.FullName(), err)
+		}
+		bindings = append(bindings, &httpBinding{
+			method:       method,
+			template:     pt,
+			body:         rule.GetBody(),
+			responseBody: rule.GetResponseBody(),
+		})
+	}
+	return bindings, nil
+}
+
+// pathTemplate is the path template of google.api.http, such as `/v1/{name=shelves/*}/books/{book_id}:publish`,
+// which is converted to echo route `/v1/shelves/:p0/books/:p1`.
+type pathTemplate struct {
+	// path route of echo
+	path string
+	// verb custom verb, such as publish
+	verb string
+	// verbParam verb follows a variable, it's captured by the param of echo
+	verbParam string
+	vars      []pathVar
+}
+
+// pathVar is a variable of path template, such as `{name=shelves/*}`
+type pathVar struct {
+	field string
+	// segments literal segments, or echo params, such as `shelves` and `:p0`
+	segments []string
+}
+
+func parsePathTemplate(template string) (*pathTemplate, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("path %q should start with /", template)
+	}
+	p := &templateParser{template: template, pt: &pathTemplate{}}
+	segments := strings.TrimPrefix(template, "/")
+	if i := strings.LastIndex(segments, ":"); i >= 0 && i > strings.LastIndex(segments, "}") && i > strings.LastIndex(segments, "/") {
+		segments, p.pt.verb = segments[:i], segments[i+1:]
+	}
+	if err := p.parse(segments); err != nil {
+		return nil, err
+	}
+
+	if p.pt.verb != "" {
+		if p.lastParam != "" {
+			p.pt.verbParam = p.lastParam
+		} else {
+			// literal colon is escaped in echo route
+			p.path.WriteString(`\:` + p.pt.verb)
+		}
+	}
+	p.pt.path = p.path.String()
+	return p.pt, nil
+}
+
+type templateParser struct {
+	template  string
+	pt        *pathTemplate
+	path      strings.Builder
+	params    int
+	wildcard  bool
+	lastParam string
+}
+
+func (p *templateParser) parse(segments string) error {
+	for segments != "" {
+		var seg string
+		if strings.HasPrefix(segments, "{") {
+			end := strings.Index(segments, "}")
+			if end < 0 {
+				return fmt.Errorf("unclosed variable of path %q", p.template)
+			}
+			seg, segments = segments[:end+1], segments[end+1:]
+		} else if i := strings.Index(segments, "/"); i >= 0 {
+			seg, segments = segments[:i], segments[i:]
+		} else {
+			seg, segments = segments, ""
+		}
+		if p.wildcard {
+			return fmt.Errorf("** should be the last segment of path %q", p.template)
+		}
+		if segments != "" {
+			if !strings.HasPrefix(segments, "/") {
+				return fmt.Errorf("invalid segment %q of path %q", seg, p.template)
+			}
+			segments = segments[1:]
+		}
+
+		if strings.HasPrefix(seg, "{") {
+			field, pattern := seg[1:len(seg)-1], "*"
+			if i := strings.Index(field, "="); i >= 0 {
+				field, pattern = field[:i], field[i+1:]
+			}
+			v := pathVar{field: field}
+			for i, s := range strings.Split(pattern, "/") {
+				if s == "" || strings.ContainsAny(s, "{}") || (p.wildcard && i > 0) {
+					return fmt.Errorf("invalid variable %q of path %q", seg, p.template)
+				}
+				v.segments = append(v.segments, p.segment(s))
+			}
+			p.pt.vars = append(p.pt.vars, v)
+			continue
+		}
+		if seg == "" {
+			return fmt.Errorf("empty segment of path %q", p.template)
+		}
+		p.segment(seg)
+	}
+	return nil
+}
+
+// segment writes the segment to echo route, it returns echo param of wildcards, or the literal
+func (p *templateParser) segment(seg string) string {
+	switch seg {
+	case "*":
+		name := "p" + strconv.Itoa(p.params)
+		p.params++
+		p.path.WriteString("/:" + name)
+		p.lastParam = name
+		return ":" + name
+	case "**":
+		p.path.WriteString("/*")
+		p.wildcard = true
+		p.lastParam = "*"
+		return ":*"
+	default:
+		p.path.WriteString("/" + seg)
+		p.lastParam = ""
+		return seg
+	}
+}
+
+// matchVerb reports whether the request matches custom verb following a variable
+func (pt *pathTemplate) matchVerb(c echo.Context) bool {
+	if pt.verbParam == "" {
+		return true
+	}
+	return strings.HasSuffix(c.Param(pt.verbParam), ":"+pt.verb)
+}
+
+// values returns values of variables from echo params
+func (pt *pathTemplate) values(c echo.Context) map[string]string {
+	values := make(map[string]string, len(pt.vars))
+	for _, v := range pt.vars {
+		segments := make([]string, 0, len(v.segments))
+		for _, seg := range v.segments {
+			if !strings.HasPrefix(seg, ":") {
+				segments = append(segments, seg)
+				continue
+			}
+			name := seg[1:]
+			value := c.Param(name)
+			if name == pt.verbParam {
+				value = strings.TrimSuffix(value, ":"+pt.verb)
+			}
+			// params are escaped if the request path is escaped
+			if c.Request().URL.RawPath != "" {
+				if unescaped, err := url.PathUnescape(value); err == nil {
+					value = unescaped
+				}
+			}
+			segments = append(segments, value)
+		}
+		values[v.field] = strings.Join(segments, "/")
+	}
+	return values
+}
@@ -5,8 +5,10 @@ import (
 	"log"
 	"net/http"
 	"runtime"
+	"strings"
 	"time"
 
+	"github.com/5idu/pilot/pkg/ratelimit"
 	"github.com/5idu/pilot/pkg/xlog"
 	"github.com/5idu/pilot/pkg/xmetric"
 	"github.com/5idu/pilot/pkg/xtrace"
@@ -103,3 +105,34 @@ func metricServerInterceptor() echo.MiddlewareFunc {
 		}
 	}
 }
+
+// rateLimitKey returns value of rule key for the request, such as ip of client
+func rateLimitKey(c echo.Context, key string) string {
+	switch {
+	case key == ratelimit.KeyIP:
+		return c.RealIP()
+	case key == ratelimit.KeyAID:
+		return c.Request().Header.Get("aid")
+	case strings.HasPrefix(key, ratelimit.KeyHeaderPrefix):
+		return c.Request().Header.Get(strings.TrimPrefix(key, ratelimit.KeyHeaderPrefix))
+	}
+	return ""
+}
+
+// rateLimitMiddleware limits requests by path of route, rejected with 429
+func rateLimitMiddleware(limiter *ratelimit.Limiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			if !limiter.Allow(ctx, c.Path(), func(key string) string { return rateLimitKey(c, key) }) {
+				xmetric.RateLimitRejected.Inc(ctx,
+					attribute.String("type", "http"),
+					attribute.String("method", c.Request().Method),
+					attribute.String("path", c.Path()),
+				)
+				return echo.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+			}
+			return next(c)
+		}
+	}
+}
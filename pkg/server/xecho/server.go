@@ -9,7 +9,10 @@ import (
 	"os"
 	"reflect"
 
+	"github.com/5idu/pilot/pkg/health"
+	"github.com/5idu/pilot/pkg/ratelimit"
 	"github.com/5idu/pilot/pkg/server"
+	"github.com/5idu/pilot/pkg/util/xnet"
 	"github.com/5idu/pilot/pkg/xlog"
 
 	"github.com/go-playground/locales/zh"
@@ -18,6 +21,7 @@ import (
 	zh_translations "github.com/go-playground/validator/v10/translations/zh"
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
+	"golang.org/x/net/http2"
 )
 
 // Server ...
@@ -25,13 +29,18 @@ type Server struct {
 	*echo.Echo
 	config   *Config
 	listener net.Listener
+	limiter  *ratelimit.Limiter
 	// registerer registry.Registry
+
+	// transcodeRoutes routes of grpc methods, by http method and path
+	transcodeRoutes map[string]*transcodeRoute
 }
 
 func newServer(config *Config) (*Server, error) {
 	var (
-		listener net.Listener
-		err      error
+		listener  = config.listener
+		tlsConfig *tls.Config
+		err       error
 	)
 
 	if config.EnableTLS {
@@ -46,20 +55,23 @@ func newServer(config *Config) (*Server, error) {
 			return nil, errors.Wrap(err, "read private failed")
 		}
 
-		tlsConfig := new(tls.Config)
+		tlsConfig = new(tls.Config)
 		tlsConfig.Certificates = make([]tls.Certificate, 1)
 
 		if tlsConfig.Certificates[0], err = tls.X509KeyPair(cert, key); err != nil {
 			return nil, errors.Wrap(err, "X509KeyPair failed")
 		}
-		listener, err = tls.Listen("tcp", config.Address(), tlsConfig)
-	} else {
-		listener, err = net.Listen("tcp", config.Address())
 	}
-	if err != nil {
-		return nil, errors.Wrapf(err, "create xecho server failed")
+
+	if listener == nil {
+		if listener, err = xnet.Listen(config.Network, config.Address()); err != nil {
+			return nil, errors.Wrapf(err, "create xecho server failed")
+		}
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
 	}
-	config.Port = listener.Addr().(*net.TCPAddr).Port
+	config.Port = xnet.Port(listener.Addr())
 
 	e := echo.New()
 	e.Validator = NewCustomValidator()
@@ -106,8 +118,9 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 	return nil
 }
 
+// Healthz reports whether all registered health checkers passed
 func (s *Server) Healthz() bool {
-	return true
+	return health.Healthy(context.Background())
 }
 
 // Serve implements server.Server interface.
@@ -118,7 +131,7 @@ func (s *Server) Serve() error {
 	// s.Echo.StdLogger = zap.NewStdLog(nil)
 	if s.Echo.Debug {
 		// display echo api list
-		for _, route := range s.Echo.Routes() {
+		for _, route := range s.ListRoutes() {
 			fmt.Printf("[ECHO] \x1b[34m%8s\x1b[0m %s\n", route.Method, route.Path)
 		}
 	}
@@ -128,6 +141,9 @@ func (s *Server) Serve() error {
 	if s.config.EnableTLS {
 		s.Echo.TLSListener = s.listener
 		err = s.Echo.StartTLS("", s.config.CertFile, s.config.PrivateFile)
+	} else if s.config.EnableH2C {
+		s.Echo.Listener = s.listener
+		err = s.Echo.StartH2CServer("", &http2.Server{})
 	} else {
 		s.Echo.Listener = s.listener
 		err = s.Echo.Start("")
@@ -140,21 +156,39 @@ func (s *Server) Serve() error {
 	return nil
 }
 
+// ListRoutes implements server.RouteLister interface.
+func (s *Server) ListRoutes() []server.Route {
+	routes := make([]server.Route, 0)
+	for _, route := range s.Echo.Routes() {
+		routes = append(routes, server.Route{Method: route.Method, Path: route.Path})
+	}
+	return routes
+}
+
 // Stop implements server.Server interface
 // it will terminate echo server immediately
 func (s *Server) Stop() error {
+	s.closeLimiter()
 	return s.Echo.Close()
 }
 
 // GracefulStop implements server.Server interface
 // it will stop echo server gracefully
 func (s *Server) GracefulStop(ctx context.Context) error {
+	s.closeLimiter()
 	return s.Echo.Shutdown(ctx)
 }
 
+// closeLimiter stops reloading config of rate limits
+func (s *Server) closeLimiter() {
+	if s.limiter != nil {
+		s.limiter.Close()
+	}
+}
+
 // Info returns server info, used by governor and consumer balancer
 func (s *Server) Info() *server.ServiceInfo {
-	serviceAddr := s.listener.Addr().String()
+	serviceAddr := xnet.ServiceAddress(s.listener.Addr())
 	if s.config.ServiceAddress != "" {
 		serviceAddr = s.config.ServiceAddress
 	}
@@ -6,7 +6,10 @@ import (
 
 	_ "github.com/5idu/pilot/pkg/registry/etcdv3"
 
+	"io"
+	"net"
 	"net/http"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -19,6 +22,7 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const configData = `
@@ -79,3 +83,66 @@ func Test_Server(t *testing.T) {
 	registry.DefaultRegisterer.Close()
 	s.Stop()
 }
+
+func TestServerUnix(t *testing.T) {
+	for _, host := range []string{filepath.Join(t.TempDir(), "http.sock"), "@pilot-xecho-test"} {
+		c := xecho.DefaultConfig()
+		c.Network, c.Host = "unix", host
+		s := c.MustBuild()
+		s.GET("/", func(c echo.Context) error {
+			return c.String(http.StatusOK, "hello pilot")
+		})
+		go func() { _ = s.Serve() }()
+
+		assert.Equal(t, "unix:"+host, s.Info().Address)
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", host)
+			},
+		}}
+		resp, err := client.Get("http://unix/")
+		if assert.Nil(t, err) {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, "hello pilot", string(body))
+		}
+		client.CloseIdleConnections()
+		assert.Nil(t, s.GracefulStop(context.Background()))
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	previous := conf.Default()
+	c := conf.New()
+	conf.SetDefaultConfiguration(c)
+	t.Cleanup(func() { conf.SetDefaultConfiguration(previous) })
+
+	key := "test.server.http"
+	require.NoError(t, c.Set(key+".host", "127.0.0.1"))
+	require.NoError(t, c.Set(key+".port", 0))
+	require.NoError(t, c.Set(key+".ratelimit.enable", true))
+	require.NoError(t, c.Set(key+".ratelimit.rules", []map[string]interface{}{
+		{"name": "/books/:id", "algorithm": "slidingwindow", "limit": 1, "window": "1m", "key": "aid"},
+	}))
+	s := xecho.RawConfig(key).MustBuild()
+	s.GET("/books/:id", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Param("id"))
+	})
+	go func() { _ = s.Serve() }()
+	defer s.Stop()
+
+	get := func(path, aid string) int {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+s.Info().Address+path, nil)
+		req.Header.Set("aid", aid)
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.Nil(t, err) {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, get("/books/1", "1"))
+	assert.Equal(t, http.StatusTooManyRequests, get("/books/2", "1"))
+	assert.Equal(t, http.StatusOK, get("/books/2", "2"))
+	assert.Equal(t, http.StatusOK, get("/healthz", "1"))
+}
@@ -0,0 +1,395 @@
+package xecho
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/5idu/pilot/pkg/xlog"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// MetadataHeaderPrefix prefix of response headers from grpc header and trailer metadata
+const MetadataHeaderPrefix = "Grpc-Metadata-"
+
+// unaryInvoker invokes grpc method with request bound by bind,
+// it returns response and metadata of header and trailer.
+type unaryInvoker func(ctx context.Context, bind func(req proto.Message) error) (proto.Message, metadata.MD, error)
+
+// transcodeRoute dispatches requests of the same echo route to grpc methods,
+// they are different by custom verbs, such as `/v1/{name=operations/*}` and `/v1/{name=operations/*}:cancel`.
+type transcodeRoute struct {
+	handlers []*transcodeHandler
+}
+
+type transcodeHandler struct {
+	binding *httpBinding
+	invoke  unaryInvoker
+}
+
+// RegisterService implements grpc.ServiceRegistrar interface,
+// it generates http routes of unary methods of the service, which are served by impl in process, such as
+//
+//	helloworld.RegisterGreeterServer(server, &greeter{})
+//
+// Routes are generated by google.api.http annotations, or `POST /{package.Service}/{Method}` if not annotated.
+// Requests are bound from path, query and body, headers are passed as incoming metadata.
+func (s *Server) RegisterService(desc *grpc.ServiceDesc, impl interface{}) {
+	if impl != nil {
+		ht := reflect.TypeOf(desc.HandlerType).Elem()
+		if st := reflect.TypeOf(impl); !st.Implements(ht) {
+			s.config.logger.Panic("register grpc service failed", xlog.String("service", desc.ServiceName), xlog.Any("error", fmt.Sprintf("%v does not implement %v", st, ht)))
+		}
+	}
+
+	// routes are generated without annotations if the service isn't registered in protoregistry
+	var sd protoreflect.ServiceDescriptor
+	if d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(desc.ServiceName)); err == nil {
+		sd, _ = d.(protoreflect.ServiceDescriptor)
+	}
+	for _, method := range desc.Methods {
+		method := method
+		fullMethod := fmt.Sprintf("/%s/%s", desc.ServiceName, method.MethodName)
+		invoke := func(ctx context.Context, bind func(req proto.Message) error) (proto.Message, metadata.MD, error) {
+			stream := &transportStream{method: fullMethod}
+			ctx = grpc.NewContextWithServerTransportStream(ctx, stream)
+			resp, err := method.Handler(impl, ctx, func(req interface{}) error {
+				m, ok := req.(proto.Message)
+				if !ok {
+					return status.Errorf(codes.Internal, "request %T is not proto message", req)
+				}
+				return bind(m)
+			}, nil)
+			if err != nil {
+				return nil, stream.metadata(), err
+			}
+			m, ok := resp.(proto.Message)
+			if !ok {
+				return nil, stream.metadata(), status.Errorf(codes.Internal, "response %T is not proto message", resp)
+			}
+			return m, stream.metadata(), nil
+		}
+
+		var bindings []*httpBinding
+		if sd != nil && sd.Methods().ByName(protoreflect.Name(method.MethodName)) != nil {
+			var err error
+			if bindings, err = httpBindings(sd.Methods().ByName(protoreflect.Name(method.MethodName))); err != nil {
+				s.config.logger.Panic("register grpc service failed", xlog.String("service", desc.ServiceName), xlog.FieldErr(err))
+			}
+		} else {
+			bindings = []*httpBinding{{method: http.MethodPost, template: &pathTemplate{path: fullMethod}, body: "*"}}
+		}
+		for _, b := range bindings {
+			s.addTranscodeRoute(b, invoke)
+		}
+	}
+}
+
+// RegisterGRPCServer generates http routes of unary methods of all services registered on gs,
+// which are invoked by cc, such as a client connection to gs.
+// Services must be registered in protoregistry, which is done by generated code.
+func (s *Server) RegisterGRPCServer(gs *grpc.Server, cc grpc.ClientConnInterface) error {
+	for name := range gs.GetServiceInfo() {
+		d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			return fmt.Errorf("find descriptor of service %s: %w", name, err)
+		}
+		sd, ok := d.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return fmt.Errorf("%s is not service", name)
+		}
+
+		for i := 0; i < sd.Methods().Len(); i++ {
+			md := sd.Methods().Get(i)
+			if md.IsStreamingClient() || md.IsStreamingServer() {
+				continue
+			}
+			bindings, err := httpBindings(md)
+			if err != nil {
+				return err
+			}
+			fullMethod := fmt.Sprintf("/%s/%s", name, md.Name())
+			invoke := func(ctx context.Context, bind func(req proto.Message) error) (proto.Message, metadata.MD, error) {
+				req, resp := newMessage(md.Input()), newMessage(md.Output())
+				if err := bind(req); err != nil {
+					return nil, nil, err
+				}
+				var header, trailer metadata.MD
+				if in, ok := metadata.FromIncomingContext(ctx); ok {
+					ctx = metadata.NewOutgoingContext(ctx, in)
+				}
+				err := cc.Invoke(ctx, fullMethod, req, resp, grpc.Header(&header), grpc.Trailer(&trailer))
+				return resp, metadata.Join(header, trailer), err
+			}
+			for _, b := range bindings {
+				s.addTranscodeRoute(b, invoke)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) addTranscodeRoute(b *httpBinding, invoke unaryInvoker) {
+	if s.transcodeRoutes == nil {
+		s.transcodeRoutes = make(map[string]*transcodeRoute)
+	}
+	key := b.method + " " + b.template.path
+	route, ok := s.transcodeRoutes[key]
+	if !ok {
+		route = &transcodeRoute{}
+		s.transcodeRoutes[key] = route
+		s.Add(b.method, b.template.path, route.handle)
+	}
+	// handlers with custom verbs take precedence
+	h := &transcodeHandler{binding: b, invoke: invoke}
+	if b.template.verbParam != "" {
+		route.handlers = append([]*transcodeHandler{h}, route.handlers...)
+	} else {
+		route.handlers = append(route.handlers, h)
+	}
+}
+
+func (r *transcodeRoute) handle(c echo.Context) error {
+	for _, h := range r.handlers {
+		if h.binding.template.matchVerb(c) {
+			return h.handle(c)
+		}
+	}
+	return echo.ErrNotFound
+}
+
+func (h *transcodeHandler) handle(c echo.Context) error {
+	req := c.Request()
+	ctx := metadata.NewIncomingContext(req.Context(), headerMetadata(req.Header))
+	if addr := remoteAddr(req.RemoteAddr); addr != nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: addr})
+	}
+
+	resp, md, err := h.invoke(ctx, func(m proto.Message) error {
+		if err := h.binding.bind(c, m.ProtoReflect()); err != nil {
+			return status.Errorf(codes.InvalidArgument, "bind request: %v", err)
+		}
+		return nil
+	})
+	for k, vs := range md {
+		for _, v := range vs {
+			c.Response().Header().Add(MetadataHeaderPrefix+k, v)
+		}
+	}
+	if err != nil {
+		return statusError(c, err)
+	}
+	return ProtoJSON(c, http.StatusOK, h.binding.response(resp))
+}
+
+// bind binds request from body, query and path, the latter overrides the former
+func (b *httpBinding) bind(c echo.Context, m protoreflect.Message) error {
+	req := c.Request()
+	if b.body != "" && req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		if len(data) > 0 {
+			if err := b.bindBody(req.Header.Get(HeaderContentType), data, m); err != nil {
+				return err
+			}
+		}
+	}
+
+	if b.body != "*" {
+		for key, values := range c.QueryParams() {
+			if b.body != "" && (key == b.body || strings.HasPrefix(key, b.body+".")) {
+				continue
+			}
+			// unknown query params are ignored
+			if err := setField(m, key, values); err != nil && err != errUnknownField {
+				return fmt.Errorf("query %s: %w", key, err)
+			}
+		}
+	}
+
+	for field, value := range b.template.values(c) {
+		if err := setField(m, field, []string{value}); err != nil {
+			return fmt.Errorf("path %s: %w", field, err)
+		}
+	}
+	return nil
+}
+
+func (b *httpBinding) bindBody(contentType string, data []byte, m protoreflect.Message) error {
+	unmarshal := protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal
+	if strings.HasPrefix(contentType, MIMEApplicationProtobuf) {
+		unmarshal = proto.Unmarshal
+	}
+	if b.body == "*" {
+		return unmarshal(data, m.Interface())
+	}
+
+	parent, fd, err := lookupField(m, b.body)
+	if err != nil {
+		return fmt.Errorf("body %s: %w", b.body, err)
+	}
+	if fd.Kind() == protoreflect.MessageKind && !fd.IsList() && !fd.IsMap() {
+		return unmarshal(data, parent.Mutable(fd).Message().Interface())
+	}
+	if strings.HasPrefix(contentType, MIMEApplicationProtobuf) {
+		return fmt.Errorf("body %s: protobuf body should be message", b.body)
+	}
+	// non-message field is unmarshaled as a field of its parent
+	raw := []byte(fmt.Sprintf(`{%q:%s}`, fd.JSONName(), data))
+	tmp := parent.New()
+	if err := protojson.Unmarshal(raw, tmp.Interface()); err != nil {
+		return err
+	}
+	parent.Set(fd, tmp.Get(fd))
+	return nil
+}
+
+// response returns field of response_body, or the whole response
+func (b *httpBinding) response(resp proto.Message) proto.Message {
+	if b.responseBody == "" || resp == nil {
+		return resp
+	}
+	parent, fd, err := lookupField(resp.ProtoReflect(), b.responseBody)
+	if err != nil || fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+		return resp
+	}
+	return parent.Get(fd).Message().Interface()
+}
+
+// statusError renders grpc status error, such as
+//
+//	{"code":5,"message":"not found"}
+//
+// errors of ProtoError format `code:message` are rendered by ProtoError.
+func statusError(c echo.Context, err error) error {
+	st := status.Convert(err)
+	code := HTTPStatusFromCode(st.Code())
+	if _, ok := statusFromString(st.Message()); ok {
+		return ProtoError(c, code, err)
+	}
+	c.Response().Header().Set(HeaderHRPCErr, "true")
+	return ProtoJSON(c, code, st.Proto())
+}
+
+// HTTPStatusFromCode converts grpc code to http status code
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// hopHeaders are not passed as metadata, they are connection specific or reserved by grpc
+var hopHeaders = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"te":                true,
+	"host":              true,
+	"content-length":    true,
+	"content-type":      true,
+	"user-agent":        true,
+}
+
+func headerMetadata(header http.Header) metadata.MD {
+	md := metadata.MD{}
+	for k, vs := range header {
+		k = strings.ToLower(k)
+		if hopHeaders[k] || strings.HasPrefix(k, "grpc-") {
+			continue
+		}
+		for _, v := range vs {
+			md.Append(k, strings.TrimFunc(v, func(r rune) bool {
+				return r == '\n' || r == '\r' || r == '\000'
+			}))
+		}
+	}
+	return md
+}
+
+func remoteAddr(addr string) net.Addr {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	tcpAddr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil
+	}
+	return tcpAddr
+}
+
+// newMessage returns message of generated type, or dynamic message if not registered
+func newMessage(md protoreflect.MessageDescriptor) proto.Message {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName()); err == nil {
+		return mt.New().Interface()
+	}
+	return dynamicpb.NewMessage(md)
+}
+
+// transportStream collects metadata set by grpc.SetHeader and grpc.SetTrailer of handlers
+type transportStream struct {
+	method  string
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+func (s *transportStream) Method() string { return s.method }
+
+func (s *transportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *transportStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *transportStream) SetTrailer(md metadata.MD) error {
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+func (s *transportStream) metadata() metadata.MD {
+	return metadata.Join(s.header, s.trailer)
+}
@@ -0,0 +1,256 @@
+package xecho
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// libraryFile is descriptor of
+//
+//	message Book { string name = 1; string title = 2; int32 pages = 3; repeated string tags = 4; }
+//	message GetBookRequest { string name = 1; bool full = 2; }
+//	message UpdateBookRequest { Book book = 1; string mask = 2; }
+//	service Library {
+//	  rpc GetBook(GetBookRequest) returns (Book) { option (google.api.http) = { get: "/v1/{name=shelves/*/books/*}" }; }
+//	  rpc CreateBook(Book) returns (Book) { option (google.api.http) = { post: "/v1/{name=shelves/*/books/*}", body: "*" }; }
+//	  rpc PublishBook(GetBookRequest) returns (Book) { option (google.api.http) = { post: "/v1/{name=shelves/*/books/*}:publish" }; }
+//	  rpc UpdateBook(UpdateBookRequest) returns (Book) { option (google.api.http) = { patch: "/v1/{book.name=shelves/*/books/*}", body: "book" }; }
+//	  rpc Echo(Book) returns (Book);
+//	}
+var libraryFile = func() protoreflect.FileDescriptor {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+		label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		if repeated {
+			label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		}
+		f := &descriptorpb.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(number), Type: typ.Enum(), Label: label.Enum(), JsonName: proto.String(name)}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	method := func(name, input string, rule *annotations.HttpRule) *descriptorpb.MethodDescriptorProto {
+		m := &descriptorpb.MethodDescriptorProto{Name: proto.String(name), InputType: proto.String(input), OutputType: proto.String(".pilot.test.Book")}
+		if rule != nil {
+			m.Options = &descriptorpb.MethodOptions{}
+			proto.SetExtension(m.Options, annotations.E_Http, rule)
+		}
+		return m
+	}
+	str, b, i32, msg := descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_BOOL, descriptorpb.FieldDescriptorProto_TYPE_INT32, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("pilot/test/library.proto"),
+		Package: proto.String("pilot.test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Book"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, str, "", false), field("title", 2, str, "", false), field("pages", 3, i32, "", false), field("tags", 4, str, "", true),
+			}},
+			{Name: proto.String("GetBookRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, str, "", false), field("full", 2, b, "", false),
+			}},
+			{Name: proto.String("UpdateBookRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("book", 1, msg, ".pilot.test.Book", false), field("mask", 2, str, "", false),
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Library"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("GetBook", ".pilot.test.GetBookRequest", &annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: "/v1/{name=shelves/*/books/*}"}}),
+				method("CreateBook", ".pilot.test.Book", &annotations.HttpRule{Pattern: &annotations.HttpRule_Post{Post: "/v1/{name=shelves/*/books/*}"}, Body: "*"}),
+				method("PublishBook", ".pilot.test.GetBookRequest", &annotations.HttpRule{Pattern: &annotations.HttpRule_Post{Post: "/v1/{name=shelves/*/books/*}:publish"}}),
+				method("UpdateBook", ".pilot.test.UpdateBookRequest", &annotations.HttpRule{Pattern: &annotations.HttpRule_Patch{Patch: "/v1/{book.name=shelves/*/books/*}"}, Body: "book"}),
+				method("Echo", ".pilot.test.Book", nil),
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(err)
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(fd); err != nil {
+		panic(err)
+	}
+	return fd
+}()
+
+type library struct{}
+
+func (library) call(ctx context.Context, method string, in *dynamicpb.Message) (*dynamicpb.Message, error) {
+	get := func(m protoreflect.Message, name string) protoreflect.Value {
+		return m.Get(m.Descriptor().Fields().ByName(protoreflect.Name(name)))
+	}
+	book := dynamicpb.NewMessage(libraryFile.Messages().ByName("Book"))
+	set := func(name string, v protoreflect.Value) {
+		book.Set(book.Descriptor().Fields().ByName(protoreflect.Name(name)), v)
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-method", method))
+
+	switch method {
+	case "GetBook", "PublishBook":
+		name := get(in, "name").String()
+		if strings.HasSuffix(name, "/404") {
+			return nil, status.Errorf(codes.NotFound, "book %s not found", name)
+		}
+		set("name", protoreflect.ValueOfString(name))
+		if get(in, "full").Bool() {
+			md, _ := metadata.FromIncomingContext(ctx)
+			set("title", protoreflect.ValueOfString(strings.Join(md.Get("x-title"), ",")))
+		}
+	case "UpdateBook":
+		book = get(in, "book").Message().Interface().(*dynamicpb.Message)
+		set("title", protoreflect.ValueOfString(get(book, "title").String()+" by "+get(in, "mask").String()))
+	default:
+		book = in
+	}
+	return book, nil
+}
+
+var libraryDesc = func() *grpc.ServiceDesc {
+	sd := libraryFile.Services().ByName("Library")
+	desc := &grpc.ServiceDesc{ServiceName: string(sd.FullName()), HandlerType: (*interface{})(nil)}
+	for i := 0; i < sd.Methods().Len(); i++ {
+		md := sd.Methods().Get(i)
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: string(md.Name()),
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := dynamicpb.NewMessage(md.Input())
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return srv.(library).call(ctx, string(md.Name()), in)
+			},
+		})
+	}
+	return desc
+}()
+
+func newTestServer(t *testing.T) *Server {
+	config := DefaultConfig()
+	config.Host, config.Port = "127.0.0.1", 0
+	s, err := config.Build()
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = s.listener.Close() })
+	return s
+}
+
+func serve(s *Server, method, target, body string, header ...string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	out := make(map[string]interface{})
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	return rec, out
+}
+
+func testTranscode(t *testing.T, s *Server) {
+	// path and query
+	rec, out := serve(s, http.MethodGet, "/v1/shelves/1/books/2?full=true", "", "X-Title", "golang")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]interface{}{"name": "shelves/1/books/2", "title": "golang"}, out)
+	assert.Equal(t, "GetBook", rec.Header().Get(MetadataHeaderPrefix+"x-method"))
+
+	// body of all fields, path overrides body
+	rec, out = serve(s, http.MethodPost, "/v1/shelves/1/books/3", `{"name":"x","title":"go","pages":10,"tags":["a","b"]}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]interface{}{"name": "shelves/1/books/3", "title": "go", "pages": float64(10), "tags": []interface{}{"a", "b"}}, out)
+
+	// custom verb on the same route
+	rec, out = serve(s, http.MethodPost, "/v1/shelves/1/books/4:publish", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "shelves/1/books/4", out["name"])
+	assert.Equal(t, "PublishBook", rec.Header().Get(MetadataHeaderPrefix+"x-method"))
+
+	// body of field, others from query
+	rec, out = serve(s, http.MethodPatch, "/v1/shelves/1/books/5?mask=title", `{"title":"go"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]interface{}{"name": "shelves/1/books/5", "title": "go by title"}, out)
+
+	// default route
+	rec, out = serve(s, http.MethodPost, "/pilot.test.Library/Echo", `{"name":"echo"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]interface{}{"name": "echo"}, out)
+
+	// status errors
+	rec, out = serve(s, http.MethodGet, "/v1/shelves/1/books/404", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get(HeaderHRPCErr))
+	assert.Equal(t, float64(codes.NotFound), out["code"])
+	assert.Equal(t, "book shelves/1/books/404 not found", out["message"])
+
+	rec, out = serve(s, http.MethodGet, "/v1/shelves/1/books/2?full=yes", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, float64(codes.InvalidArgument), out["code"])
+}
+
+func TestRegisterService(t *testing.T) {
+	s := newTestServer(t)
+	s.RegisterService(libraryDesc, library{})
+	testTranscode(t, s)
+}
+
+func TestRegisterGRPCServer(t *testing.T) {
+	gs := grpc.NewServer()
+	gs.RegisterService(libraryDesc, library{})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() { _ = gs.Serve(lis) }()
+	defer gs.Stop()
+
+	cc, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	defer cc.Close()
+
+	s := newTestServer(t)
+	assert.Nil(t, s.RegisterGRPCServer(gs, cc))
+	testTranscode(t, s)
+}
+
+func TestParsePathTemplate(t *testing.T) {
+	cases := []struct {
+		template, path, verb, verbParam string
+		vars                            []pathVar
+	}{
+		{template: "/v1/messages/{message_id}", path: "/v1/messages/:p0", vars: []pathVar{{field: "message_id", segments: []string{":p0"}}}},
+		{template: "/v1/{name=shelves/*/books/*}", path: "/v1/shelves/:p0/books/:p1", vars: []pathVar{{field: "name", segments: []string{"shelves", ":p0", "books", ":p1"}}}},
+		{template: "/v1/{name=operations/**}:cancel", path: "/v1/operations/*", verb: "cancel", verbParam: "*", vars: []pathVar{{field: "name", segments: []string{"operations", ":*"}}}},
+		{template: "/v1/books:batchGet", path: `/v1/books\:batchGet`, verb: "batchGet"},
+	}
+	for _, c := range cases {
+		pt, err := parsePathTemplate(c.template)
+		if assert.Nil(t, err, c.template) {
+			assert.Equal(t, c.path, pt.path, c.template)
+			assert.Equal(t, c.verb, pt.verb, c.template)
+			assert.Equal(t, c.verbParam, pt.verbParam, c.template)
+			assert.Equal(t, c.vars, pt.vars, c.template)
+		}
+	}
+
+	for _, template := range []string{"v1/books", "/v1/{name", "/v1/{name=**}/books", "/v1//books"} {
+		_, err := parsePathTemplate(template)
+		assert.NotNil(t, err, template)
+	}
+}
@@ -2,10 +2,14 @@ package xgrpc
 
 import (
 	"fmt"
+	"net"
+	"time"
 
 	"github.com/5idu/pilot/pkg/conf"
 	"github.com/5idu/pilot/pkg/constant"
 	"github.com/5idu/pilot/pkg/flag"
+	"github.com/5idu/pilot/pkg/ratelimit"
+	"github.com/5idu/pilot/pkg/util/xnet"
 	"github.com/5idu/pilot/pkg/xlog"
 
 	"github.com/pkg/errors"
@@ -17,7 +21,7 @@ type Config struct {
 	Name string `json:"name"`
 	Host string `json:"host"`
 	Port int    `json:"port"`
-	// Network network type, tcp4 by default
+	// Network network type, tcp4 by default. For unix, Host is the socket, see xnet.Listen
 	Network string `json:"network"`
 	// EnableAccessLog enable Access Interceptor, true by default
 	EnableAccessLog bool
@@ -37,14 +41,26 @@ type Config struct {
 	CertFile string
 	// PrivateFile
 	PrivateFile string
+	// EnableHealth register grpc.health.v1 service, true by default.
+	// Services turn NOT_SERVING once the server begins to stop, so that load balancers drain it.
+	EnableHealth bool
+	// HealthCheckInterval interval to refresh the status of grpc.health.v1 service, 5s by default
+	HealthCheckInterval time.Duration
+	// EnableReflection register grpc server reflection service, false by default
+	EnableReflection bool
+	// EnableChannelz register grpc channelz service, false by default
+	EnableChannelz bool
 
 	Labels map[string]string `json:"labels"`
 
 	serverOptions      []grpc.ServerOption
 	streamInterceptors []grpc.StreamServerInterceptor
 	unaryInterceptors  []grpc.UnaryServerInterceptor
+	listener           net.Listener
 
 	logger *xlog.Logger
+	// configKey set by RawConfig, the limiter of methods binds configKey.ratelimit
+	configKey string
 }
 
 // StdConfig represents Standard gRPC Server config
@@ -60,6 +76,7 @@ func RawConfig(key string) *Config {
 	if err := conf.UnmarshalKey(key, &config); err != nil {
 		panic(errors.WithMessage(err, "grpc server parse config failed"))
 	}
+	config.configKey = key
 	return config
 }
 
@@ -75,6 +92,8 @@ func DefaultConfig() *Config {
 		EnableTrace:               true,
 		EnableMetric:              true,
 		SlowQueryThresholdInMilli: 500,
+		EnableHealth:              true,
+		HealthCheckInterval:       5 * time.Second,
 		logger:                    xlog.With(xlog.String("mod", "grpc.server")),
 		serverOptions:             []grpc.ServerOption{},
 		streamInterceptors:        []grpc.StreamServerInterceptor{},
@@ -123,6 +142,16 @@ func (config *Config) MustBuild() *Server {
 
 // Build ...
 func (config *Config) Build() (*Server, error) {
+	var limiter *ratelimit.Limiter
+	if config.configKey != "" {
+		// rate limits apply before interceptors injected
+		var err error
+		if limiter, err = ratelimit.New(config.configKey + ".ratelimit"); err != nil {
+			return nil, err
+		}
+		config.unaryInterceptors = append([]grpc.UnaryServerInterceptor{rateLimitUnaryServerInterceptor(limiter)}, config.unaryInterceptors...)
+		config.streamInterceptors = append([]grpc.StreamServerInterceptor{rateLimitStreamServerInterceptor(limiter)}, config.streamInterceptors...)
+	}
 	if config.EnableTrace {
 		config.unaryInterceptors = append(config.unaryInterceptors, NewTraceUnaryServerInterceptor())
 		config.streamInterceptors = append(config.streamInterceptors, NewTraceStreamServerInterceptor())
@@ -131,7 +160,27 @@ func (config *Config) Build() (*Server, error) {
 		config.unaryInterceptors = append(config.unaryInterceptors, metricUnaryServerInterceptor)
 		config.streamInterceptors = append(config.streamInterceptors, metricStreamServerInterceptor)
 	}
-	return newServer(config)
+	server, err := newServer(config)
+	if err != nil {
+		if limiter != nil {
+			limiter.Close()
+		}
+		return nil, err
+	}
+	server.limiter = limiter
+	return server, nil
+}
+
+// WithListener serves on listener instead of listening itself, such as a cmux listener of grpc connections
+func (config *Config) WithListener(listener net.Listener) *Config {
+	config.listener = listener
+	return config
+}
+
+// WithConfigKey sets key of config embedded in other servers, so that key.ratelimit applies
+func (config *Config) WithConfigKey(key string) *Config {
+	config.configKey = key
+	return config
 }
 
 // WithLogger ...
@@ -142,5 +191,8 @@ func (config *Config) WithLogger(logger *xlog.Logger) *Config {
 
 // Address ...
 func (config Config) Address() string {
+	if xnet.IsUnixNetwork(config.Network) {
+		return config.Host
+	}
 	return fmt.Sprintf("%s:%d", config.Host, config.Port)
 }
@@ -0,0 +1,67 @@
+package xgrpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// inflight tracks calls being handled, which are logged if cut by stopping
+type inflight struct {
+	mu    sync.Mutex
+	calls map[*call]struct{}
+}
+
+type call struct {
+	method string
+	peer   string
+	start  time.Time
+	stream bool
+}
+
+func newInflight() *inflight {
+	return &inflight{calls: make(map[*call]struct{})}
+}
+
+func (in *inflight) begin(ctx context.Context, method string, stream bool) *call {
+	c := &call{method: method, start: time.Now(), stream: stream}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		c.peer = p.Addr.String()
+	}
+	in.mu.Lock()
+	in.calls[c] = struct{}{}
+	in.mu.Unlock()
+	return c
+}
+
+func (in *inflight) end(c *call) {
+	in.mu.Lock()
+	delete(in.calls, c)
+	in.mu.Unlock()
+}
+
+// list returns calls being handled
+func (in *inflight) list() []*call {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	calls := make([]*call, 0, len(in.calls))
+	for c := range in.calls {
+		calls = append(calls, c)
+	}
+	return calls
+}
+
+func (in *inflight) unaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	c := in.begin(ctx, info.FullMethod, false)
+	defer in.end(c)
+	return handler(ctx, req)
+}
+
+func (in *inflight) streamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	c := in.begin(ss.Context(), info.FullMethod, true)
+	defer in.end(c)
+	return handler(srv, ss)
+}
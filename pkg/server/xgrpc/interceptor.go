@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/5idu/pilot/pkg/ratelimit"
 	"github.com/5idu/pilot/pkg/xlog"
 	"github.com/5idu/pilot/pkg/xmetric"
 	"github.com/5idu/pilot/pkg/xtrace"
@@ -17,6 +18,7 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
@@ -226,6 +228,41 @@ func NewTraceStreamServerInterceptor() grpc.StreamServerInterceptor {
 	}
 }
 
+// rateLimitKey returns value of rule key for the request, such as ip of client
+func rateLimitKey(ctx context.Context, key string) string {
+	switch {
+	case key == ratelimit.KeyIP:
+		return getPeer(ctx)["clientIP"]
+	case key == ratelimit.KeyAID:
+		return getPeer(ctx)["aid"]
+	case strings.HasPrefix(key, ratelimit.KeyHeaderPrefix):
+		md, _ := metadata.FromIncomingContext(ctx)
+		return strings.Join(md.Get(strings.TrimPrefix(key, ratelimit.KeyHeaderPrefix)), ";")
+	}
+	return ""
+}
+
+func rateLimitUnaryServerInterceptor(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !limiter.Allow(ctx, info.FullMethod, func(key string) string { return rateLimitKey(ctx, key) }) {
+			xmetric.RateLimitRejected.Inc(ctx, attribute.String("type", "unary"), attribute.String("method", info.FullMethod))
+			return nil, status.Errorf(grpccodes.ResourceExhausted, "rate limit exceeded: %s", info.FullMethod)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func rateLimitStreamServerInterceptor(limiter *ratelimit.Limiter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		if !limiter.Allow(ctx, info.FullMethod, func(key string) string { return rateLimitKey(ctx, key) }) {
+			xmetric.RateLimitRejected.Inc(ctx, attribute.String("type", "stream"), attribute.String("method", info.FullMethod))
+			return status.Errorf(grpccodes.ResourceExhausted, "rate limit exceeded: %s", info.FullMethod)
+		}
+		return handler(srv, ss)
+	}
+}
+
 func metricUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	startTime := time.Now()
 	resp, err := handler(ctx, req)
@@ -7,12 +7,23 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/5idu/pilot/pkg/health"
+	"github.com/5idu/pilot/pkg/ratelimit"
 	"github.com/5idu/pilot/pkg/server"
+	"github.com/5idu/pilot/pkg/util/xgo"
+	"github.com/5idu/pilot/pkg/util/xnet"
+	"github.com/5idu/pilot/pkg/xlog"
 
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	channelzservice "google.golang.org/grpc/channelz/service"
 	"google.golang.org/grpc/credentials"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 // Server ...
@@ -20,16 +31,23 @@ type Server struct {
 	*grpc.Server
 	listener net.Listener
 	*Config
+
+	health    *grpchealth.Server
+	inflight  *inflight
+	limiter   *ratelimit.Limiter
+	closeOnce sync.Once
+	closed    chan struct{}
 }
 
 func newServer(config *Config) (*Server, error) {
+	inflight := newInflight()
 	var streamInterceptors = append(
-		[]grpc.StreamServerInterceptor{defaultStreamServerInterceptor(config.logger, config)},
+		[]grpc.StreamServerInterceptor{inflight.streamServerInterceptor, defaultStreamServerInterceptor(config.logger, config)},
 		config.streamInterceptors...,
 	)
 
 	var unaryInterceptors = append(
-		[]grpc.UnaryServerInterceptor{defaultUnaryServerInterceptor(config.logger, config)},
+		[]grpc.UnaryServerInterceptor{inflight.unaryServerInterceptor, defaultUnaryServerInterceptor(config.logger, config)},
 		config.unaryInterceptors...,
 	)
 
@@ -65,54 +83,147 @@ func newServer(config *Config) (*Server, error) {
 	)
 
 	newServer := grpc.NewServer(config.serverOptions...)
-	listener, err := net.Listen(config.Network, config.Address())
-	if err != nil {
-		return nil, errors.Wrap(err, "net.Listen failed")
+	listener := config.listener
+	if listener == nil {
+		var err error
+		if listener, err = xnet.Listen(config.Network, config.Address()); err != nil {
+			return nil, errors.Wrap(err, "net.Listen failed")
+		}
 	}
-	config.Port = listener.Addr().(*net.TCPAddr).Port
+	config.Port = xnet.Port(listener.Addr())
 
-	return &Server{
+	s := &Server{
 		Server:   newServer,
 		listener: listener,
 		Config:   config,
-	}, nil
+		inflight: inflight,
+		closed:   make(chan struct{}),
+	}
+	if config.EnableHealth {
+		s.health = grpchealth.NewServer()
+		healthpb.RegisterHealthServer(newServer, s.health)
+	}
+	if config.EnableReflection {
+		reflection.Register(newServer)
+	}
+	if config.EnableChannelz {
+		channelzservice.RegisterChannelzServiceToServer(newServer)
+	}
+	return s, nil
 }
 
+// Healthz reports whether all registered health checkers passed
 func (s *Server) Healthz() bool {
-	return true
+	return health.Healthy(context.Background())
+}
+
+// watchHealth refreshes the serving status of every grpc service by health checkers
+func (s *Server) watchHealth() {
+	interval := s.Config.HealthCheckInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		status := healthpb.HealthCheckResponse_SERVING
+		if !s.Healthz() {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		s.health.SetServingStatus("", status)
+		for name := range s.GetServiceInfo() {
+			s.health.SetServingStatus(name, status)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// shutdownHealth marks all services as NOT_SERVING, and ignores later updates
+func (s *Server) shutdownHealth() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		if s.health != nil {
+			s.health.Shutdown()
+		}
+	})
 }
 
 // Server implements server.Server interface.
 func (s *Server) Serve() error {
 	// display grpc server method list
-	for fm, info := range s.GetServiceInfo() {
-		for _, method := range info.Methods {
-			fmt.Printf("[GRPC] \x1b[34m%8s\x1b[0m.%s\n", fm, method.Name)
-		}
+	for _, route := range s.ListRoutes() {
+		fmt.Printf("[GRPC] \x1b[34m%8s\x1b[0m %s\n", route.Method, route.Path)
 	}
 	// display grpc server addr
 	fmt.Printf("[GRPC] \x1b[33m%8s\x1b[0m %s\n", "Listen On", s.listener.Addr().String())
+	if s.health != nil {
+		xgo.Go(s.watchHealth)
+	}
 	err := s.Server.Serve(s.listener)
 	return err
 }
 
+// ListRoutes implements server.RouteLister interface.
+func (s *Server) ListRoutes() []server.Route {
+	routes := make([]server.Route, 0)
+	for fm, info := range s.GetServiceInfo() {
+		for _, method := range info.Methods {
+			routes = append(routes, server.Route{Method: "GRPC", Path: "/" + fm + "/" + method.Name})
+		}
+	}
+	return routes
+}
+
 // Stop implements server.Server interface
 // it will terminate echo server immediately
 func (s *Server) Stop() error {
+	s.shutdownHealth()
+	s.closeLimiter()
 	s.Server.Stop()
 	return nil
 }
 
 // GracefulStop implements server.Server interface
-// it will stop echo server gracefully
+// it will stop grpc server gracefully, and falls back to Stop if ctx is done,
+// the streams being cut are logged.
 func (s *Server) GracefulStop(ctx context.Context) error {
-	s.Server.GracefulStop()
-	return nil
+	s.shutdownHealth()
+	s.closeLimiter()
+	done := make(chan struct{})
+	go func() {
+		s.Server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	calls := s.inflight.list()
+	s.logger.Warn("graceful stop timeout, force stop", xlog.Int("streams", len(calls)), xlog.FieldErr(ctx.Err()))
+	for _, c := range calls {
+		s.logger.Warn("stream cut",
+			xlog.String("method", c.method),
+			xlog.String("peer", c.peer),
+			xlog.Any("stream", c.stream),
+			xlog.FieldCost(time.Since(c.start)),
+		)
+	}
+	s.Server.Stop()
+	<-done
+	return ctx.Err()
 }
 
 // Info returns server info, used by governor and consumer balancer
 func (s *Server) Info() *server.ServiceInfo {
-	serviceAddress := s.listener.Addr().String()
+	serviceAddress := xnet.ServiceAddress(s.listener.Addr())
 	if s.Config.ServiceAddress != "" {
 		serviceAddress = s.Config.ServiceAddress
 	}
@@ -123,3 +234,10 @@ func (s *Server) Info() *server.ServiceInfo {
 	)
 	return &info
 }
+
+// closeLimiter stops reloading config of rate limits
+func (s *Server) closeLimiter() {
+	if s.limiter != nil {
+		s.limiter.Close()
+	}
+}
@@ -0,0 +1,161 @@
+package xgrpc
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/5idu/pilot/pkg/conf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestServerServices(t *testing.T) {
+	config := DefaultConfig()
+	config.Host, config.Port = "127.0.0.1", 0
+	config.EnableReflection = true
+	config.EnableChannelz = true
+	s := config.MustBuild()
+	defer s.Stop()
+
+	services := s.GetServiceInfo()
+	assert.Contains(t, services, "grpc.health.v1.Health")
+	assert.Contains(t, services, "grpc.reflection.v1alpha.ServerReflection")
+	assert.Contains(t, services, "grpc.channelz.v1.Channelz")
+
+	config = DefaultConfig()
+	config.Host, config.Port = "127.0.0.1", 0
+	config.EnableHealth = false
+	s2 := config.MustBuild()
+	defer s2.Stop()
+	assert.Empty(t, s2.GetServiceInfo())
+}
+
+func TestServerHealthOnGracefulStop(t *testing.T) {
+	config := DefaultConfig()
+	config.Host, config.Port = "127.0.0.1", 0
+	s := config.MustBuild()
+	go func() { _ = s.Serve() }()
+
+	cc, err := grpc.Dial(s.listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	defer cc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	watch, err := healthpb.NewHealthClient(cc).Watch(ctx, &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+	resp, err := watch.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	// the watch stream blocks graceful stop, until it's canceled
+	stopped := make(chan struct{})
+	go func() {
+		_ = s.GracefulStop(context.Background())
+		close(stopped)
+	}()
+	resp, err = watch.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Error("graceful stop not finished")
+	}
+}
+
+func TestServerGracefulStopTimeout(t *testing.T) {
+	config := DefaultConfig()
+	config.Host, config.Port = "127.0.0.1", 0
+	s := config.MustBuild()
+	go func() { _ = s.Serve() }()
+
+	cc, err := grpc.Dial(s.listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	defer cc.Close()
+
+	watch, err := healthpb.NewHealthClient(cc).Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Nil(t, err)
+	_, err = watch.Recv()
+	assert.Nil(t, err)
+	assert.Len(t, s.inflight.list(), 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, s.GracefulStop(ctx), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// the stream is cut by force stop
+	for err == nil {
+		_, err = watch.Recv()
+	}
+	assert.Eventually(t, func() bool { return len(s.inflight.list()) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestServerUnix(t *testing.T) {
+	for _, host := range []string{filepath.Join(t.TempDir(), "grpc.sock"), "@pilot-xgrpc-test"} {
+		config := DefaultConfig()
+		config.Network, config.Host = "unix", host
+		s := config.MustBuild()
+		go func() { _ = s.Serve() }()
+
+		assert.Equal(t, "unix:"+host, s.Info().Address)
+		cc, err := grpc.Dial(s.Info().Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		assert.Nil(t, err)
+		resp, err := healthpb.NewHealthClient(cc).Check(context.Background(), &healthpb.HealthCheckRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+		cc.Close()
+		assert.Nil(t, s.GracefulStop(context.Background()))
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	previous := conf.Default()
+	c := conf.New()
+	conf.SetDefaultConfiguration(c)
+	t.Cleanup(func() { conf.SetDefaultConfiguration(previous) })
+
+	key := "test.server.grpc"
+	require.NoError(t, c.Set(key+".host", "127.0.0.1"))
+	require.NoError(t, c.Set(key+".port", 0))
+	require.NoError(t, c.Set(key+".ratelimit.enable", true))
+	require.NoError(t, c.Set(key+".ratelimit.rules", []map[string]interface{}{
+		{"name": "/grpc.health.v1.Health/Check", "limit": 1, "window": "1m", "key": "header:x-user"},
+	}))
+	s := RawConfig(key).MustBuild()
+	go func() { _ = s.Serve() }()
+	defer s.Stop()
+
+	cc, err := grpc.Dial(s.listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	defer cc.Close()
+
+	client := healthpb.NewHealthClient(cc)
+	check := func(user string) codes.Code {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-user", user)
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		return status.Code(err)
+	}
+	assert.Equal(t, codes.OK, check("alice"))
+	assert.Equal(t, codes.ResourceExhausted, check("alice"))
+	assert.Equal(t, codes.OK, check("bob"))
+
+	// config of rate limits is not reloaded once stopped
+	require.NoError(t, s.Stop())
+	require.NoError(t, c.Set(key+".ratelimit.enable", false))
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, s.limiter.Config().Enable)
+}
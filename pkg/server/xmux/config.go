@@ -0,0 +1,106 @@
+package xmux
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/5idu/pilot/pkg/conf"
+	"github.com/5idu/pilot/pkg/constant"
+	"github.com/5idu/pilot/pkg/flag"
+	"github.com/5idu/pilot/pkg/server/xecho"
+	"github.com/5idu/pilot/pkg/server/xgrpc"
+	"github.com/5idu/pilot/pkg/util/xnet"
+	"github.com/5idu/pilot/pkg/xlog"
+
+	"github.com/pkg/errors"
+)
+
+// Config of server which serves http and grpc on the same port
+type Config struct {
+	Host string
+	Port int
+	// Network network type, tcp4 by default, or unix with Host as in xnet.Listen
+	Network string
+	// ServiceAddress service address in registry info of both http and grpc, default to 'Host:Port'
+	ServiceAddress string
+	// EnableTLS terminates TLS of all connections, h2 and http/1.1 are negotiated by ALPN
+	EnableTLS   bool
+	CertFile    string
+	PrivateFile string
+	// MatchTimeout max duration to read the protocol of connections, including TLS handshake, 10s by default
+	MatchTimeout time.Duration
+
+	// HTTP config of the embedded echo server, its host, port and TLS are ignored
+	HTTP *xecho.Config
+	// GRPC config of the embedded grpc server, its host, port and TLS are ignored
+	GRPC *xgrpc.Config
+
+	logger *xlog.Logger
+}
+
+// DefaultConfig ...
+func DefaultConfig() *Config {
+	return &Config{
+		Host:         flag.String("host"),
+		Port:         9090,
+		Network:      "tcp4",
+		CertFile:     "cert.pem",
+		PrivateFile:  "private.pem",
+		MatchTimeout: 10 * time.Second,
+		HTTP:         xecho.DefaultConfig(),
+		GRPC:         xgrpc.DefaultConfig(),
+		logger:       xlog.With(xlog.String("mod", "mux.server")),
+	}
+}
+
+// StdConfig reads config of key `pilot.server.<name>`, such as
+//
+//	[pilot.server.mux]
+//	port = 9090
+//	[pilot.server.mux.http]
+//	debug = true
+//	[pilot.server.mux.grpc]
+//	enableAccessLog = false
+func StdConfig(name string) *Config {
+	return RawConfig(constant.ConfigKey("server." + name))
+}
+
+// RawConfig ...
+func RawConfig(key string) *Config {
+	var config = DefaultConfig()
+	if err := conf.UnmarshalKey(key, &config); err != nil && errors.Cause(err) != conf.ErrInvalidKey {
+		panic(errors.WithMessage(err, "mux server parse config error"))
+	}
+	// rate limits of the embedded servers are key.http.ratelimit and key.grpc.ratelimit
+	config.HTTP.WithConfigKey(key + ".http")
+	config.GRPC.WithConfigKey(key + ".grpc")
+	return config
+}
+
+// WithLogger ...
+func (config *Config) WithLogger(logger *xlog.Logger) *Config {
+	config.logger = logger
+	return config
+}
+
+// MustBuild ...
+func (config *Config) MustBuild() *Server {
+	server, err := config.Build()
+	if err != nil {
+		xlog.Panic("build xmux server", xlog.FieldErr(err))
+	}
+	return server
+}
+
+// Build listens on Address, then builds the embedded echo and grpc servers on the multiplexed listeners
+func (config *Config) Build() (*Server, error) {
+	return newServer(config)
+}
+
+// Address ...
+func (config *Config) Address() string {
+	if xnet.IsUnixNetwork(config.Network) {
+		return config.Host
+	}
+	return fmt.Sprintf("%s:%d", config.Host, config.Port)
+}
@@ -0,0 +1,183 @@
+package xmux
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"github.com/5idu/pilot/pkg/health"
+	"github.com/5idu/pilot/pkg/server"
+	"github.com/5idu/pilot/pkg/server/xecho"
+	"github.com/5idu/pilot/pkg/server/xgrpc"
+	"github.com/5idu/pilot/pkg/util/xgo"
+	"github.com/5idu/pilot/pkg/util/xnet"
+	"github.com/5idu/pilot/pkg/xlog"
+
+	"github.com/pkg/errors"
+	"github.com/soheilhy/cmux"
+	"go.uber.org/multierr"
+)
+
+// Server serves http and grpc on the same listener.
+// Connections are routed by protocol: HTTP/2 with content-type application/grpc to the grpc server,
+// others, such as HTTP/1.1 and HTTP/2 of browsers, to the echo server.
+// With TLS enabled, connections are terminated by the listener, and h2 is negotiated by ALPN.
+type Server struct {
+	// Echo embedded echo server, to register routes and middlewares
+	Echo *xecho.Server
+	// GRPC embedded grpc server, to register services
+	GRPC *xgrpc.Server
+
+	config   *Config
+	listener net.Listener
+	mux      cmux.CMux
+}
+
+func newServer(config *Config) (*Server, error) {
+	var tlsConfig *tls.Config
+	if config.EnableTLS {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.PrivateFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "tls.LoadX509KeyPair failed")
+		}
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"h2", "http/1.1"},
+		}
+	}
+
+	listener, err := xnet.Listen(config.Network, config.Address())
+	if err != nil {
+		return nil, errors.Wrap(err, "net.Listen failed")
+	}
+	config.Port = xnet.Port(listener.Addr())
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	mux := cmux.New(listener)
+	mux.SetReadTimeout(config.MatchTimeout)
+	// grpc clients wait for SETTINGS frame of server before sending headers,
+	// which is acknowledged later by HTTP/2 clients of echo server, see settingsTracker
+	tracker := &settingsTracker{}
+	grpcListener := mux.MatchWithWriters(tracker.match("content-type", "application/grpc"))
+	httpListener := tracker.listener(mux.Match(cmux.Any()))
+
+	// TLS has been terminated by the listener
+	config.GRPC.EnableTLS = false
+	config.HTTP.EnableTLS = false
+	config.HTTP.EnableH2C = true
+	if config.ServiceAddress != "" {
+		config.GRPC.ServiceAddress = config.ServiceAddress
+		config.HTTP.ServiceAddress = config.ServiceAddress
+	}
+
+	grpcServer, err := config.GRPC.WithListener(grpcListener).Build()
+	if err != nil {
+		_ = listener.Close()
+		return nil, errors.WithMessage(err, "build grpc server failed")
+	}
+	echoServer, err := config.HTTP.WithListener(httpListener).Build()
+	if err != nil {
+		_ = listener.Close()
+		return nil, errors.WithMessage(err, "build echo server failed")
+	}
+
+	return &Server{
+		Echo:     echoServer,
+		GRPC:     grpcServer,
+		config:   config,
+		listener: listener,
+		mux:      mux,
+	}, nil
+}
+
+// Serve implements server.Server interface, it blocks until both servers stopped.
+func (s *Server) Serve() error {
+	var err error
+	for e := range xgo.ParallelWithErrorChan(s.GRPC.Serve, s.Echo.Serve, s.serveMux) {
+		if !isClosed(e) {
+			err = multierr.Append(err, e)
+		}
+	}
+	return err
+}
+
+func (s *Server) serveMux() error {
+	s.config.logger.Info("mux server listen", xlog.String("addr", s.listener.Addr().String()))
+	return s.mux.Serve()
+}
+
+// Stop implements server.Server interface
+// it will terminate both servers immediately
+func (s *Server) Stop() error {
+	var err error
+	for _, e := range []error{s.GRPC.Stop(), s.Echo.Stop(), s.close()} {
+		if !isClosed(e) {
+			err = multierr.Append(err, e)
+		}
+	}
+	return err
+}
+
+// GracefulStop implements server.Server interface
+// it will stop both servers gracefully
+func (s *Server) GracefulStop(ctx context.Context) error {
+	var err error
+	for e := range xgo.ParallelWithErrorChan(
+		func() error { return s.GRPC.GracefulStop(ctx) },
+		func() error { return s.Echo.GracefulStop(ctx) },
+	) {
+		// the listener is shared, it may have been closed by the other server
+		if !isClosed(e) {
+			err = multierr.Append(err, e)
+		}
+	}
+	return multierr.Append(err, s.close())
+}
+
+// close stops accepting connections, connections accepted are left to the servers
+func (s *Server) close() error {
+	s.mux.Close()
+	if err := s.listener.Close(); err != nil && !isClosed(err) {
+		return err
+	}
+	return nil
+}
+
+// Healthz reports whether all registered health checkers passed
+func (s *Server) Healthz() bool {
+	return health.Healthy(context.Background())
+}
+
+// Info implements server.Server interface, it returns info of the grpc server,
+// all infos are returned by ListServiceInfos.
+func (s *Server) Info() *server.ServiceInfo {
+	return s.GRPC.Info()
+}
+
+// ListServiceInfos implements server.ServiceInfoLister interface,
+// it returns info of grpc and http, which share the same address.
+func (s *Server) ListServiceInfos() []*server.ServiceInfo {
+	infos := make([]*server.ServiceInfo, 0, 2)
+	for _, info := range []*server.ServiceInfo{s.GRPC.Info(), s.Echo.Info()} {
+		if info != nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// ListRoutes implements server.RouteLister interface.
+func (s *Server) ListRoutes() []server.Route {
+	return append(s.GRPC.ListRoutes(), s.Echo.ListRoutes()...)
+}
+
+// isClosed reports whether err is caused by closing listeners
+func isClosed(err error) bool {
+	return errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, cmux.ErrListenerClosed) ||
+		errors.Is(err, cmux.ErrServerClosed) ||
+		errors.Is(err, http.ErrServerClosed)
+}
@@ -0,0 +1,179 @@
+package xmux
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/5idu/pilot/pkg/conf"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func startServer(t *testing.T, config *Config) *Server {
+	config.Host = "127.0.0.1"
+	config.Port = 0
+	s, err := config.Build()
+	assert.Nil(t, err)
+	s.Echo.GET("/hello", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Request().Proto)
+	})
+
+	served := make(chan error, 1)
+	go func() { served <- s.Serve() }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		assert.Nil(t, s.GracefulStop(ctx))
+		select {
+		case err := <-served:
+			assert.Nil(t, err)
+		case <-time.After(3 * time.Second):
+			t.Error("serve not returned after graceful stop")
+		}
+	})
+	return s
+}
+
+func checkGRPC(t *testing.T, addr string, creds credentials.TransportCredentials) {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	assert.Eventually(t, func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		return err == nil && resp.Status == healthpb.HealthCheckResponse_SERVING
+	}, 3*time.Second, 50*time.Millisecond)
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	resp, err := client.Get(url)
+	if !assert.Nil(t, err) {
+		return ""
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	return string(body)
+}
+
+func TestServer(t *testing.T) {
+	s := startServer(t, DefaultConfig())
+	addr := s.listener.Addr().String()
+
+	checkGRPC(t, addr, insecure.NewCredentials())
+	assert.Equal(t, "HTTP/1.1", get(t, http.DefaultClient, "http://"+addr+"/hello"))
+
+	// HTTP/2 with prior knowledge, which isn't grpc
+	h2c := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	assert.Equal(t, "HTTP/2.0", get(t, h2c, "http://"+addr+"/hello"))
+
+	infos := s.ListServiceInfos()
+	if assert.Len(t, infos, 2) {
+		assert.Equal(t, "grpc", infos[0].Scheme)
+		assert.Equal(t, "http", infos[1].Scheme)
+		assert.Equal(t, addr, infos[0].Address)
+		assert.Equal(t, addr, infos[1].Address)
+	}
+}
+
+func TestServerTLS(t *testing.T) {
+	config := DefaultConfig()
+	config.EnableTLS = true
+	config.CertFile, config.PrivateFile = writeCert(t)
+	s := startServer(t, config)
+	addr := s.listener.Addr().String()
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	checkGRPC(t, addr, credentials.NewTLS(tlsConfig))
+
+	// protocols are negotiated by ALPN
+	h1 := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	assert.Equal(t, "HTTP/1.1", get(t, h1, "https://"+addr+"/hello"))
+	h2 := &http.Client{Transport: &http2.Transport{TLSClientConfig: tlsConfig}}
+	assert.Equal(t, "HTTP/2.0", get(t, h2, "https://"+addr+"/hello"))
+}
+
+// writeCert writes a self-signed certificate of 127.0.0.1
+func writeCert(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "private.pem")
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestServerRateLimit(t *testing.T) {
+	previous := conf.Default()
+	c := conf.New()
+	conf.SetDefaultConfiguration(c)
+	t.Cleanup(func() { conf.SetDefaultConfiguration(previous) })
+
+	key := "test.server.mux"
+	rules := func(name string) []map[string]interface{} {
+		return []map[string]interface{}{{"name": name, "limit": 1, "window": "1m"}}
+	}
+	require.NoError(t, c.Set(key+".http.ratelimit", map[string]interface{}{"enable": true, "rules": rules("/hello")}))
+	require.NoError(t, c.Set(key+".grpc.ratelimit", map[string]interface{}{"enable": true, "rules": rules("/grpc.health.v1.Health/Check")}))
+	s := startServer(t, RawConfig(key))
+	addr := s.listener.Addr().String()
+
+	// limits of the embedded servers apply
+	assert.Equal(t, "HTTP/1.1", get(t, http.DefaultClient, "http://"+addr+"/hello"))
+	resp, err := http.Get("http://" + addr + "/hello")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
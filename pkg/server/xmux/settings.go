@@ -0,0 +1,143 @@
+package xmux
+
+import (
+	"io"
+	"net"
+	"sync"
+
+	"github.com/soheilhy/cmux"
+	"golang.org/x/net/http2"
+)
+
+const (
+	frameHeaderLen   = 9
+	frameTypeSetting = 0x4
+	frameFlagAck     = 0x1
+)
+
+// settingsTracker records SETTINGS frames written while matching grpc connections.
+// HTTP/2 connections which are not grpc are passed to echo server,
+// the acknowledgments of these frames are dropped, which would be protocol errors of echo server.
+type settingsTracker struct {
+	// sent connection => number of SETTINGS frames written
+	sent sync.Map
+}
+
+// match matches HTTP/2 connections by header prefix, and sends SETTINGS frame to clients waiting for it
+func (t *settingsTracker) match(name, valuePrefix string) cmux.MatchWriter {
+	matcher := cmux.HTTP2MatchHeaderFieldPrefixSendSettings(name, valuePrefix)
+	return func(w io.Writer, r io.Reader) bool {
+		cw := &countWriter{Writer: w}
+		if matcher(cw, r) {
+			return true
+		}
+		if conn, ok := w.(net.Conn); ok && cw.frames > 0 {
+			t.sent.Store(conn, cw.frames)
+		}
+		return false
+	}
+}
+
+// listener wraps connections which SETTINGS frames were sent to
+func (t *settingsTracker) listener(l net.Listener) net.Listener {
+	return &trackedListener{Listener: l, tracker: t}
+}
+
+// countWriter counts frames written, the framer writes a frame by a single Write
+type countWriter struct {
+	io.Writer
+	frames int
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.frames++
+	return w.Writer.Write(p)
+}
+
+type trackedListener struct {
+	net.Listener
+	tracker *settingsTracker
+}
+
+func (l *trackedListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if mc, ok := c.(*cmux.MuxConn); ok {
+		if frames, ok := l.tracker.sent.LoadAndDelete(mc.Conn); ok {
+			return &ackDropConn{Conn: c, drop: frames.(int), preface: len(http2.ClientPreface)}, nil
+		}
+	}
+	return c, nil
+}
+
+// ackDropConn drops the first acknowledgments of SETTINGS frames read from client
+type ackDropConn struct {
+	net.Conn
+	// drop number of acknowledgments to drop
+	drop int
+	// preface bytes of client preface to pass
+	preface int
+	// payload bytes of current frame to pass
+	payload int
+	in, out []byte
+}
+
+func (c *ackDropConn) Read(p []byte) (int, error) {
+	for len(c.out) == 0 {
+		if c.drop == 0 && len(c.in) == 0 {
+			return c.Conn.Read(p)
+		}
+		buf := make([]byte, len(p))
+		n, err := c.Conn.Read(buf)
+		c.in = append(c.in, buf[:n]...)
+		c.filter()
+		if err != nil && len(c.out) == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+// filter moves bytes from in to out, skipping acknowledgments of SETTINGS
+func (c *ackDropConn) filter() {
+	for c.drop > 0 && len(c.in) > 0 {
+		switch {
+		case c.preface > 0:
+			c.pass(&c.preface)
+		case c.payload > 0:
+			c.pass(&c.payload)
+		case len(c.in) < frameHeaderLen:
+			// wait for the whole frame header
+			return
+		default:
+			length := int(c.in[0])<<16 | int(c.in[1])<<8 | int(c.in[2])
+			if c.in[3] == frameTypeSetting && c.in[4]&frameFlagAck != 0 && length == 0 {
+				c.in = c.in[frameHeaderLen:]
+				c.drop--
+				continue
+			}
+			c.out = append(c.out, c.in[:frameHeaderLen]...)
+			c.in = c.in[frameHeaderLen:]
+			c.payload = length
+		}
+	}
+	if c.drop == 0 {
+		c.out = append(c.out, c.in...)
+		c.in = nil
+	}
+}
+
+// pass moves at most *remain bytes from in to out
+func (c *ackDropConn) pass(remain *int) {
+	n := *remain
+	if n > len(c.in) {
+		n = len(c.in)
+	}
+	c.out = append(c.out, c.in[:n]...)
+	c.in = c.in[n:]
+	*remain -= n
+}
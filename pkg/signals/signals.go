@@ -0,0 +1,24 @@
+package signals
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+var shutdownSignals = []os.Signal{syscall.SIGQUIT, syscall.SIGINT, syscall.SIGTERM}
+
+// Shutdown registers the shutdown signals, stop will be called
+// in a new goroutine once the first signal received.
+// SIGQUIT asks for an immediate stop, SIGINT and SIGTERM ask for a graceful one.
+// A second signal will terminate the process directly.
+func Shutdown(stop func(grace bool)) {
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, shutdownSignals...)
+	go func() {
+		s := <-sig
+		go stop(s != syscall.SIGQUIT)
+		<-sig
+		os.Exit(128 + int(s.(syscall.Signal)))
+	}()
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"github.com/5idu/pilot/pkg/health"
+
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -45,6 +47,9 @@ func newClient(config *Config) *Client {
 		config: config,
 	}
 	_instances.Store(config.Name, c)
+	health.RegisterReadiness("mongo:"+config.Name, health.CheckerFunc(func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	}))
 
 	// if config.EnableMetric {
 	// 	cb, err := xmetric.MongoDBClientSession.Observe(func(ctx context.Context, o metric.Observer) error {
@@ -71,6 +76,7 @@ func (c *Client) NewDatabase(dbname string) *Database {
 }
 
 func (c *Client) Close() error {
+	health.Unregister("mongo:" + c.config.Name)
 	// if len(c.metricCallbacks) > 0 {
 	// 	for _, cb := range c.metricCallbacks {
 	// 		cb.Unregister()
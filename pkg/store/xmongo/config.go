@@ -11,16 +11,20 @@ import (
 	"github.com/spf13/cast"
 )
 
+func init() {
+	conf.RegisterSchemaFunc(func() string { return constant.ConfigKey("mongo.*") }, DefaultConfig())
+}
+
 // Config ...
 type (
 	Config struct {
 		Name string
 		// DSN地址
-		DSN string `json:"dsn"`
+		DSN string `json:"dsn" validate:"required"`
 		// 创建连接的超时时间
 		SocketTimeout time.Duration `json:"socketTimeout"`
 		// 连接池大小(最大连接数)
-		PoolLimit int `json:"poolLimit"`
+		PoolLimit int `json:"poolLimit" validate:"gte=0"`
 
 		EnableTrace  bool `json:"enableTrace"`
 		EnableMetric bool `json:"enableMetric"`
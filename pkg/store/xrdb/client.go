@@ -1,8 +1,11 @@
 package xrdb
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/5idu/pilot/pkg/health"
+
 	"github.com/pkg/errors"
 	"github.com/uptrace/opentelemetry-go-extra/otelgorm"
 	"go.opentelemetry.io/otel/attribute"
@@ -23,6 +26,7 @@ type Client struct {
 }
 
 func (c *Client) Close() {
+	health.Unregister("rdb:" + c.config.Name)
 	db, err := c.DB.DB()
 	if err == nil && db != nil {
 		db.Close()
@@ -81,6 +85,10 @@ func newClient(config *Config) (*Client, error) {
 		}
 	}
 
+	health.RegisterReadiness("rdb:"+config.Name, health.CheckerFunc(func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}))
+
 	return &Client{inner, config}, err
 }
 
@@ -1,6 +1,8 @@
 package xfile
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -187,3 +189,21 @@ func TestRecursiveDirsLookup(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("a: 1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteAtomic(path, []byte("a: 2")); err != nil {
+		t.Fatalf("WriteAtomic() error = %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil || string(content) != "a: 2" {
+		t.Errorf("WriteAtomic() content = %s, err = %v, want a: 2", content, err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode() != 0600 {
+		t.Errorf("WriteAtomic() mode = %v, want %v", fi.Mode(), os.FileMode(0600))
+	}
+}
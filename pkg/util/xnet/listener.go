@@ -3,6 +3,10 @@ package xnet
 import (
 	"fmt"
 	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // LocalListener 随机一个本地端口，返回listener
@@ -15,3 +19,51 @@ func LocalListener() net.Listener {
 	}
 	return l
 }
+
+// IsUnixNetwork reports whether network is unix domain socket
+func IsUnixNetwork(network string) bool {
+	return network == "unix"
+}
+
+// Listen listens on address of network.
+// Address of unix network is path of socket, such as /tmp/app.sock,
+// or name prefixed by @ of abstract socket on linux, such as @app.
+// stale socket file left by exited process is removed before listening.
+func Listen(network, address string) (net.Listener, error) {
+	if IsUnixNetwork(network) && address != "" && !strings.HasPrefix(address, "@") {
+		if fi, err := os.Stat(address); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			// socket in use is not removed, listening will fail
+			if conn, err := net.DialTimeout(network, address, time.Second); err == nil {
+				_ = conn.Close()
+			} else {
+				_ = os.Remove(address)
+			}
+		}
+	}
+	return net.Listen(network, address)
+}
+
+// Port returns port of tcp address, 0 for others, such as unix socket
+func Port(addr net.Addr) int {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.Port
+	}
+	return 0
+}
+
+// ServiceAddress returns address of listener to be dialed by clients, such as 192.168.1.2:9092 or unix:/tmp/app.sock.
+// Unspecified ip, such as 0.0.0.0, is replaced by the main ip of host.
+func ServiceAddress(addr net.Addr) string {
+	switch addr := addr.(type) {
+	case *net.UnixAddr:
+		// accepted by grpc target and dialer, abstract socket is unix:@name
+		return "unix:" + addr.Name
+	case *net.TCPAddr:
+		if addr.IP.IsUnspecified() {
+			if ip, _, err := GetLocalMainIP(); err == nil {
+				return net.JoinHostPort(ip, strconv.Itoa(addr.Port))
+			}
+		}
+	}
+	return addr.String()
+}
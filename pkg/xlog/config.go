@@ -3,14 +3,17 @@ package xlog
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/5idu/pilot/pkg/conf"
 	"github.com/5idu/pilot/pkg/constant"
+	"github.com/5idu/pilot/pkg/hooks"
 
 	"go.uber.org/zap"
 )
 
 func init() {
+	conf.RegisterSchemaFunc(func() string { return constant.ConfigKey("logger.*") }, DefaultConfig())
 	conf.OnLoaded(func(c *conf.Configuration) {
 		prefix := constant.GetConfigPrefix()
 		log.Print("hook config, init logger")
@@ -18,7 +21,42 @@ func init() {
 		key := prefix + ".logger.default"
 		log.Printf("reload default logger with configKey: %s\n", key)
 		logger = RawConfig(key).Build()
+		bindLevel(key, logger)
 	})
+	hooks.SetLogger(logHook)
+}
+
+// logHook logs hooks by name, or the whole stage if name is empty
+func logHook(stage hooks.Stage, name string, cost time.Duration, err error) {
+	fields := []Field{String("mod", "hooks"), String("stage", stage.String()), FieldCost(cost)}
+	if name != "" {
+		fields = append(fields, String("hook", name))
+	}
+	if err != nil {
+		Default().Error("hook failed", append(fields, FieldErr(err))...)
+		return
+	}
+	Default().Info("hook done", fields...)
+}
+
+var levelBinding *conf.Binding
+
+// bindLevel changes level of logger on changes of config
+func bindLevel(key string, l *Logger) {
+	if levelBinding != nil {
+		levelBinding.Close()
+	}
+	binding, err := conf.Bind(key, DefaultConfig(), conf.WithOnChange(func(old, new interface{}) {
+		if level := new.(*Config).Level; level != old.(*Config).Level {
+			log.Printf("change level of default logger to %s", level)
+			l.SetLevel(level)
+		}
+	}))
+	if err != nil {
+		log.Printf("bind logger config failed: %v", err)
+		return
+	}
+	levelBinding = binding
 }
 
 const (
@@ -32,12 +70,12 @@ const (
 type Config struct {
 	Dir       string // 日志输出目录
 	Name      string // 日志文件名称
-	Level     string
+	Level     string `validate:"oneofci=debug info warn error panic fatal"`
 	Compress  bool
-	MaxBackup int
-	MaxSize   int
-	MaxAge    int
-	Format    string
+	MaxBackup int    `validate:"gte=0"`
+	MaxSize   int    `validate:"gte=0"`
+	MaxAge    int    `validate:"gte=0"`
+	Format    string // json, or text for others such as console
 	configKey string
 }
 
@@ -74,9 +112,11 @@ func DefaultConfig() *Config {
 
 // Build ...
 func (config Config) Build() *Logger {
-	core := newLoggerCore(&config)
+	lvl := zap.NewAtomicLevelAt(getzaplogLevel(config.Level))
+	core := newLoggerCore(&config, lvl)
 	zapopts := newLoggerOptions()
 	return &Logger{
-		zlog: zap.New(core, zapopts...),
+		zlog:  zap.New(core, zapopts...),
+		level: lvl,
 	}
 }
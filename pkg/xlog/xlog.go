@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/5idu/pilot/pkg/xtrace"
 
@@ -13,11 +14,13 @@ import (
 	lumberjack "gopkg.in/natefinch/lumberjack.v2"
 )
 
-var logger *Logger
+// logger writes to stdout until the configuration loaded
+var logger = DefaultConfig().Build()
 
 type Logger struct {
-	ctx  context.Context
-	zlog *zap.Logger
+	ctx   context.Context
+	zlog  *zap.Logger
+	level zap.AtomicLevel
 }
 
 // Default returns default logger
@@ -25,13 +28,12 @@ func Default() *Logger {
 	return logger
 }
 
-func newLoggerCore(c *Config) zapcore.Core {
+func newLoggerCore(c *Config, lvl zap.AtomicLevel) zapcore.Core {
 	hook := newLogWriter(c)
-	lvl := zap.NewAtomicLevelAt(getzaplogLevel(c.Level))
 
 	encoderConfig := newZapEncoder()
 	var encoder zapcore.Encoder
-	if c.Format == FormatJSON {
+	if strings.EqualFold(c.Format, FormatJSON) {
 		encoder = zapcore.NewJSONEncoder(encoderConfig)
 	} else {
 		encoder = zapcore.NewConsoleEncoder(encoderConfig)
@@ -92,7 +94,7 @@ func newLoggerOptions() []zap.Option {
 }
 
 func getzaplogLevel(level string) zapcore.Level {
-	switch level {
+	switch strings.ToLower(level) {
 	case "debug":
 		return zap.DebugLevel
 	case "info":
@@ -110,6 +112,11 @@ func getzaplogLevel(level string) zapcore.Level {
 	}
 }
 
+// SetLevel changes level of the logger and loggers derived from it
+func (l *Logger) SetLevel(level string) {
+	l.level.SetLevel(getzaplogLevel(level))
+}
+
 func (l *Logger) With(fields ...Field) *Logger {
 	l.zlog = l.zlog.With(fields...)
 	return l
@@ -1,14 +1,21 @@
 package xmetric
 
 import (
+	"context"
 	"log"
 
 	"github.com/5idu/pilot/pkg/conf"
 	"github.com/5idu/pilot/pkg/constant"
 	"github.com/5idu/pilot/pkg/xmetric/otelgrpc"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func init() {
+	conf.OnRejected(func(key string, err error) {
+		ConfigRejected.Inc(context.Background(), attribute.String("key", key))
+	})
+
 	// 加载完配置，初始化 metric
 	conf.OnLoaded(func(c *conf.Configuration) {
 		log.Println("hook config, init metric config")
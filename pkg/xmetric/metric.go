@@ -29,4 +29,8 @@ var (
 	GRPCServerStreamFault = NewInt64CounterVecOpts("grpc.server.stream.faults", "The number of grpc server stream faults.")
 	// GRPCServerStreamDuration ...
 	GRPCServerStreamDuration = NewHistogramVec("grpc.server.stream.duration", "The duration of grpc server stream.")
+	// ConfigRejected ...
+	ConfigRejected = NewInt64CounterVecOpts("config.rejected", "The number of rejected config changes.")
+	// RateLimitRejected ...
+	RateLimitRejected = NewInt64CounterVecOpts("ratelimit.rejected", "The number of requests rejected by rate limit.")
 )